@@ -205,6 +205,9 @@ type BlockChainConfig struct {
 	HotCacheShadowMode   bool
 	HotCacheWatchlist    []common.Address
 	HotCacheMaxSnapshots int
+	HotCacheJournal      string // File to persist the hot cache across restarts (optional)
+
+	HotCacheSkipOptionalSlots bool // Skip reading optional decoder slots, e.g. V2 price cumulatives
 }
 
 // DefaultConfig returns the default config.
@@ -411,6 +414,8 @@ func NewBlockChain(db ethdb.Database, genesis *Genesis, engine consensus.Engine,
 		Watchlist:    cfg.HotCacheWatchlist,
 		ShadowMode:   cfg.HotCacheShadowMode,
 		MaxSnapshots: cfg.HotCacheMaxSnapshots,
+
+		SkipOptionalSlots: cfg.HotCacheSkipOptionalSlots,
 	}
 	if hotCacheConfig.MaxSnapshots == 0 {
 		hotCacheConfig.MaxSnapshots = 64 // Default
@@ -561,6 +566,12 @@ func NewBlockChain(db ethdb.Database, genesis *Genesis, engine consensus.Engine,
 			log.Info("Failed to setup size tracker", "err", err)
 		}
 	}
+	bc.loadHotCacheJournal()
+	if bc.hotCache.IsEnabled() && bc.hotCache.GetSnapshot().BlockHash != bc.CurrentBlock().Hash() {
+		if err := bc.WarmupHotCache(); err != nil {
+			log.Warn("Failed to warm up hot cache", "err", err)
+		}
+	}
 	return bc, nil
 }
 
@@ -1304,6 +1315,7 @@ func (bc *BlockChain) stopWithoutSaving() {
 // it will abort them using the procInterrupt.
 func (bc *BlockChain) Stop() {
 	bc.stopWithoutSaving()
+	bc.saveHotCacheJournal()
 
 	// Ensure that the entirety of the state snapshot is journaled to disk.
 	var snapBase common.Hash
@@ -1701,6 +1713,11 @@ func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types
 	}
 	currentBlock := bc.CurrentBlock()
 
+	// Let the hot cache watch contracts created in the block before a reorg
+	// may replay it
+	if bc.hotCache.IsEnabled() {
+		bc.hotCache.ProcessLogs(logs)
+	}
 	// Reorganise the chain if the parent is not the head block
 	if block.ParentHash() != currentBlock.Hash() {
 		if err := bc.reorg(currentBlock, block.Header()); err != nil {
@@ -1711,18 +1728,12 @@ func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types
 	// Set new head.
 	bc.writeHeadBlock(block)
 	
-	// Update hot state cache if enabled
-	if bc.hotCache.IsEnabled() {
+	// Update hot state cache if enabled (validated against state in shadow
+	// mode), unless the reorg above already replayed the new head
+	if bc.hotCache.IsEnabled() && bc.hotCache.GetSnapshotByHash(block.Hash()) == nil {
 		if err := bc.hotCache.Update(block.Header(), hotcache.NewStateDBReader(state)); err != nil {
 			log.Warn("Failed to update hot cache", "block", block.NumberU64(), "err", err)
 		}
-		
-		// Validate cache in shadow mode
-		if bc.hotCache.IsEnabled() {
-			if err := bc.hotCache.Validate(hotcache.NewStateDBReader(state)); err != nil {
-				log.Error("Hot cache validation failed", "block", block.NumberU64(), "err", err)
-			}
-		}
 	}
 
 	bc.chainFeed.Send(ChainEvent{
@@ -2591,16 +2602,18 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Header) error
 	// Release the tx-lookup lock after mutation.
 	bc.txLookupLock.Unlock()
 	
-	// Handle hot cache reorg if enabled
+	// Handle hot cache reorg if enabled, replaying every block of the new
+	// chain from its own state
 	if bc.hotCache.IsEnabled() {
-		// Get the latest state at the new head to use for replay
-		newHeadState, err := bc.StateAt(bc.CurrentBlock().Root)
-		if err != nil {
-			log.Warn("Failed to get state for hot cache reorg", "err", err)
-		} else {
-			if err := bc.hotCache.HandleReorg(oldChain, newChain, hotcache.NewStateDBReader(newHeadState)); err != nil {
-				log.Error("Failed to handle hot cache reorg", "err", err)
+		states := func(root common.Hash) (hotcache.StateReader, error) {
+			statedb, err := bc.StateAt(root)
+			if err != nil {
+				return nil, err
 			}
+			return hotcache.NewStateDBReader(statedb), nil
+		}
+		if err := bc.hotCache.HandleReorg(oldChain, newChain, states); err != nil {
+			log.Error("Failed to handle hot cache reorg", "err", err)
 		}
 	}
 
@@ -17,15 +17,26 @@
 package core
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math/big"
+	"os"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state/hotcache"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 var (
-	ErrHotCacheDisabled = errors.New("hot cache is disabled")
-	ErrHotCacheNotFound = errors.New("contract not in hot cache")
+	ErrHotCacheDisabled     = errors.New("hot cache is disabled")
+	ErrHotCacheNotFound     = errors.New("contract not in hot cache")
+	ErrHotCacheNotPopulated = errors.New("contract watched but not yet in hot cache")
+	ErrHotCacheNotUniswapV2 = errors.New("contract is not a Uniswap V2 pool")
+	ErrHotCacheNoSnapshot   = errors.New("hot cache snapshot not retained")
+	ErrHotCacheNoLiquidity  = errors.New("pool has no liquidity")
+	ErrHotCacheNoDecoder    = errors.New("no hot cache decoder registered for contract")
+	ErrHotCacheNoCumulative = errors.New("pool price cumulatives not cached")
 )
 
 // HotCache returns the hot state cache instance.
@@ -37,6 +48,15 @@ func (bc *BlockChain) HotCache() *hotcache.Cache {
 	return bc.hotCache
 }
 
+// HotCacheReader returns the read-only view of the hot state cache, or nil if
+// the cache is disabled.
+func (bc *BlockChain) HotCacheReader() hotcache.Reader {
+	if cache := bc.HotCache(); cache != nil {
+		return cache
+	}
+	return nil
+}
+
 // GetHotCacheSnapshot returns the current hot cache snapshot.
 // This provides a consistent view of all cached contract states.
 func (bc *BlockChain) GetHotCacheSnapshot() (*hotcache.Snapshot, error) {
@@ -46,23 +66,79 @@ func (bc *BlockChain) GetHotCacheSnapshot() (*hotcache.Snapshot, error) {
 	return bc.hotCache.GetSnapshot(), nil
 }
 
+// SubscribeHotCacheUpdates subscribes to the snapshots published by the hot
+// cache, see hotcache.Cache.SubscribeUpdates.
+func (bc *BlockChain) SubscribeHotCacheUpdates() (<-chan *hotcache.Snapshot, func(), error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return nil, nil, ErrHotCacheDisabled
+	}
+	updates, unsubscribe := bc.hotCache.SubscribeUpdates()
+	return updates, unsubscribe, nil
+}
+
+// IsHotCacheStale reports whether the hot cache lags the current head by more
+// than maxLag blocks. A disabled cache is always stale.
+func (bc *BlockChain) IsHotCacheStale(maxLag uint64) bool {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return true
+	}
+	return bc.hotCache.IsStale(bc.CurrentBlock().Number.Uint64(), maxLag)
+}
+
 // GetHotCachedContractState returns the cached state for a specific contract.
 // This is significantly faster than state trie lookups for frequently-accessed contracts.
+// Returns ErrHotCacheNotPopulated if the contract is watched but not cached
+// yet, and ErrHotCacheNotFound if it is not watched.
 func (bc *BlockChain) GetHotCachedContractState(addr common.Address) (*hotcache.ContractState, error) {
 	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
 		return nil, ErrHotCacheDisabled
 	}
 	state, err := bc.hotCache.GetContractState(addr)
 	if err != nil {
-		if errors.Is(err, hotcache.ErrNotFound) {
-			return nil, ErrHotCacheNotFound
-		}
-		return nil, err
+		return nil, hotCacheLookupError(err)
 	}
 	return state, nil
 }
 
-// GetHotCachedUniswapV2State returns decoded Uniswap V2 pool state.
+// hotCacheLookupError maps the lookup errors of the hot cache to those
+// returned by the BlockChain accessors.
+func hotCacheLookupError(err error) error {
+	switch {
+	case errors.Is(err, hotcache.ErrNotPopulated):
+		return ErrHotCacheNotPopulated
+	case errors.Is(err, hotcache.ErrNotFound):
+		return ErrHotCacheNotFound
+	}
+	return err
+}
+
+// WaitForHotCacheContract returns the cached state for a specific contract like
+// GetHotCachedContractState, but if the contract is watched and not cached
+// yet, e.g. right after it was added to the watchlist, it waits for the update
+// populating it. Returns ctx.Err() if the context is done first.
+func (bc *BlockChain) WaitForHotCacheContract(ctx context.Context, addr common.Address) (*hotcache.ContractState, error) {
+	// Subscribe before the first lookup so no update is missed in between
+	updates, unsubscribe, err := bc.SubscribeHotCacheUpdates()
+	if err != nil {
+		return nil, err
+	}
+	defer unsubscribe()
+
+	for {
+		state, err := bc.GetHotCachedContractState(addr)
+		if !errors.Is(err, ErrHotCacheNotPopulated) {
+			return state, err
+		}
+		select {
+		case <-updates:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// GetHotCachedUniswapV2State returns decoded Uniswap V2 pool state. Pools of
+// V2 forks sharing the same layout (e.g. SushiSwap) are returned as well.
 // Returns ErrHotCacheNotFound if the contract is not cached.
 // Returns an error if the contract is cached but is not a Uniswap V2 pool.
 func (bc *BlockChain) GetHotCachedUniswapV2State(addr common.Address) (*hotcache.UniswapV2State, error) {
@@ -71,10 +147,13 @@ func (bc *BlockChain) GetHotCachedUniswapV2State(addr common.Address) (*hotcache
 		return nil, err
 	}
 	
-	if state.Type != hotcache.ContractTypeUniswapV2 {
-		return nil, errors.New("contract is not a Uniswap V2 pool")
+	if state.Type != hotcache.ContractTypeUniswapV2 && state.Type != hotcache.ContractTypeSushiSwap {
+		return nil, ErrHotCacheNotUniswapV2
 	}
 	
+	if state.DecodeError != nil {
+		return nil, fmt.Errorf("contract state not decoded: %w", state.DecodeError)
+	}
 	if state.Decoded == nil {
 		return nil, errors.New("contract state not decoded")
 	}
@@ -87,14 +166,193 @@ func (bc *BlockChain) GetHotCachedUniswapV2State(addr common.Address) (*hotcache
 	return v2State, nil
 }
 
+// GetHotCachedReserves returns the reserves of a cached Uniswap V2 (or fork)
+// pool together with the block number of the snapshot they were read from.
+// Returns ErrHotCacheNotPopulated if the pool is watched but not cached yet,
+// ErrHotCacheNotFound if it is not watched and ErrHotCacheNotUniswapV2 if the
+// contract is not a V2 pool.
+func (bc *BlockChain) GetHotCachedReserves(addr common.Address) (reserve0, reserve1 *big.Int, blockNumber uint64, err error) {
+	v2State, number, err := bc.hotCachedUniswapV2State(addr)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return new(big.Int).Set(v2State.Reserve0), new(big.Int).Set(v2State.Reserve1), number, nil
+}
+
+// GetHotCachedPrice returns the price of token0 in terms of token1 (reserve1 /
+// reserve0, in raw token units) of a cached Uniswap V2 (or fork) pool together
+// with the block number of the snapshot it was computed from. Lookup errors
+// are those of GetHotCachedReserves; ErrHotCacheNoLiquidity is returned if
+// either reserve is empty.
+func (bc *BlockChain) GetHotCachedPrice(addr common.Address) (*big.Float, uint64, error) {
+	v2State, number, err := bc.hotCachedUniswapV2State(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if v2State.Reserve0.Sign() == 0 || v2State.Reserve1.Sign() == 0 {
+		return nil, 0, ErrHotCacheNoLiquidity
+	}
+	return v2State.GetPrice(), number, nil
+}
+
+// hotCachedUniswapV2State returns the decoded state of a cached Uniswap V2 (or
+// fork) pool and the number of the block it was read from. The lookup goes
+// through the cache's accessor, so it is counted in the hit and miss
+// statistics like GetHotCachedContractState.
+func (bc *BlockChain) hotCachedUniswapV2State(addr common.Address) (*hotcache.UniswapV2State, uint64, error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return nil, 0, ErrHotCacheDisabled
+	}
+	// Read state and block number from a single snapshot so they match
+	state, _, number, err := bc.hotCache.GetContractStateWithBlock(addr)
+	if err != nil {
+		return nil, 0, hotCacheLookupError(err)
+	}
+	v2State, err := uniswapV2StateOf(state)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v2State, number, nil
+}
+
+// GetStorageFast returns the value of a storage slot at the current head. The
+// value is served from the hot cache when it is up to date and holds the slot
+// (hit is true), and read from the canonical state otherwise.
+func (bc *BlockChain) GetStorageFast(addr common.Address, slot common.Hash) (value common.Hash, hit bool, err error) {
+	head := bc.CurrentBlock()
+	if bc.hotCache != nil && bc.hotCache.IsEnabled() {
+		if value, ok := bc.hotCache.GetStorageAt(head.Hash(), addr, slot); ok {
+			return value, true, nil
+		}
+	}
+	statedb, err := bc.StateAt(head.Root)
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	return statedb.GetState(addr, slot), false, nil
+}
+
+// GetHotCachedTWAP returns the time-weighted average prices of a cached Uniswap
+// V2 (or fork) pool over the last lookbackBlocks blocks, computed from the
+// price cumulatives of the current snapshot and the retained snapshot of the
+// canonical block lookbackBlocks earlier. price0 is token0 in terms of token1.
+// Returns ErrHotCacheNoSnapshot if the historical snapshot has aged out, and
+// ErrHotCacheNoCumulative if either snapshot lacks the price cumulatives, e.g.
+// because the cache skips optional slots.
+func (bc *BlockChain) GetHotCachedTWAP(addr common.Address, lookbackBlocks uint64) (price0, price1 *big.Float, err error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return nil, nil, ErrHotCacheDisabled
+	}
+	current := bc.hotCache.GetSnapshot()
+	if lookbackBlocks == 0 || lookbackBlocks > current.BlockNumber {
+		return nil, nil, fmt.Errorf("invalid TWAP lookback %d at block %d", lookbackBlocks, current.BlockNumber)
+	}
+	number := current.BlockNumber - lookbackBlocks
+	past := bc.hotCache.GetSnapshotByHash(bc.GetCanonicalHash(number))
+	if past == nil {
+		return nil, nil, fmt.Errorf("%w: block %d", ErrHotCacheNoSnapshot, number)
+	}
+	now, err := uniswapV2StateIn(current, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	prev, err := uniswapV2StateIn(past, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if now.OptionalSlotsMissing || prev.OptionalSlotsMissing {
+		return nil, nil, ErrHotCacheNoCumulative
+	}
+	// Advance both cumulatives to their block times, the pair may not have
+	// been touched in either block
+	price0, price1 = now.AtTimestamp(current.BlockTime).TWAP(prev.AtTimestamp(past.BlockTime))
+	return price0, price1, nil
+}
+
+// HotCacheDiff returns the cached contracts whose state changed between the
+// retained snapshots of the canonical blocks fromBlock and toBlock. Returns
+// ErrHotCacheNoSnapshot if either snapshot has aged out.
+func (bc *BlockChain) HotCacheDiff(fromBlock, toBlock uint64) ([]hotcache.ContractChange, error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return nil, ErrHotCacheDisabled
+	}
+	from := bc.hotCache.GetSnapshotByHash(bc.GetCanonicalHash(fromBlock))
+	if from == nil {
+		return nil, fmt.Errorf("%w: block %d", ErrHotCacheNoSnapshot, fromBlock)
+	}
+	to := bc.hotCache.GetSnapshotByHash(bc.GetCanonicalHash(toBlock))
+	if to == nil {
+		return nil, fmt.Errorf("%w: block %d", ErrHotCacheNoSnapshot, toBlock)
+	}
+	return to.Diff(from), nil
+}
+
+// uniswapV2StateIn returns the decoded V2 state of a contract in a snapshot.
+func uniswapV2StateIn(snapshot *hotcache.Snapshot, addr common.Address) (*hotcache.UniswapV2State, error) {
+	state, ok := snapshot.Contracts[addr]
+	if !ok {
+		return nil, ErrHotCacheNotFound
+	}
+	return uniswapV2StateOf(state)
+}
+
+// uniswapV2StateOf returns the decoded state of a cached Uniswap V2 (or fork)
+// pool.
+func uniswapV2StateOf(state *hotcache.ContractState) (*hotcache.UniswapV2State, error) {
+	v2State, ok := state.Decoded.(*hotcache.UniswapV2State)
+	if !ok {
+		if state.DecodeError != nil {
+			return nil, fmt.Errorf("contract state not decoded: %w", state.DecodeError)
+		}
+		return nil, ErrHotCacheNotUniswapV2
+	}
+	return v2State, nil
+}
+
 // GetHotCacheStatistics returns performance statistics for the hot cache.
-func (bc *BlockChain) GetHotCacheStatistics() (hotcache.Statistics, error) {
+func (bc *BlockChain) GetHotCacheStatistics() (hotcache.StatisticsSnapshot, error) {
 	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
-		return hotcache.Statistics{}, ErrHotCacheDisabled
+		return hotcache.StatisticsSnapshot{}, ErrHotCacheDisabled
 	}
 	return bc.hotCache.GetStatistics(), nil
 }
 
+// HotCacheHealth returns the safety signals of the hot cache, with staleness
+// measured against the current head: the cache is stale if it lags by more
+// than maxLag blocks. The report is also returned for a cache disabled by
+// validation failures, so it can serve as a liveness probe.
+func (bc *BlockChain) HotCacheHealth(maxLag uint64) (hotcache.HealthReport, error) {
+	if bc.hotCache == nil {
+		return hotcache.HealthReport{}, ErrHotCacheDisabled
+	}
+	return bc.hotCache.Health(bc.CurrentBlock().Number.Uint64(), maxLag), nil
+}
+
+// GetHotCacheMemoryStats returns an estimate of the memory held by the hot
+// cache snapshots.
+func (bc *BlockChain) GetHotCacheMemoryStats() (hotcache.MemoryStats, error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return hotcache.MemoryStats{}, ErrHotCacheDisabled
+	}
+	return bc.hotCache.MemoryStats(), nil
+}
+
+// WarmupHotCache reads every watched contract from the current head state and
+// publishes the result, so cached reads are served before the next block is
+// imported. It runs on startup unless the cache was restored from its journal;
+// call it again after registering decoders to populate their decoded state.
+func (bc *BlockChain) WarmupHotCache() error {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return ErrHotCacheDisabled
+	}
+	head := bc.CurrentBlock()
+	statedb, err := bc.StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+	return bc.hotCache.Warmup(head, hotcache.NewStateDBReader(statedb))
+}
+
 // RegisterHotCacheDecoder registers a decoder for a specific contract address.
 // This allows the cache to decode contract-specific state automatically.
 func (bc *BlockChain) RegisterHotCacheDecoder(addr common.Address, decoder hotcache.ContractDecoder) error {
@@ -105,3 +363,106 @@ func (bc *BlockChain) RegisterHotCacheDecoder(addr common.Address, decoder hotca
 	return nil
 }
 
+
+// GetHotCacheRequiredSlots returns the storage slots the hot cache reads for a
+// contract through its registered decoder, selected by the contract's code at
+// the current head for proxies. Returns ErrHotCacheNoDecoder if no decoder
+// applies.
+func (bc *BlockChain) GetHotCacheRequiredSlots(addr common.Address) ([]common.Hash, error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return nil, ErrHotCacheDisabled
+	}
+	statedb, err := bc.StateAt(bc.CurrentBlock().Root)
+	if err != nil {
+		return nil, err
+	}
+	slots, ok := bc.hotCache.RequiredSlots(addr, hotcache.NewStateDBReader(statedb))
+	if !ok {
+		return nil, ErrHotCacheNoDecoder
+	}
+	return slots, nil
+}
+
+// AddHotCacheWatch adds a contract to the hot cache watchlist at runtime.
+// The contract is populated on the next block import.
+func (bc *BlockChain) AddHotCacheWatch(addr common.Address) error {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return ErrHotCacheDisabled
+	}
+	return bc.hotCache.AddToWatchlist(addr)
+}
+
+// HotCacheWatchlist returns the watched contract addresses, including those
+// added at runtime, sorted for stable output.
+func (bc *BlockChain) HotCacheWatchlist() ([]common.Address, error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return nil, ErrHotCacheDisabled
+	}
+	return bc.hotCache.Watchlist(), nil
+}
+
+// RemoveHotCacheWatch removes a contract and its decoder from the hot cache.
+func (bc *BlockChain) RemoveHotCacheWatch(addr common.Address) error {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return ErrHotCacheDisabled
+	}
+	bc.hotCache.RemoveFromWatchlist(addr)
+	return nil
+}
+
+// HotCacheSnapshotJSON returns the current hot cache snapshot encoded as JSON.
+func (bc *BlockChain) HotCacheSnapshotJSON() ([]byte, error) {
+	if bc.hotCache == nil || !bc.hotCache.IsEnabled() {
+		return nil, ErrHotCacheDisabled
+	}
+	return bc.hotCache.SnapshotJSON()
+}
+
+// loadHotCacheJournal restores the hot cache from the configured journal if it
+// was persisted at the current head. Stale or unreadable journals are ignored
+// and the cache is rebuilt as blocks are imported.
+func (bc *BlockChain) loadHotCacheJournal() {
+	if bc.cfg.HotCacheJournal == "" || !bc.hotCache.IsEnabled() {
+		return
+	}
+	f, err := os.Open(bc.cfg.HotCacheJournal)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to open hot cache journal", "path", bc.cfg.HotCacheJournal, "err", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if err := bc.hotCache.LoadFrom(f, bc.CurrentBlock()); err != nil {
+		log.Warn("Discarding hot cache journal", "path", bc.cfg.HotCacheJournal, "err", err)
+	}
+}
+
+// saveHotCacheJournal persists the hot cache to the configured journal. The
+// file is replaced atomically so a crash never leaves a truncated journal.
+func (bc *BlockChain) saveHotCacheJournal() {
+	if bc.cfg.HotCacheJournal == "" || !bc.hotCache.IsEnabled() {
+		return
+	}
+	tmp := bc.cfg.HotCacheJournal + ".new"
+	f, err := os.Create(tmp)
+	if err != nil {
+		log.Warn("Failed to create hot cache journal", "path", tmp, "err", err)
+		return
+	}
+	if err := bc.hotCache.SaveTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		log.Warn("Failed to write hot cache journal", "path", tmp, "err", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		log.Warn("Failed to write hot cache journal", "path", tmp, "err", err)
+		return
+	}
+	if err := os.Rename(tmp, bc.cfg.HotCacheJournal); err != nil {
+		log.Warn("Failed to replace hot cache journal", "path", bc.cfg.HotCacheJournal, "err", err)
+	}
+}
@@ -0,0 +1,604 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/hotcache"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newHotCacheTestBlockChain creates a chain of the given length with the hot
+// cache enabled, watching a Uniswap V2 pair with seeded reserves and an
+// additional contract without a decoder.
+func newHotCacheTestBlockChain(t *testing.T, pool, other common.Address, blocks int) *BlockChain {
+	config := DefaultConfig()
+	config.EnableHotCache = true
+	config.HotCacheWatchlist = []common.Address{pool, other}
+	return newHotCacheTestBlockChainWithConfig(t, rawdb.NewMemoryDatabase(), config, pool, blocks)
+}
+
+// newHotCacheTestBlockChainWithConfig is newHotCacheTestBlockChain with an
+// explicit database and configuration.
+func newHotCacheTestBlockChainWithConfig(t *testing.T, db ethdb.Database, config *BlockChainConfig, pool common.Address, blocks int) *BlockChain {
+	var (
+		packed = new(big.Int).Or(big.NewInt(1000000), new(big.Int).Lsh(big.NewInt(500), 112))
+		gspec  = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				pool: {
+					Balance: big.NewInt(1),
+					Code:    []byte{0x00},
+					Storage: map[common.Hash]common.Hash{
+						common.BigToHash(big.NewInt(8)): common.BigToHash(packed),
+					},
+				},
+			},
+		}
+		engine = ethash.NewFaker()
+	)
+	_, chainBlocks, _ := GenerateChainWithGenesis(gspec, engine, blocks, nil)
+
+	chain, err := NewBlockChain(db, gspec, engine, config)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if err := chain.RegisterHotCacheDecoder(pool, &hotcache.UniswapV2Decoder{}); err != nil {
+		t.Fatalf("failed to register decoder: %v", err)
+	}
+	if n, err := chain.InsertChain(chainBlocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	return chain
+}
+
+func TestHotCacheReorgUpdatesNewHeadOnce(t *testing.T) {
+	var (
+		pool   = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		packed = new(big.Int).Or(big.NewInt(1000000), new(big.Int).Lsh(big.NewInt(500), 112))
+		gspec  = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				pool: {
+					Balance: big.NewInt(1),
+					Code:    []byte{0x00},
+					Storage: map[common.Hash]common.Hash{
+						common.BigToHash(big.NewInt(8)): common.BigToHash(packed),
+					},
+				},
+			},
+		}
+		engine = ethash.NewFaker()
+	)
+	_, canonical, _ := GenerateChainWithGenesis(gspec, engine, 3, nil)
+	_, fork, _ := GenerateChainWithGenesis(gspec, engine, 5, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	config := DefaultConfig()
+	config.EnableHotCache = true
+	config.HotCacheWatchlist = []common.Address{pool}
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), gspec, engine, config)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(canonical); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	before := chain.HotCache().GetStatistics()
+	if n, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("block %d: failed to insert fork: %v", n, err)
+	}
+	stats := chain.HotCache().GetStatistics()
+	if head := chain.HotCache().GetSnapshot(); head.BlockHash != fork[len(fork)-1].Hash() {
+		t.Fatalf("expected snapshot at the fork head, got block %d", head.BlockNumber)
+	}
+	// Every block of the fork is built exactly once
+	if updates := stats.Updates - before.Updates; updates != uint64(len(fork)) {
+		t.Errorf("expected %d updates, got %d", len(fork), updates)
+	}
+	if reorgs := stats.ReorgCount - before.ReorgCount; reorgs != 1 {
+		t.Errorf("expected 1 reorg, got %d", reorgs)
+	}
+}
+
+func TestGetHotCachedReserves(t *testing.T) {
+	var (
+		pool    = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token   = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		missing = common.HexToAddress("0x1")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 3)
+	defer chain.Stop()
+
+	reserve0, reserve1, number, err := chain.GetHotCachedReserves(pool)
+	if err != nil {
+		t.Fatalf("failed to get reserves: %v", err)
+	}
+	if reserve0.Int64() != 1000000 || reserve1.Int64() != 500 {
+		t.Errorf("unexpected reserves: %s/%s", reserve0, reserve1)
+	}
+	if number != 3 {
+		t.Errorf("expected snapshot block 3, got %d", number)
+	}
+	if _, _, _, err := chain.GetHotCachedReserves(missing); !errors.Is(err, ErrHotCacheNotFound) {
+		t.Errorf("expected ErrHotCacheNotFound, got %v", err)
+	}
+	if _, _, _, err := chain.GetHotCachedReserves(token); !errors.Is(err, ErrHotCacheNotUniswapV2) {
+		t.Errorf("expected ErrHotCacheNotUniswapV2, got %v", err)
+	}
+}
+
+func TestGetHotCachedReservesCountsLookups(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		added = common.HexToAddress("0x2")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 3)
+	defer chain.Stop()
+
+	before := chain.HotCache().GetStatistics()
+	if _, _, _, err := chain.GetHotCachedReserves(pool); err != nil {
+		t.Fatalf("failed to get reserves: %v", err)
+	}
+	if _, _, err := chain.GetHotCachedPrice(pool); err != nil {
+		t.Fatalf("failed to get price: %v", err)
+	}
+	if hits := chain.HotCache().GetStatistics().Hits - before.Hits; hits != 2 {
+		t.Errorf("expected 2 counted hits, got %d", hits)
+	}
+
+	// A pool watched since the last block reports the same error as the
+	// other accessors, and counts as a miss
+	if err := chain.AddHotCacheWatch(added); err != nil {
+		t.Fatalf("failed to watch contract: %v", err)
+	}
+	if _, _, _, err := chain.GetHotCachedReserves(added); !errors.Is(err, ErrHotCacheNotPopulated) {
+		t.Errorf("expected ErrHotCacheNotPopulated, got %v", err)
+	}
+	if _, _, err := chain.GetHotCachedPrice(added); !errors.Is(err, ErrHotCacheNotPopulated) {
+		t.Errorf("expected ErrHotCacheNotPopulated, got %v", err)
+	}
+	if misses := chain.HotCache().GetStatistics().Misses - before.Misses; misses != 2 {
+		t.Errorf("expected 2 counted misses, got %d", misses)
+	}
+}
+
+func TestGetHotCachedTWAP(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 4)
+	defer chain.Stop()
+
+	// Reserves never change, so the TWAP equals the spot price
+	price0, price1, err := chain.GetHotCachedTWAP(pool, 3)
+	if err != nil {
+		t.Fatalf("failed to get TWAP: %v", err)
+	}
+	if p, _ := price0.Float64(); p < 0.000499 || p > 0.000501 {
+		t.Errorf("expected price0 ~0.0005, got %v", price0)
+	}
+	if p, _ := price1.Float64(); p < 1999 || p > 2001 {
+		t.Errorf("expected price1 ~2000, got %v", price1)
+	}
+	if _, _, err := chain.GetHotCachedTWAP(pool, 5); err == nil {
+		t.Error("expected error for lookback beyond the chain")
+	}
+	if _, _, err := chain.GetHotCachedTWAP(token, 1); !errors.Is(err, ErrHotCacheNotUniswapV2) {
+		t.Errorf("expected ErrHotCacheNotUniswapV2, got %v", err)
+	}
+}
+
+func TestGetHotCachedTWAPSnapshotAgedOut(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+
+	config := DefaultConfig()
+	config.EnableHotCache = true
+	config.HotCacheWatchlist = []common.Address{pool}
+	config.HotCacheMaxSnapshots = 2
+	chain := newHotCacheTestBlockChainWithConfig(t, rawdb.NewMemoryDatabase(), config, pool, 6)
+	defer chain.Stop()
+
+	if _, _, err := chain.GetHotCachedTWAP(pool, 1); err != nil {
+		t.Errorf("failed to get TWAP within retention: %v", err)
+	}
+	if _, _, err := chain.GetHotCachedTWAP(pool, 5); !errors.Is(err, ErrHotCacheNoSnapshot) {
+		t.Errorf("expected ErrHotCacheNoSnapshot, got %v", err)
+	}
+}
+
+func TestGetHotCachedTWAPSkipOptionalSlots(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+
+	config := DefaultConfig()
+	config.EnableHotCache = true
+	config.HotCacheWatchlist = []common.Address{pool}
+	config.HotCacheSkipOptionalSlots = true
+	chain := newHotCacheTestBlockChainWithConfig(t, rawdb.NewMemoryDatabase(), config, pool, 3)
+	defer chain.Stop()
+
+	// The reserves are still served, but no TWAP without the cumulatives
+	if _, _, _, err := chain.GetHotCachedReserves(pool); err != nil {
+		t.Errorf("failed to get reserves: %v", err)
+	}
+	if _, _, err := chain.GetHotCachedTWAP(pool, 2); !errors.Is(err, ErrHotCacheNoCumulative) {
+		t.Errorf("expected ErrHotCacheNoCumulative, got %v", err)
+	}
+}
+
+func TestHotCacheJournal(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+
+	db := rawdb.NewMemoryDatabase()
+	config := DefaultConfig()
+	config.EnableHotCache = true
+	config.HotCacheWatchlist = []common.Address{pool}
+	config.HotCacheJournal = filepath.Join(t.TempDir(), "hotcache.journal")
+
+	chain := newHotCacheTestBlockChainWithConfig(t, db, config, pool, 3)
+	chain.Stop()
+
+	// Reopen the same database without importing blocks: the cache is served
+	// from the journal instead of starting empty
+	config.HotCacheWatchlist = nil
+	chain, err := NewBlockChain(db, nil, ethash.NewFaker(), config)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+
+	reserve0, reserve1, number, err := chain.GetHotCachedReserves(pool)
+	if err != nil {
+		t.Fatalf("failed to get reserves from journal: %v", err)
+	}
+	if reserve0.Int64() != 1000000 || reserve1.Int64() != 500 || number != 3 {
+		t.Errorf("unexpected restored reserves %s/%s at block %d", reserve0, reserve1, number)
+	}
+	if !chain.HotCache().IsWatched(pool) {
+		t.Error("watchlist not restored from journal")
+	}
+}
+
+func TestWarmupHotCache(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+
+	config := DefaultConfig()
+	config.EnableHotCache = true
+	config.HotCacheWatchlist = []common.Address{pool}
+	chain := newHotCacheTestBlockChainWithConfig(t, rawdb.NewMemoryDatabase(), config, pool, 0)
+	defer chain.Stop()
+
+	// Startup warmed the cache before the decoder was registered, so only the
+	// raw contract is present until it is warmed up again
+	if _, err := chain.GetHotCachedContractState(pool); err != nil {
+		t.Fatalf("contract not cached after startup: %v", err)
+	}
+	if _, _, _, err := chain.GetHotCachedReserves(pool); !errors.Is(err, ErrHotCacheNotUniswapV2) {
+		t.Errorf("expected undecoded contract before warmup, got %v", err)
+	}
+	if err := chain.WarmupHotCache(); err != nil {
+		t.Fatalf("failed to warm up hot cache: %v", err)
+	}
+	reserve0, reserve1, number, err := chain.GetHotCachedReserves(pool)
+	if err != nil {
+		t.Fatalf("failed to get reserves after warmup: %v", err)
+	}
+	if reserve0.Int64() != 1000000 || reserve1.Int64() != 500 || number != 0 {
+		t.Errorf("unexpected warmed up reserves %s/%s at block %d", reserve0, reserve1, number)
+	}
+}
+
+func TestIsHotCacheStale(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 2)
+	defer chain.Stop()
+
+	if chain.IsHotCacheStale(0) {
+		t.Error("expected cache at head to be fresh")
+	}
+	disabled := &BlockChain{}
+	if !disabled.IsHotCacheStale(100) {
+		t.Error("expected disabled cache to be stale")
+	}
+}
+
+func TestHotCacheWatchlist(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		added = common.HexToAddress("0x1")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 1)
+	defer chain.Stop()
+
+	if err := chain.AddHotCacheWatch(added); err != nil {
+		t.Fatalf("failed to add watch: %v", err)
+	}
+	watchlist, err := chain.HotCacheWatchlist()
+	if err != nil {
+		t.Fatalf("failed to get watchlist: %v", err)
+	}
+	// Sorted by address
+	want := []common.Address{added, token, pool}
+	if !slices.Equal(watchlist, want) {
+		t.Errorf("watchlist mismatch: have %v, want %v", watchlist, want)
+	}
+	disabled := &BlockChain{}
+	if _, err := disabled.HotCacheWatchlist(); !errors.Is(err, ErrHotCacheDisabled) {
+		t.Errorf("expected ErrHotCacheDisabled, got %v", err)
+	}
+}
+
+func TestGetStorageFast(t *testing.T) {
+	var (
+		pool     = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		reserves = common.BigToHash(big.NewInt(8))
+		packed   = common.BigToHash(new(big.Int).Or(big.NewInt(1000000), new(big.Int).Lsh(big.NewInt(500), 112)))
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 2)
+	defer chain.Stop()
+
+	// Cached slot
+	value, hit, err := chain.GetStorageFast(pool, reserves)
+	if err != nil || !hit || value != packed {
+		t.Errorf("expected cached reserves, got %x (hit %v, err %v)", value, hit, err)
+	}
+	// Uncached slot of a watched contract falls back to the state
+	value, hit, err = chain.GetStorageFast(pool, common.BigToHash(big.NewInt(100)))
+	if err != nil || hit || value != (common.Hash{}) {
+		t.Errorf("expected state fallback, got %x (hit %v, err %v)", value, hit, err)
+	}
+	stats, _ := chain.GetHotCacheStatistics()
+	if stats.Fallbacks != 1 {
+		t.Errorf("expected 1 fallback, got %d", stats.Fallbacks)
+	}
+
+	// A disabled cache always reads the state
+	disabled := newHotCacheTestBlockChainWithConfig(t, rawdb.NewMemoryDatabase(), DefaultConfig(), pool, 2)
+	defer disabled.Stop()
+
+	value, hit, err = disabled.GetStorageFast(pool, reserves)
+	if err != nil || hit || value != packed {
+		t.Errorf("expected state read with disabled cache, got %x (hit %v, err %v)", value, hit, err)
+	}
+}
+
+func TestGetHotCachedPrice(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 2)
+	defer chain.Stop()
+
+	price, number, err := chain.GetHotCachedPrice(pool)
+	if err != nil {
+		t.Fatalf("failed to get price: %v", err)
+	}
+	// 500 / 1000000
+	if have, _ := price.Float64(); have != 0.0005 {
+		t.Errorf("expected price 0.0005, got %v", have)
+	}
+	if number != 2 {
+		t.Errorf("expected snapshot block 2, got %d", number)
+	}
+	if _, _, err := chain.GetHotCachedPrice(token); !errors.Is(err, ErrHotCacheNotUniswapV2) {
+		t.Errorf("expected ErrHotCacheNotUniswapV2, got %v", err)
+	}
+	// A pair without reserves cannot be priced
+	if err := chain.RegisterHotCacheDecoder(token, &hotcache.UniswapV2Decoder{}); err != nil {
+		t.Fatalf("failed to register decoder: %v", err)
+	}
+	if err := chain.WarmupHotCache(); err != nil {
+		t.Fatalf("failed to warm up: %v", err)
+	}
+	if _, _, err := chain.GetHotCachedPrice(token); !errors.Is(err, ErrHotCacheNoLiquidity) {
+		t.Errorf("expected ErrHotCacheNoLiquidity, got %v", err)
+	}
+}
+
+func TestHotCacheDiff(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 3)
+	defer chain.Stop()
+
+	// The pool's storage is constant, so consecutive blocks do not differ
+	changes, err := chain.HotCacheDiff(1, 3)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+	if _, err := chain.HotCacheDiff(1, 10); !errors.Is(err, ErrHotCacheNoSnapshot) {
+		t.Errorf("expected ErrHotCacheNoSnapshot, got %v", err)
+	}
+}
+
+func TestHotCacheReader(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 1)
+	defer chain.Stop()
+
+	reader := chain.HotCacheReader()
+	if reader == nil || !reader.IsWatched(pool) {
+		t.Fatal("expected reader watching the pool")
+	}
+	disabled := &BlockChain{}
+	if reader := disabled.HotCacheReader(); reader != nil {
+		t.Errorf("expected nil reader for a disabled cache, got %v", reader)
+	}
+}
+
+func TestGetHotCachedContractStateNotPopulated(t *testing.T) {
+	var (
+		pool    = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token   = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		added   = common.HexToAddress("0x1")
+		missing = common.HexToAddress("0x2")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 1)
+	defer chain.Stop()
+
+	// Watched after the last import, so no snapshot holds it yet
+	chain.HotCache().AddToWatchlist(added)
+
+	if _, err := chain.GetHotCachedContractState(pool); err != nil {
+		t.Errorf("expected cached pool, got %v", err)
+	}
+	if _, err := chain.GetHotCachedContractState(added); !errors.Is(err, ErrHotCacheNotPopulated) {
+		t.Errorf("expected ErrHotCacheNotPopulated, got %v", err)
+	}
+	if _, err := chain.GetHotCachedContractState(missing); !errors.Is(err, ErrHotCacheNotFound) {
+		t.Errorf("expected ErrHotCacheNotFound, got %v", err)
+	}
+}
+
+func TestGetHotCacheMemoryStats(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 2)
+	defer chain.Stop()
+
+	stats, err := chain.GetHotCacheMemoryStats()
+	if err != nil {
+		t.Fatalf("failed to get memory stats: %v", err)
+	}
+	if stats.Contracts != 2 || stats.CurrentBytes == 0 || stats.RetainedBytes < stats.CurrentBytes {
+		t.Errorf("unexpected memory stats: %+v", stats)
+	}
+	if _, err := (&BlockChain{}).GetHotCacheMemoryStats(); !errors.Is(err, ErrHotCacheDisabled) {
+		t.Errorf("expected ErrHotCacheDisabled, got %v", err)
+	}
+}
+
+func TestHotCacheHealth(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 2)
+	defer chain.Stop()
+
+	report, err := chain.HotCacheHealth(0)
+	if err != nil {
+		t.Fatalf("failed to get health report: %v", err)
+	}
+	if !report.Enabled || !report.Healthy || report.Stale || report.HeadNumber != 2 || report.BlockNumber != 2 || report.Contracts != 2 {
+		t.Errorf("unexpected health report: %+v", report)
+	}
+	if _, err := (&BlockChain{}).HotCacheHealth(0); !errors.Is(err, ErrHotCacheDisabled) {
+		t.Errorf("expected ErrHotCacheDisabled, got %v", err)
+	}
+}
+
+func TestGetHotCacheRequiredSlots(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 2)
+	defer chain.Stop()
+
+	slots, err := chain.GetHotCacheRequiredSlots(pool)
+	if err != nil {
+		t.Fatalf("failed to get required slots: %v", err)
+	}
+	want := []common.Hash{
+		common.BigToHash(big.NewInt(6)),  // token0
+		common.BigToHash(big.NewInt(7)),  // token1
+		common.BigToHash(big.NewInt(8)),  // reserves
+		common.BigToHash(big.NewInt(9)),  // price0CumulativeLast
+		common.BigToHash(big.NewInt(10)), // price1CumulativeLast
+		common.BigToHash(big.NewInt(11)), // kLast
+	}
+	if !slices.Equal(slots, want) {
+		t.Errorf("unexpected required slots: %v", slots)
+	}
+	if _, err := chain.GetHotCacheRequiredSlots(token); !errors.Is(err, ErrHotCacheNoDecoder) {
+		t.Errorf("expected ErrHotCacheNoDecoder, got %v", err)
+	}
+}
+
+func TestWaitForHotCacheContract(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		added = common.HexToAddress("0x1")
+	)
+	chain := newHotCacheTestBlockChain(t, pool, token, 1)
+	defer chain.Stop()
+
+	// Cached contracts are returned right away
+	if state, err := chain.WaitForHotCacheContract(context.Background(), pool); err != nil || state.Address != pool {
+		t.Fatalf("expected cached pool, got %v (err %v)", state, err)
+	}
+	if err := chain.AddHotCacheWatch(added); err != nil {
+		t.Fatalf("failed to add watch: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := chain.WaitForHotCacheContract(ctx, added); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded before any update, got %v", err)
+	}
+
+	// An update shortly after populates the new contract
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		chain.WarmupHotCache()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	state, err := chain.WaitForHotCacheContract(ctx, added)
+	if err != nil {
+		t.Fatalf("failed to wait for contract: %v", err)
+	}
+	if state.Address != added {
+		t.Errorf("unexpected contract %s", state.Address.Hex())
+	}
+	disabled := &BlockChain{}
+	if _, err := disabled.WaitForHotCacheContract(context.Background(), added); !errors.Is(err, ErrHotCacheDisabled) {
+		t.Errorf("expected ErrHotCacheDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Balancer V2 pools hold no balances themselves, the Vault accounts for them.
+// Weighted pools with more than two tokens use the Vault's MinimalSwapInfo
+// specialization:
+// _minimalSwapInfoPoolsBalances: mapping(bytes32 poolId => mapping(IERC20 => bytes32))
+//
+// The balance of a token lives at
+// keccak256(pad32(token) . keccak256(poolId . pad32(balancesSlot)))
+// and is packed, from the least significant bit:
+// cash (uint112), managed (uint112), lastChangeBlock (uint32)
+// The pool's total balance of the token is cash + managed.
+//
+// Normalized weights are immutables of the pool contract and live in its
+// bytecode, so they must be supplied when constructing the decoder.
+
+var balancerMask112 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 112), big.NewInt(1))
+
+//go:generate go run github.com/fjl/gencodec -type BalancerState -field-override balancerStateMarshaling -out gen_balancer_json.go
+
+// BalancerState represents the decoded state of a Balancer weighted pool.
+type BalancerState struct {
+	Tokens   []common.Address `json:"tokens"`
+	Weights  []*big.Int       `json:"weights"`  // normalized, 18 decimals
+	Balances []*big.Int       `json:"balances"` // cash + managed
+}
+
+// balancerStateMarshaling renders big integers as decimal strings.
+type balancerStateMarshaling struct {
+	Weights  []*math.Decimal256
+	Balances []*math.Decimal256
+}
+
+// String returns a human-readable representation of the pool state.
+func (s *BalancerState) String() string {
+	return fmt.Sprintf("Balancer{tokens: %d, weights: %v, balances: %v}", len(s.Tokens), s.Weights, s.Balances)
+}
+
+// Copy returns a deep copy of the pool state.
+func (s *BalancerState) Copy() *BalancerState {
+	cpy := &BalancerState{
+		Tokens:   append([]common.Address(nil), s.Tokens...),
+		Weights:  make([]*big.Int, len(s.Weights)),
+		Balances: make([]*big.Int, len(s.Balances)),
+	}
+	for i, weight := range s.Weights {
+		cpy.Weights[i] = copyBig(weight)
+	}
+	for i, balance := range s.Balances {
+		cpy.Balances[i] = copyBig(balance)
+	}
+	return cpy
+}
+
+// SpotPrice returns the price of token i in terms of token j, excluding fees:
+// price = (balance_j / weight_j) / (balance_i / weight_i)
+// Balances are in raw token units. Zero is returned for out of range indices
+// or empty balances.
+func (s *BalancerState) SpotPrice(i, j int) *big.Float {
+	if i < 0 || j < 0 || i >= len(s.Balances) || j >= len(s.Balances) {
+		return big.NewFloat(0)
+	}
+	if s.Balances[i].Sign() == 0 || s.Balances[j].Sign() == 0 || s.Weights[i].Sign() == 0 || s.Weights[j].Sign() == 0 {
+		return big.NewFloat(0)
+	}
+	// (B_j * W_i) / (B_i * W_j)
+	num := new(big.Float).SetPrec(256).SetInt(new(big.Int).Mul(s.Balances[j], s.Weights[i]))
+	den := new(big.Float).SetPrec(256).SetInt(new(big.Int).Mul(s.Balances[i], s.Weights[j]))
+	return num.Quo(num, den)
+}
+
+// BalancerDecoder decodes a Balancer V2 weighted pool from the Vault's storage.
+// It should be registered on the pool address; its slots are read from the
+// Vault.
+type BalancerDecoder struct {
+	vault   common.Address
+	poolID  common.Hash
+	tokens  []common.Address
+	weights []*big.Int
+	slots   []common.Hash // balance slot of each token
+}
+
+// NewBalancerDecoder creates a decoder for the pool with the given id whose
+// balances are held in the Vault's _minimalSwapInfoPoolsBalances mapping at
+// balancesSlot. tokens and weights must be in the pool's token order.
+func NewBalancerDecoder(vault common.Address, poolID, balancesSlot common.Hash, tokens []common.Address, weights []*big.Int) (*BalancerDecoder, error) {
+	if len(tokens) != len(weights) {
+		return nil, fmt.Errorf("token and weight count mismatch: %d != %d", len(tokens), len(weights))
+	}
+	poolSlot := MappingSlot(poolID[:], balancesSlot)
+
+	slots := make([]common.Hash, len(tokens))
+	for i, token := range tokens {
+		slots[i] = MappingSlot(common.LeftPadBytes(token.Bytes(), 32), poolSlot)
+	}
+	return &BalancerDecoder{
+		vault:   vault,
+		poolID:  poolID,
+		tokens:  tokens,
+		weights: weights,
+		slots:   slots,
+	}, nil
+}
+
+// Type returns the contract type.
+func (d *BalancerDecoder) Type() ContractType {
+	return ContractTypeBalancer
+}
+
+// StorageAddress returns the Vault, which holds the pool's balances.
+func (d *BalancerDecoder) StorageAddress() common.Address {
+	return d.vault
+}
+
+// RequiredSlots returns the Vault storage slots of the pool's token balances.
+func (d *BalancerDecoder) RequiredSlots() []common.Hash {
+	return d.slots
+}
+
+// Decode decodes raw Vault storage slots into BalancerState.
+func (d *BalancerDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	state := &BalancerState{
+		Tokens:   make([]common.Address, len(d.tokens)),
+		Weights:  make([]*big.Int, len(d.weights)),
+		Balances: make([]*big.Int, len(d.tokens)),
+	}
+	copy(state.Tokens, d.tokens)
+	for i, weight := range d.weights {
+		state.Weights[i] = new(big.Int).Set(weight)
+	}
+	for i, slot := range d.slots {
+		value, ok := slots[slot]
+		if !ok {
+			return nil, fmt.Errorf("%w: balance of token %s", ErrMissingSlot, d.tokens[i].Hex())
+		}
+		packed := value.Big()
+		cash := new(big.Int).And(packed, balancerMask112)
+		managed := new(big.Int).Rsh(packed, 112)
+		managed.And(managed, balancerMask112)
+		state.Balances[i] = cash.Add(cash, managed)
+	}
+	return state, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	balancerTestVault        = common.HexToAddress("0xBA12222222228d8Ba445958a75a0704d566BF2C8")
+	balancerTestPool         = common.HexToAddress("0x5c6Ee304399DBdB9C8Ef030aB642B10820DB8F56")
+	balancerTestPoolID       = common.HexToHash("0x5c6ee304399dbdb9c8ef030ab642b10820db8f56000200000000000000000014")
+	balancerTestBalancesSlot = common.BigToHash(big.NewInt(9))
+	balancerTestTokens       = []common.Address{
+		common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+	}
+)
+
+// balancerPackedBalance packs a Vault balance with the given cash, managed
+// amount and last change block.
+func balancerPackedBalance(cash, managed *big.Int, block uint32) common.Hash {
+	packed := new(big.Int).Lsh(big.NewInt(int64(block)), 224)
+	packed.Or(packed, new(big.Int).Lsh(managed, 112))
+	packed.Or(packed, cash)
+	return common.BigToHash(packed)
+}
+
+func newBalancerTestDecoder(t *testing.T) *BalancerDecoder {
+	weights := []*big.Int{big.NewInt(8e17), big.NewInt(2e17)} // 80/20 pool
+	decoder, err := NewBalancerDecoder(balancerTestVault, balancerTestPoolID, balancerTestBalancesSlot, balancerTestTokens, weights)
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	return decoder
+}
+
+func TestBalancerDecode(t *testing.T) {
+	decoder := newBalancerTestDecoder(t)
+	if decoder.Type() != ContractTypeBalancer || decoder.StorageAddress() != balancerTestVault {
+		t.Fatalf("Unexpected decoder type %v or storage %s", decoder.Type(), decoder.StorageAddress().Hex())
+	}
+
+	// The balance slot is nested in the poolId mapping, keyed by token
+	poolSlot := crypto.Keccak256(balancerTestPoolID[:], balancerTestBalancesSlot[:])
+	want := crypto.Keccak256Hash(common.LeftPadBytes(balancerTestTokens[0].Bytes(), 32), poolSlot)
+	slots := decoder.RequiredSlots()
+	if len(slots) != 2 || slots[0] != want {
+		t.Fatalf("Unexpected required slots %v, want first %s", slots, want.Hex())
+	}
+
+	weth, _ := new(big.Int).SetString("1000000000000000000000", 10) // 1000 WETH
+	decoded, err := decoder.Decode(map[common.Hash]common.Hash{
+		slots[0]: balancerPackedBalance(weth, new(big.Int), 17000000),
+		slots[1]: balancerPackedBalance(big.NewInt(400000e6), big.NewInt(100000e6), 17000001), // 500k USDC, partly managed
+	})
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	state := decoded.(*BalancerState)
+	if state.Balances[0].Cmp(weth) != 0 || state.Balances[1].Int64() != 500000e6 {
+		t.Errorf("Unexpected balances %v", state.Balances)
+	}
+	if state.Weights[0].Int64() != 8e17 || state.Weights[1].Int64() != 2e17 || state.Tokens[1] != balancerTestTokens[1] {
+		t.Errorf("Unexpected pool metadata %v", state)
+	}
+
+	// WETH in USDC units: (500k/0.2) / (1000/0.8) = 2000 USDC per WETH, in raw units
+	price, _ := state.SpotPrice(0, 1).Float64()
+	if price < 1.999e-9 || price > 2.001e-9 {
+		t.Errorf("Expected spot price ~2e-9, got %v", price)
+	}
+	inverse, _ := state.SpotPrice(1, 0).Float64()
+	if inverse < 4.999e8 || inverse > 5.001e8 {
+		t.Errorf("Expected inverse spot price ~5e8, got %v", inverse)
+	}
+	if state.SpotPrice(0, 2).Sign() != 0 {
+		t.Error("Expected zero spot price for out of range token")
+	}
+
+	if _, err := decoder.Decode(map[common.Hash]common.Hash{slots[0]: {}}); err == nil {
+		t.Error("Expected error for missing balance slot")
+	}
+	if _, err := NewBalancerDecoder(balancerTestVault, balancerTestPoolID, balancerTestBalancesSlot, balancerTestTokens, nil); err == nil {
+		t.Error("Expected error for mismatched weights")
+	}
+}
+
+func TestBalancerReadsVaultStorage(t *testing.T) {
+	decoder := newBalancerTestDecoder(t)
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{balancerTestPool}})
+	cache.RegisterDecoder(balancerTestPool, decoder)
+
+	reader := newMockStateReader()
+	slots := decoder.RequiredSlots()
+	reader.setState(balancerTestVault, slots[0], balancerPackedBalance(big.NewInt(1000), new(big.Int), 1))
+	reader.setState(balancerTestVault, slots[1], balancerPackedBalance(big.NewInt(2000), new(big.Int), 1))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(balancerTestPool)
+	if err != nil {
+		t.Fatalf("Pool not cached: %v", err)
+	}
+	balancer := state.Decoded.(*BalancerState)
+	if balancer.Balances[0].Int64() != 1000 || balancer.Balances[1].Int64() != 2000 {
+		t.Errorf("Unexpected balances %v", balancer.Balances)
+	}
+	if err := cache.Validate(reader); err != nil {
+		t.Errorf("Expected validation against the Vault to pass: %v", err)
+	}
+}
+
+// TestBalancerWatchSlots checks that custom slots of a pool are read from the
+// pool itself rather than from the Vault holding its balances.
+func TestBalancerWatchSlots(t *testing.T) {
+	decoder := newBalancerTestDecoder(t)
+	cache := New(Config{Enabled: true, ShadowMode: true})
+	cache.RegisterDecoder(balancerTestPool, decoder)
+	swapFee := common.BigToHash(big.NewInt(7))
+	if err := cache.WatchSlots(balancerTestPool, []common.Hash{swapFee}); err != nil {
+		t.Fatalf("WatchSlots failed: %v", err)
+	}
+
+	reader := newMockStateReader()
+	slots := decoder.RequiredSlots()
+	reader.setState(balancerTestVault, slots[0], balancerPackedBalance(big.NewInt(1000), new(big.Int), 1))
+	reader.setState(balancerTestVault, slots[1], balancerPackedBalance(big.NewInt(2000), new(big.Int), 1))
+	reader.setState(balancerTestVault, swapFee, common.HexToHash("0xbad"))
+	reader.setState(balancerTestPool, swapFee, common.HexToHash("0x3e8"))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if value, err := cache.GetRawSlot(balancerTestPool, swapFee); err != nil || value != common.HexToHash("0x3e8") {
+		t.Errorf("Expected the custom slot of the pool, got %s (%v)", value.Hex(), err)
+	}
+	state, err := cache.GetContractState(balancerTestPool)
+	if err != nil {
+		t.Fatalf("Pool not cached: %v", err)
+	}
+	if balances := state.Decoded.(*BalancerState).Balances; balances[0].Int64() != 1000 || balances[1].Int64() != 2000 {
+		t.Errorf("Unexpected balances %v", balances)
+	}
+	if err := cache.Validate(reader); err != nil {
+		t.Errorf("Expected validation to pass: %v", err)
+	}
+}
@@ -22,8 +22,15 @@ package hotcache
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"math/bits"
+	"reflect"
+	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
@@ -32,7 +39,23 @@ import (
 var (
 	ErrNotFound        = errors.New("contract not in cache")
 	ErrNotWatched      = errors.New("contract not in watchlist")
+	ErrNotPopulated    = errors.New("contract watched but not yet cached")
 	ErrInconsistentState = errors.New("cache state inconsistent with canonical state")
+	ErrInvalidConfig     = errors.New("invalid hot cache config")
+	ErrCodeHashMismatch  = errors.New("contract code hash changed")
+	ErrEmptyReorg        = errors.New("reorg without new chain")
+	
+	// ErrWatchlistFull is returned when adding contracts would grow the
+	// watchlist beyond Config.MaxWatchlist.
+	ErrWatchlistFull = errors.New("hot cache watchlist full")
+	
+	// ErrMissingSlot is returned by decoders if a required slot is absent
+	// from the slots passed to Decode. It is wrapped with the missing slot.
+	ErrMissingSlot = errors.New("missing required slot")
+
+	// ErrReorgProtectionDisabled is returned by HandleReorg if no snapshots
+	// are retained to roll back to; see Config.DisableReorgProtection.
+	ErrReorgProtectionDisabled = errors.New("hot cache reorg protection disabled")
 )
 
 // Config contains configuration for the hot state cache.
@@ -47,9 +70,104 @@ type Config struct {
 	// Should be true initially to verify correctness
 	ShadowMode bool
 	
-	// MaxSnapshots is the maximum number of historical snapshots to keep
-	// for reorg protection (default: 64)
+	// ValidateEveryN samples shadow mode validation during Update to every
+	// N-th block number (default: 0, validate every block)
+	ValidateEveryN uint64
+	
+	// ValidationSampleRate is the fraction of cached slots, between 0 and 1,
+	// checked by each Validate call. Slots are picked at random per call so a
+	// persistent mismatch is still found over successive validations
+	// (default: 0, check every slot)
+	ValidationSampleRate float64
+	
+	// OnValidationError, if set, is called for every mismatched slot found by
+	// shadow mode validation, before the validation error is returned. It runs
+	// synchronously on the validating goroutine, i.e. during block import, and
+	// must not block or call back into the cache's Update methods.
+	OnValidationError func(addr common.Address, slot common.Hash, cached, canonical common.Hash)
+	
+	// PublishPartialUpdates publishes the contracts read so far when an
+	// UpdateContext call is cancelled, instead of keeping the previous
+	// snapshot. Contracts not yet read are absent from the partial snapshot.
+	PublishPartialUpdates bool
+	
+	// MergePartialUpdates carries the previous state of contracts that could
+	// not be read forward into the new snapshot instead of dropping them:
+	// contracts whose update failed and, for partial snapshots published
+	// under PublishPartialUpdates, the contracts not yet read. A single failing
+	// contract then does not hold back the others, and LastUpdated tells how
+	// stale each carried state is.
+	MergePartialUpdates bool
+	
+	// PublishOnlyOnChange skips publishing a new snapshot for blocks that
+	// leave every watched contract unchanged. The current snapshot is then
+	// retained for the new block as well, keeping the metadata of the block
+	// it was built from, and subscribers are not notified.
+	PublishOnlyOnChange bool
+	
+	// MaxValidationErrors disables the cache once the total number of
+	// mismatched slots found by validation exceeds it, so consumers fall back
+	// to canonical reads (default: 0, never disable)
+	MaxValidationErrors uint64
+	
+	// MaxSnapshots is the maximum number of most recently published snapshots
+	// to keep for reorg protection (default: 64)
 	MaxSnapshots int
+	
+	// WatchFactoryPairs enables ProcessLogs to add the pairs created by
+	// factories registered with WatchFactory to the watchlist
+	WatchFactoryPairs bool
+	
+	// MaxConsecutiveDecodeErrors marks a contract Dead once decoding it
+	// failed on that many consecutive updates, telling persistent failures
+	// apart from transient ones, see DeadContracts (default: 0, never)
+	MaxConsecutiveDecodeErrors uint64
+	
+	// MaxActiveContracts limits the number of watched contracts updated on
+	// each block. Once exceeded, the coldest contracts according to the
+	// EvictionPolicy are dropped from the snapshot but stay watched; reading
+	// an evicted contract returns ErrNotPopulated and admits it again on the
+	// next update (default: 0, update every watched contract)
+	MaxActiveContracts int
+	
+	// MaxWatchlist caps the number of watched contracts to bound the work
+	// done on block import. Additions beyond it fail with ErrWatchlistFull
+	// and New truncates a longer Watchlist (default: 0, unlimited)
+	MaxWatchlist int
+	
+	// SkipOptionalSlots stops reading the OptionalSlots of decoders, saving
+	// storage reads on block import at the cost of decoding their fields as
+	// zero (default: false, read optional slots)
+	SkipOptionalSlots bool
+	
+	// EvictionPolicy selects the contracts evicted by MaxActiveContracts
+	// (default: EvictLRU)
+	EvictionPolicy EvictionPolicy
+	
+	// PoolMetrics allows ExportPoolMetrics to export the reserves and price
+	// of every cached Uniswap V2 (or fork) pool. Each pool adds three
+	// metrics, so it should only be enabled for moderately sized watchlists.
+	PoolMetrics bool
+	
+	// DisableReorgProtection keeps only the current snapshot instead of
+	// retaining MaxSnapshots of them, saving their memory on chains that
+	// never reorg, e.g. with deterministic finality. HandleReorg then fails
+	// with ErrReorgProtectionDisabled and the cache serves the abandoned
+	// branch's state until the next update; snapshots of earlier blocks are
+	// not available through GetSnapshotByHash or HotCacheDiff.
+	DisableReorgProtection bool
+	
+	// ReuseSnapshots recycles the maps of snapshots that age out of the
+	// retention window to reduce per-block allocations. When enabled, a
+	// snapshot must not be read after it has been evicted; see recycleSnapshot.
+	// It has no effect with DisableReorgProtection, where nothing is retained
+	// to bound how long readers may hold the replaced snapshot.
+	ReuseSnapshots bool
+	
+	// Logger receives the cache's log messages, allowing them to be scoped,
+	// silenced or redirected independently of the node (default: nil, the
+	// root logger)
+	Logger log.Logger
 }
 
 // DefaultConfig returns the default configuration.
@@ -62,47 +180,162 @@ func DefaultConfig() Config {
 	}
 }
 
+// Reader is the read-only view of the cache. Code that only consumes cached
+// state should depend on it rather than on *Cache, so that it can be tested
+// against a fake.
+type Reader interface {
+	GetSnapshot() *Snapshot
+	GetContractState(addr common.Address) (*ContractState, error)
+	GetRawSlot(addr common.Address, slot common.Hash) (common.Hash, error)
+	IsWatched(addr common.Address) bool
+	GetStatistics() StatisticsSnapshot
+}
+
+var _ Reader = (*Cache)(nil)
+
 // Cache maintains an in-memory cache of DeFi contract state.
 // It uses copy-on-write snapshots for lock-free reads and atomic updates.
 type Cache struct {
-	config Config
+	config  Config
+	logger  log.Logger  // config.Logger, or the root logger if unset
+	enabled atomic.Bool // config.Enabled, cleared when validation fails repeatedly
+	tripped atomic.Bool // set once the cache was disabled by validation failures
+	
+	// Number of the last block the cache was updated to, which is ahead of
+	// the current snapshot's block if PublishOnlyOnChange retained it
+	headNumber atomic.Uint64
 	
 	// Current canonical state (atomic pointer for lock-free reads)
 	current atomic.Pointer[Snapshot]
 	
-	// Historical snapshots for reorg protection, keyed by block hash
-	snapshots map[common.Hash]*Snapshot
+	// Work done by the most recent update, see LastUpdateStats
+	lastUpdate atomic.Pointer[UpdateStats]
+	
+	// Most recent snapshots for reorg protection, indexed by block hash, nil
+	// if reorg protection is disabled
+	snapshots *snapshotRing
 	snapshotMu sync.RWMutex
 	
-	// Watchlist map for O(1) lookup
+	// Watchlist map for O(1) lookup, and factories whose new pairs are
+	// watched, see WatchFactory
 	watchlist map[common.Address]bool
+	factories map[common.Address]bool
+	watchMu   sync.RWMutex
+	
+	// Decoders for known contract types, additional raw slots to read,
+	// expected code hashes, token decimals, quote tokens and reserve change
+	// thresholds of pools, all guarded by decoderMu.
+	// Decoders of proxies keyed by implementation code hash are replaced on
+	// write, so they can be read after releasing decoderMu.
+	decoders      map[common.Address]ContractDecoder
+	implDecoders  map[common.Address]map[common.Hash]ContractDecoder
+	customSlots   map[common.Address][]common.Hash
+	codeHashes    map[common.Address]common.Hash
+	tokenDecimals map[common.Address]uint8
+	quoteTokens   map[common.Address]common.Address
+	thresholds    map[common.Address]uint64 // reserve change thresholds in bps
+	interned      map[interface{}]ContractDecoder // stateless decoders by configuration
+	decoderMu     sync.RWMutex
 	
-	// Decoders for known contract types
-	decoders map[common.Address]ContractDecoder
-	decoderMu sync.RWMutex
+	// Reads of watched contracts, tracked for MaxActiveContracts eviction
+	access   map[common.Address]*contractAccess
+	accessMu sync.RWMutex
+	
+	// Snapshot update subscribers
+	subscribers map[chan *Snapshot]struct{}
+	subMu       sync.Mutex
+	
+	// Background validator, see StartValidator
+	validatorStop chan struct{}
+	validatorDone chan struct{}
+	validatorMu   sync.Mutex
 	
 	// Statistics
-	stats Statistics
+	stats         statistics
+	updateLatency latencyWindow
+	metrics       *cacheMetrics // nil if the cache is disabled
+	poolMetrics   poolExporter  // per-pool gauges, see ExportPoolMetrics
 }
 
-// Statistics tracks cache performance metrics.
-type Statistics struct {
+// statistics tracks cache performance metrics. The counters are updated
+// atomically and must not be copied; use snapshot to read them.
+type statistics struct {
 	Hits              atomic.Uint64
 	Misses            atomic.Uint64
 	Updates           atomic.Uint64
 	ValidationErrors  atomic.Uint64
 	ReorgCount        atomic.Uint64
+	Fallbacks         atomic.Uint64
+}
+
+// snapshot returns a point-in-time copy of the counters.
+func (s *statistics) snapshot() StatisticsSnapshot {
+	return StatisticsSnapshot{
+		Hits:             s.Hits.Load(),
+		Misses:           s.Misses.Load(),
+		Updates:          s.Updates.Load(),
+		ValidationErrors: s.ValidationErrors.Load(),
+		ReorgCount:       s.ReorgCount.Load(),
+		Fallbacks:        s.Fallbacks.Load(),
+	}
+}
+
+// StatisticsSnapshot is a point-in-time copy of the cache performance metrics.
+type StatisticsSnapshot struct {
+	Hits             uint64 `json:"hits"`
+	Misses           uint64 `json:"misses"`
+	Updates          uint64 `json:"updates"`
+	ValidationErrors uint64 `json:"validationErrors"`
+	ReorgCount       uint64 `json:"reorgCount"`
+	Fallbacks        uint64 `json:"fallbacks"`
+}
+
+// UpdateStats counts the contracts handled by a single update.
+type UpdateStats struct {
+	BlockNumber  uint64 `json:"blockNumber"`
+	Updated      int    `json:"updated"`      // contracts read from the state
+	Skipped      int    `json:"skipped"`      // unchanged contracts carried forward by incremental updates
+	DecodeFailed int    `json:"decodeFailed"` // updated contracts that failed to decode
+	Failed       int    `json:"failed"`       // contracts whose update failed
 }
 
 // Snapshot represents a point-in-time view of cached contract states.
 // Snapshots are immutable once published for lock-free reads.
 type Snapshot struct {
-	BlockNumber uint64
-	BlockHash   common.Hash
-	BlockTime   uint64
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+	BlockTime   uint64      `json:"blockTime"`
+	StateRoot   common.Hash `json:"stateRoot"` // state root of the block, for verifying the reads
 	
 	// Contract states keyed by address
-	Contracts map[common.Address]*ContractState
+	Contracts map[common.Address]*ContractState `json:"contracts"`
+	
+	// Contracts that changed significantly since the previous snapshot, see
+	// SignificantChanges
+	significant map[common.Address]bool
+}
+
+// Age returns the number of seconds between the snapshot's block time and now,
+// or zero if now precedes the block time.
+func (s *Snapshot) Age(now uint64) uint64 {
+	if now <= s.BlockTime {
+		return 0
+	}
+	return now - s.BlockTime
+}
+
+// ContractsOfType returns the states of all contracts of the given type in
+// the snapshot, sorted by address. The states are shared by all readers of
+// the snapshot and must not be modified.
+func (s *Snapshot) ContractsOfType(t ContractType) []*ContractState {
+	var states []*ContractState
+	for _, state := range s.Contracts {
+		if state.Type == t {
+			states = append(states, state)
+		}
+	}
+	slices.SortFunc(states, func(a, b *ContractState) int { return a.Address.Cmp(b.Address) })
+	return states
 }
 
 // ContractState holds the cached state for a single contract.
@@ -110,16 +343,85 @@ type ContractState struct {
 	Address     common.Address
 	Type        ContractType
 	
-	// Raw storage slots (always populated)
-	RawSlots    map[common.Hash]common.Hash
+	// Raw storage slots (always populated). Unexported so the slots of a
+	// shared snapshot cannot be mutated; see ForEachSlot and SlotCount.
+	rawSlots    map[common.Hash]common.Hash
 	
 	// Decoded state (populated if decoder available)
 	Decoded     interface{}
 	
+	// NativeBalance is the contract's native balance, read only for decoders
+	// implementing NativeBalanceDecoder and nil otherwise
+	NativeBalance *big.Int
+	
+	// DecodeError records why decoding failed; Decoded is nil in that case
+	// while the raw slots stay populated
+	DecodeError error
+	
+	// Suspect is set when the contract's code no longer matches the expected
+	// code hash, e.g. after a proxy upgrade. Decoding is skipped as the storage
+	// layout may have changed.
+	Suspect bool
+	
+	// Dead is set once decoding failed on MaxConsecutiveDecodeErrors
+	// consecutive updates. The contract is still served with its raw slots
+	// and decoded again on every update; a successful decode clears it.
+	Dead           bool
+	decodeFailures uint64 // consecutive decode failures, capped at the limit
+	
 	// Metadata
 	LastUpdated uint64 // Block number
 }
 
+// ForEachSlot calls fn for every cached raw storage slot of the contract, in
+// unspecified order.
+func (s *ContractState) ForEachSlot(fn func(slot, value common.Hash)) {
+	for slot, value := range s.rawSlots {
+		fn(slot, value)
+	}
+}
+
+// SlotCount returns the number of cached raw storage slots of the contract.
+func (s *ContractState) SlotCount() int {
+	return len(s.rawSlots)
+}
+
+// Copy returns a deep copy of the contract state. Decoded states of the
+// built-in contract types are copied deeply; other decoded values are shared.
+func (s *ContractState) Copy() *ContractState {
+	cpy := *s
+	cpy.NativeBalance = copyBig(s.NativeBalance)
+	cpy.rawSlots = make(map[common.Hash]common.Hash, len(s.rawSlots))
+	for slot, value := range s.rawSlots {
+		cpy.rawSlots[slot] = value
+	}
+	switch decoded := s.Decoded.(type) {
+	case *UniswapV2State:
+		cpy.Decoded = decoded.Copy()
+	case *UniswapV3State:
+		cpy.Decoded = decoded.Copy()
+	case *ERC20State:
+		cpy.Decoded = decoded.Copy()
+	case *ChainlinkState:
+		cpy.Decoded = decoded.Copy()
+	case *BalancerState:
+		cpy.Decoded = decoded.Copy()
+	case *CompoundState:
+		cpy.Decoded = decoded.Copy()
+	case *WETHState:
+		cpy.Decoded = decoded.Copy()
+	}
+	return &cpy
+}
+
+// copyBig returns a copy of a big integer, preserving nil.
+func copyBig(x *big.Int) *big.Int {
+	if x == nil {
+		return nil
+	}
+	return new(big.Int).Set(x)
+}
+
 // ContractType identifies the contract type for specialized decoding.
 type ContractType uint8
 
@@ -128,9 +430,62 @@ const (
 	ContractTypeUniswapV2
 	ContractTypeUniswapV3
 	ContractTypeAave
-	ContractTypeCurve
+	ContractTypeCurve // reserved, no decoder yet; watch Curve pools with WatchSlots
+	ContractTypeSushiSwap
+	ContractTypeERC20
+	ContractTypeChainlink
+	ContractTypeBalancer
+	ContractTypeCompound
+	ContractTypeWETH
+	
+	// firstCustomContractType is the first type assigned by
+	// RegisterContractType; built-in types must be declared above it.
+	firstCustomContractType
 )
 
+// customContractTypes holds the names of the types registered through
+// RegisterContractType, indexed from firstCustomContractType.
+var (
+	customContractTypes   []string
+	customContractTypesMu sync.RWMutex
+)
+
+// RegisterContractType assigns a new contract type for a decoder defined
+// outside this package and records its name for String and JSON encoding.
+// Types are assigned in registration order, so they are only stable across
+// restarts if registered in the same order, typically from init functions.
+// It panics if the name is already taken or the types are exhausted.
+func RegisterContractType(name string) ContractType {
+	customContractTypesMu.Lock()
+	defer customContractTypesMu.Unlock()
+
+	if _, ok := contractTypeByName(name); ok {
+		panic(fmt.Sprintf("hotcache: contract type %q already registered", name))
+	}
+	next := int(firstCustomContractType) + len(customContractTypes)
+	if next > math.MaxUint8 {
+		panic(fmt.Sprintf("hotcache: too many contract types registering %q", name))
+	}
+	customContractTypes = append(customContractTypes, name)
+	return ContractType(next)
+}
+
+// contractTypeByName returns the built-in or registered type with the given
+// name. The caller must hold customContractTypesMu.
+func contractTypeByName(name string) (ContractType, bool) {
+	for _, known := range knownContractTypes {
+		if known.String() == name {
+			return known, true
+		}
+	}
+	for i, custom := range customContractTypes {
+		if custom == name {
+			return firstCustomContractType + ContractType(i), true
+		}
+	}
+	return ContractTypeUnknown, false
+}
+
 func (t ContractType) String() string {
 	switch t {
 	case ContractTypeUniswapV2:
@@ -141,28 +496,120 @@ func (t ContractType) String() string {
 		return "Aave"
 	case ContractTypeCurve:
 		return "Curve"
-	default:
-		return "Unknown"
+	case ContractTypeSushiSwap:
+		return "SushiSwap"
+	case ContractTypeERC20:
+		return "ERC20"
+	case ContractTypeChainlink:
+		return "Chainlink"
+	case ContractTypeBalancer:
+		return "Balancer"
+	case ContractTypeCompound:
+		return "Compound"
+	case ContractTypeWETH:
+		return "WETH"
+	}
+	if t >= firstCustomContractType {
+		customContractTypesMu.RLock()
+		defer customContractTypesMu.RUnlock()
+		if i := int(t - firstCustomContractType); i < len(customContractTypes) {
+			return customContractTypes[i]
+		}
+	}
+	return "Unknown"
+}
+
+// Validate checks the configuration for consistency. An enabled cache must
+// watch at least one contract, watchlist entries must be unique and non-zero,
+// MaxSnapshots must not be negative and ValidationSampleRate must lie within
+// [0, 1]. ShadowMode has no effect while the cache is disabled, so it is
+// accepted either way, as in DefaultConfig.
+func (config *Config) Validate() error {
+	if config.Enabled && len(config.Watchlist) == 0 {
+		return fmt.Errorf("%w: enabled with empty watchlist", ErrInvalidConfig)
+	}
+	if config.MaxSnapshots < 0 {
+		return fmt.Errorf("%w: negative MaxSnapshots %d", ErrInvalidConfig, config.MaxSnapshots)
+	}
+	if config.ValidationSampleRate < 0 || config.ValidationSampleRate > 1 {
+		return fmt.Errorf("%w: ValidationSampleRate %v out of range", ErrInvalidConfig, config.ValidationSampleRate)
+	}
+	if config.MaxActiveContracts < 0 {
+		return fmt.Errorf("%w: negative MaxActiveContracts %d", ErrInvalidConfig, config.MaxActiveContracts)
+	}
+	if config.MaxWatchlist < 0 {
+		return fmt.Errorf("%w: negative MaxWatchlist %d", ErrInvalidConfig, config.MaxWatchlist)
+	}
+	seen := make(map[common.Address]bool, len(config.Watchlist))
+	for _, addr := range config.Watchlist {
+		if addr == (common.Address{}) {
+			return fmt.Errorf("%w: zero address in watchlist", ErrInvalidConfig)
+		}
+		if seen[addr] {
+			return fmt.Errorf("%w: duplicate watchlist address %s", ErrInvalidConfig, addr.Hex())
+		}
+		seen[addr] = true
+	}
+	return nil
+}
+
+// NewWithError creates a new hot state cache, rejecting invalid configurations.
+func NewWithError(config Config) (*Cache, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
+	return New(config), nil
 }
 
-// New creates a new hot state cache with the given configuration.
+// New creates a new hot state cache with the given configuration. The
+// configuration is not validated; see NewWithError.
 func New(config Config) *Cache {
 	if config.MaxSnapshots == 0 {
 		config.MaxSnapshots = 64
 	}
+	if config.Logger == nil {
+		config.Logger = log.Root()
+	}
 	
-	// Build watchlist map
+	// Build watchlist map, dropping duplicate entries
 	watchlist := make(map[common.Address]bool, len(config.Watchlist))
+	unique := make([]common.Address, 0, len(config.Watchlist))
 	for _, addr := range config.Watchlist {
-		watchlist[addr] = true
+		if !watchlist[addr] {
+			watchlist[addr] = true
+			unique = append(unique, addr)
+		}
+	}
+	if dups := len(config.Watchlist) - len(unique); dups > 0 {
+		config.Logger.Warn("Dropped duplicate hot cache watchlist entries", "duplicates", dups, "watched", len(unique))
+	}
+	if config.MaxWatchlist > 0 && len(unique) > config.MaxWatchlist {
+		for _, addr := range unique[config.MaxWatchlist:] {
+			delete(watchlist, addr)
+		}
+		config.Logger.Warn("Truncated hot cache watchlist", "dropped", len(unique)-config.MaxWatchlist, "max", config.MaxWatchlist)
+		unique = unique[:config.MaxWatchlist]
 	}
+	config.Watchlist = unique
 	
 	cache := &Cache{
-		config:    config,
-		snapshots: make(map[common.Hash]*Snapshot),
-		watchlist: watchlist,
-		decoders:  make(map[common.Address]ContractDecoder),
+		config:        config,
+		logger:        config.Logger,
+		watchlist:     watchlist,
+		factories:     make(map[common.Address]bool),
+		decoders:      make(map[common.Address]ContractDecoder),
+		implDecoders:  make(map[common.Address]map[common.Hash]ContractDecoder),
+		customSlots:   make(map[common.Address][]common.Hash),
+		codeHashes:    make(map[common.Address]common.Hash),
+		tokenDecimals: make(map[common.Address]uint8),
+		quoteTokens:   make(map[common.Address]common.Address),
+		thresholds:    make(map[common.Address]uint64),
+		interned:      make(map[interface{}]ContractDecoder),
+		subscribers:   make(map[chan *Snapshot]struct{}),
+		access:        make(map[common.Address]*contractAccess),
+	}
+	if !config.DisableReorgProtection {
+		cache.snapshots = newSnapshotRing(config.MaxSnapshots)
 	}
 	
 	// Initialize with empty snapshot
@@ -170,10 +617,14 @@ func New(config Config) *Cache {
 		Contracts: make(map[common.Address]*ContractState),
 	}
 	cache.current.Store(initial)
+	cache.enabled.Store(config.Enabled)
 	
 	if config.Enabled {
-		log.Info("Hot state cache initialized",
-			"watchlist", len(config.Watchlist),
+		cache.metrics = newCacheMetrics()
+		cache.metrics.watchlist.Update(int64(len(watchlist)))
+		
+		cache.logger.Info("Hot state cache initialized",
+			"watchlist", len(watchlist),
 			"shadowMode", config.ShadowMode,
 			"maxSnapshots", config.MaxSnapshots)
 	}
@@ -181,22 +632,430 @@ func New(config Config) *Cache {
 	return cache
 }
 
-// IsEnabled returns whether the cache is enabled.
+// IsEnabled returns whether the cache is enabled. A cache enabled in its
+// configuration is disabled once it exceeds MaxValidationErrors.
 func (c *Cache) IsEnabled() bool {
-	return c.config.Enabled
+	return c.enabled.Load()
+}
+
+// Healthy reports whether the cache has not been disabled for exceeding
+// MaxValidationErrors.
+func (c *Cache) Healthy() bool {
+	return !c.tripped.Load()
 }
 
 // IsWatched returns whether an address is in the watchlist.
 func (c *Cache) IsWatched(addr common.Address) bool {
+	c.watchMu.RLock()
+	defer c.watchMu.RUnlock()
 	return c.watchlist[addr]
 }
 
+// AddToWatchlist starts caching the given contract from the next update on.
+// Adding an already watched contract is a no-op. Returns ErrWatchlistFull if
+// the watchlist already holds MaxWatchlist contracts.
+func (c *Cache) AddToWatchlist(addr common.Address) error {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	return c.addToWatchlist(addr)
+}
+
+// addToWatchlist implements AddToWatchlist. Must be called with watchMu held.
+func (c *Cache) addToWatchlist(addr common.Address) error {
+	if c.watchlist[addr] {
+		return nil
+	}
+	if err := c.checkWatchlistLimit(); err != nil {
+		return err
+	}
+	c.watchlist[addr] = true
+	if c.metrics != nil {
+		c.metrics.watchlist.Update(int64(len(c.watchlist)))
+	}
+	c.logger.Debug("Added contract to hot cache watchlist", "address", addr)
+	return nil
+}
+
+// checkWatchlistLimit returns ErrWatchlistFull if the watchlist holds
+// MaxWatchlist contracts. Must be called with watchMu held.
+func (c *Cache) checkWatchlistLimit() error {
+	if limit := c.config.MaxWatchlist; limit > 0 && len(c.watchlist) >= limit {
+		return fmt.Errorf("%w: %d contracts", ErrWatchlistFull, limit)
+	}
+	return nil
+}
+
+// RemoveFromWatchlist stops caching the given contract. Its decoder and custom
+// slots are dropped and the contract disappears from the next published
+// snapshot.
+func (c *Cache) RemoveFromWatchlist(addr common.Address) {
+	c.watchMu.Lock()
+	delete(c.watchlist, addr)
+	if c.metrics != nil {
+		c.metrics.watchlist.Update(int64(len(c.watchlist)))
+	}
+	c.watchMu.Unlock()
+
+	c.decoderMu.Lock()
+	delete(c.decoders, addr)
+	delete(c.implDecoders, addr)
+	delete(c.customSlots, addr)
+	delete(c.codeHashes, addr)
+	delete(c.quoteTokens, addr)
+	delete(c.thresholds, addr)
+	c.decoderMu.Unlock()
+
+	// Forget the reads, so a contract watched again starts cold
+	c.accessMu.Lock()
+	delete(c.access, addr)
+	c.accessMu.Unlock()
+
+	c.logger.Debug("Removed contract from hot cache watchlist", "address", addr)
+}
+
+// ReplaceWatchlist atomically replaces the watchlist with addrs and the
+// registered decoders with decoders, e.g. on a configuration reload. Readers
+// never observe a mix of the old and the new watchlist. Decoders for addresses
+// outside addrs are ignored; custom slots, implementation decoders, expected
+// code hashes, quote tokens and change thresholds of contracts that stay
+// watched are kept.
+// Removed contracts, including pairs watched through WatchFactory, disappear
+// from the next published snapshot. An update running concurrently may still read the old
+// watchlist, the replacement takes full effect from the next update on.
+// If addrs holds more than MaxWatchlist distinct contracts, ErrWatchlistFull
+// is returned and the watchlist is left unchanged.
+func (c *Cache) ReplaceWatchlist(addrs []common.Address, decoders map[common.Address]ContractDecoder) error {
+	watchlist := make(map[common.Address]bool, len(addrs))
+	registered := make(map[common.Address]ContractDecoder, len(decoders))
+	for _, addr := range addrs {
+		watchlist[addr] = true
+	}
+	if limit := c.config.MaxWatchlist; limit > 0 && len(watchlist) > limit {
+		return fmt.Errorf("%w: %d contracts, have %d", ErrWatchlistFull, limit, len(watchlist))
+	}
+	c.watchMu.Lock()
+	c.decoderMu.Lock()
+	for _, addr := range addrs {
+		if decoder, ok := decoders[addr]; ok && decoder != nil {
+			registered[addr] = c.internDecoder(decoder)
+		}
+	}
+	removed := 0
+	for addr := range c.watchlist {
+		if !watchlist[addr] {
+			delete(c.implDecoders, addr)
+			delete(c.customSlots, addr)
+			delete(c.codeHashes, addr)
+			delete(c.quoteTokens, addr)
+			delete(c.thresholds, addr)
+			removed++
+		}
+	}
+	c.watchlist = watchlist
+	c.decoders = registered
+	c.decoderMu.Unlock()
+	if c.metrics != nil {
+		c.metrics.watchlist.Update(int64(len(watchlist)))
+	}
+	c.watchMu.Unlock()
+
+	c.logger.Info("Replaced hot cache watchlist", "watched", len(watchlist), "removed", removed, "decoders", len(registered))
+	return nil
+}
+
+// Watchlist returns a copy of the watched addresses, sorted for stable output.
+func (c *Cache) Watchlist() []common.Address {
+	addrs := c.watchedAddresses()
+	slices.SortFunc(addrs, func(a, b common.Address) int { return a.Cmp(b) })
+	return addrs
+}
+
+// DeadContracts returns the contracts marked dead in the current snapshot for
+// failing to decode on MaxConsecutiveDecodeErrors consecutive updates, sorted
+// for stable output.
+func (c *Cache) DeadContracts() []common.Address {
+	var dead []common.Address
+	for addr, state := range c.GetSnapshot().Contracts {
+		if state.Dead {
+			dead = append(dead, addr)
+		}
+	}
+	slices.SortFunc(dead, func(a, b common.Address) int { return a.Cmp(b) })
+	return dead
+}
+
+// GetContractsByType returns the states of all contracts of the given type in
+// the current snapshot, sorted by address; see Snapshot.ContractsOfType.
+func (c *Cache) GetContractsByType(t ContractType) []*ContractState {
+	return c.GetSnapshot().ContractsOfType(t)
+}
+
+// watchedAddresses returns a copy of the watchlist that is safe to iterate
+// without holding watchMu.
+func (c *Cache) watchedAddresses() []common.Address {
+	c.watchMu.RLock()
+	defer c.watchMu.RUnlock()
+	addrs := make([]common.Address, 0, len(c.watchlist))
+	for addr := range c.watchlist {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
 // RegisterDecoder registers a decoder for a specific contract address.
 func (c *Cache) RegisterDecoder(addr common.Address, decoder ContractDecoder) {
 	c.decoderMu.Lock()
 	defer c.decoderMu.Unlock()
+	decoder = c.internDecoder(decoder)
+	c.decoders[addr] = decoder
+	c.logger.Debug("Registered contract decoder", "address", addr, "type", decoder.Type())
+}
+
+// registerDecoderIfAbsent registers decoder for a contract unless it already
+// has one, including decoders registered for specific implementations. The
+// check and the registration happen atomically; it reports whether decoder
+// was registered.
+func (c *Cache) registerDecoderIfAbsent(addr common.Address, decoder ContractDecoder) bool {
+	c.decoderMu.Lock()
+	defer c.decoderMu.Unlock()
+	if _, ok := c.decoders[addr]; ok || len(c.implDecoders[addr]) > 0 {
+		return false
+	}
+	decoder = c.internDecoder(decoder)
 	c.decoders[addr] = decoder
-	log.Debug("Registered contract decoder", "address", addr, "type", decoder.Type())
+	c.logger.Debug("Registered contract decoder", "address", addr, "type", decoder.Type())
+	return true
+}
+
+// internDecoder returns the first registered instance of a stateless decoder
+// equal to the given one, so contracts registered with separately allocated
+// but identical decoders share a single instance. Other decoders are returned
+// unchanged. Must be called with decoderMu held.
+func (c *Cache) internDecoder(decoder ContractDecoder) ContractDecoder {
+	if stateless, ok := decoder.(StatelessDecoder); !ok || !stateless.Stateless() {
+		return decoder
+	}
+	// Key by the pointed-to configuration, which also encodes the type
+	key := reflect.ValueOf(decoder)
+	if key.Kind() == reflect.Pointer {
+		if key.IsNil() {
+			return decoder
+		}
+		key = key.Elem()
+	}
+	if !key.Comparable() {
+		return decoder
+	}
+	if shared, ok := c.interned[key.Interface()]; ok {
+		return shared
+	}
+	c.interned[key.Interface()] = decoder
+	return decoder
+}
+
+// RegisterDecoderForImpl registers a decoder for a proxy contract running the
+// implementation with the given code hash. Once a contract has decoders for
+// implementations, each update decodes it with the one matching its current
+// code hash, taking precedence over a decoder registered with RegisterDecoder.
+// If none matches, or the StateReader does not implement CodeHashReader, only
+// its raw slots are cached.
+func (c *Cache) RegisterDecoderForImpl(addr common.Address, codeHash common.Hash, decoder ContractDecoder) {
+	c.decoderMu.Lock()
+	defer c.decoderMu.Unlock()
+	impls := maps.Clone(c.implDecoders[addr])
+	if impls == nil {
+		impls = make(map[common.Hash]ContractDecoder)
+	}
+	impls[codeHash] = decoder
+	c.implDecoders[addr] = impls
+	c.logger.Debug("Registered implementation decoder", "address", addr, "code", codeHash, "type", decoder.Type())
+}
+
+// lookupDecoder returns the decoder of a contract, selecting among decoders
+// registered for implementations by the code hash reported by stateDB.
+func (c *Cache) lookupDecoder(addr common.Address, stateDB StateReader) (ContractDecoder, bool) {
+	c.decoderMu.RLock()
+	decoder, ok := c.decoders[addr]
+	impls := c.implDecoders[addr]
+	c.decoderMu.RUnlock()
+
+	if impls == nil {
+		return decoder, ok
+	}
+	reader, ok := stateDB.(CodeHashReader)
+	if !ok {
+		return nil, false
+	}
+	decoder, ok = impls[reader.GetCodeHash(addr)]
+	return decoder, ok
+}
+
+// SetTokenDecimals registers the number of decimals of a token, used to
+// annotate decoded pool states so they can quote normalized prices. The
+// decimals are applied from the next update on.
+func (c *Cache) SetTokenDecimals(token common.Address, decimals uint8) {
+	c.decoderMu.Lock()
+	defer c.decoderMu.Unlock()
+	c.tokenDecimals[token] = decimals
+}
+
+// SetQuoteToken registers the token a pool's price is quoted in, see
+// GetQuotedPrice. The token is validated against the pool's tokens when the
+// price is read. A zero address removes the registration.
+func (c *Cache) SetQuoteToken(pool, quoteToken common.Address) {
+	c.decoderMu.Lock()
+	defer c.decoderMu.Unlock()
+	if quoteToken == (common.Address{}) {
+		delete(c.quoteTokens, pool)
+		return
+	}
+	c.quoteTokens[pool] = quoteToken
+}
+
+// QuoteToken returns the quote token registered for a pool.
+func (c *Cache) QuoteToken(pool common.Address) (common.Address, bool) {
+	c.decoderMu.RLock()
+	defer c.decoderMu.RUnlock()
+	quote, ok := c.quoteTokens[pool]
+	return quote, ok
+}
+
+// GetQuotedPrice returns the price of a cached Uniswap V2 (or fork) pool in
+// its registered quote token, see UniswapV2State.QuotedPrice. Without a
+// registered quote token the price is quoted in token1, as by GetPrice.
+func (c *Cache) GetQuotedPrice(pool common.Address) (*big.Float, error) {
+	state, err := c.GetContractState(pool)
+	if err != nil {
+		return nil, err
+	}
+	v2, ok := state.Decoded.(*UniswapV2State)
+	if !ok {
+		return nil, fmt.Errorf("contract %s is not a decoded Uniswap V2 pool", pool.Hex())
+	}
+	quote, ok := c.QuoteToken(pool)
+	if !ok {
+		quote = v2.Token1
+	}
+	return v2.QuotedPrice(quote)
+}
+
+// SetChangeThreshold sets the reserve move, in basis points of the previous
+// reserves, below which an update of a Uniswap V2 (or fork) pool is not
+// reported by Snapshot.SignificantChanges. A threshold of zero removes it, so
+// that any change of the pool is significant again.
+func (c *Cache) SetChangeThreshold(addr common.Address, bps uint64) {
+	c.decoderMu.Lock()
+	defer c.decoderMu.Unlock()
+	if bps == 0 {
+		delete(c.thresholds, addr)
+		return
+	}
+	c.thresholds[addr] = bps
+}
+
+// ChangeThreshold returns the reserve change threshold of a pool in basis
+// points, or zero if none is set.
+func (c *Cache) ChangeThreshold(addr common.Address) uint64 {
+	c.decoderMu.RLock()
+	defer c.decoderMu.RUnlock()
+	return c.thresholds[addr]
+}
+
+// lookupTokenDecimals returns the registered decimals of a token.
+func (c *Cache) lookupTokenDecimals(token common.Address) (uint8, bool) {
+	c.decoderMu.RLock()
+	defer c.decoderMu.RUnlock()
+	decimals, ok := c.tokenDecimals[token]
+	return decimals, ok
+}
+
+// HasDecoder returns whether a decoder is registered for the given address,
+// including decoders registered for specific implementations.
+func (c *Cache) HasDecoder(addr common.Address) bool {
+	c.decoderMu.RLock()
+	defer c.decoderMu.RUnlock()
+	_, ok := c.decoders[addr]
+	return ok || len(c.implDecoders[addr]) > 0
+}
+
+// RequiredSlots returns the slots read by the decoder of a contract, not
+// including slots added with WatchSlots. Optional slots follow the required
+// ones unless Config.SkipOptionalSlots is set. For contracts with decoders
+// registered per implementation, the decoder is selected by the code hash in
+// stateDB. The boolean is false if no decoder applies.
+func (c *Cache) RequiredSlots(addr common.Address, stateDB StateReader) ([]common.Hash, bool) {
+	decoder, ok := c.lookupDecoder(addr, stateDB)
+	if !ok {
+		return nil, false
+	}
+	slots := slices.Clone(decoder.RequiredSlots())
+	if optional, ok := decoder.(OptionalSlotDecoder); ok && !c.config.SkipOptionalSlots {
+		slots = append(slots, optional.OptionalSlots()...)
+	}
+	return slots, true
+}
+
+// UnregisterDecoder removes the decoders of a contract, including those
+// registered for specific implementations. The contract stays
+// watched; subsequent updates publish it with its custom slots only and no
+// decoded state.
+func (c *Cache) UnregisterDecoder(addr common.Address) {
+	c.decoderMu.Lock()
+	defer c.decoderMu.Unlock()
+	_, ok := c.decoders[addr]
+	if _, impl := c.implDecoders[addr]; ok || impl {
+		delete(c.decoders, addr)
+		delete(c.implDecoders, addr)
+		c.logger.Debug("Unregistered contract decoder", "address", addr)
+	}
+}
+
+// WatchSlots caches the given raw storage slots of a contract in addition to
+// those required by its decoder, if any, and adds the contract to the
+// watchlist. Slots of contracts without a decoder are published with a nil
+// Decoded state and can be read through GetRawSlot. Returns ErrWatchlistFull
+// if the contract cannot be watched, see AddToWatchlist.
+func (c *Cache) WatchSlots(addr common.Address, slots []common.Hash) error {
+	c.decoderMu.Lock()
+	for _, slot := range slots {
+		if !slices.Contains(c.customSlots[addr], slot) {
+			c.customSlots[addr] = append(c.customSlots[addr], slot)
+		}
+	}
+	c.decoderMu.Unlock()
+
+	if err := c.AddToWatchlist(addr); err != nil {
+		// Drop the slots with the contract, as RemoveFromWatchlist does
+		c.decoderMu.Lock()
+		delete(c.customSlots, addr)
+		c.decoderMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// WatchMappingSlot caches the entry of the (nested) Solidity mapping declared
+// at mappingSlot reached by keys, e.g. balanceOf[holder] or
+// allowance[owner][spender], like WatchSlots. Keys are padded as for
+// MappingSlot. The derived slot is returned; the cached value can be read with
+// GetMappingSlot without deriving it again.
+func (c *Cache) WatchMappingSlot(addr common.Address, mappingSlot common.Hash, keys ...[]byte) (common.Hash, error) {
+	slot := NestedMappingSlot(mappingSlot, keys...)
+	return slot, c.WatchSlots(addr, []common.Hash{slot})
+}
+
+// SetExpectedCodeHash records the code hash a contract's decoder was written
+// for. If the contract's code changes, its cached state is marked Suspect and
+// left undecoded. The check requires a StateReader implementing
+// CodeHashReader. A zero hash removes the expectation.
+func (c *Cache) SetExpectedCodeHash(addr common.Address, hash common.Hash) {
+	c.decoderMu.Lock()
+	defer c.decoderMu.Unlock()
+	if hash == (common.Hash{}) {
+		delete(c.codeHashes, addr)
+		return
+	}
+	c.codeHashes[addr] = hash
 }
 
 // GetSnapshot returns the current cache snapshot.
@@ -205,35 +1064,176 @@ func (c *Cache) GetSnapshot() *Snapshot {
 	return c.current.Load()
 }
 
+// IsStale reports whether the cache was last updated more than maxLag blocks
+// before the given head, or no block has been cached yet. Consumers should fall back
+// to trie reads for stale snapshots.
+func (c *Cache) IsStale(headNumber uint64, maxLag uint64) bool {
+	if c.GetSnapshot().BlockHash == (common.Hash{}) {
+		return true
+	}
+	number := c.headNumber.Load()
+	return number < headNumber && headNumber-number > maxLag
+}
+
+// LatestFresh returns the current snapshot if its block time is within maxAge
+// of the wall clock, guarding consumers against serving old prices from a
+// halted chain. Block times have a resolution of one second. If the snapshot
+// is older, or no block has been cached yet, nil and false are returned.
+func (c *Cache) LatestFresh(maxAge time.Duration) (*Snapshot, bool) {
+	snapshot := c.GetSnapshot()
+	if snapshot.BlockHash == (common.Hash{}) {
+		return nil, false
+	}
+	if time.Since(time.Unix(int64(snapshot.BlockTime), 0)) > maxAge {
+		return nil, false
+	}
+	return snapshot, true
+}
+
+// GetSnapshotByHash returns the retained snapshot holding the state at the
+// given block hash, or nil if it has aged out of the retention window. With
+// PublishOnlyOnChange this may be a snapshot built for an earlier block.
+func (c *Cache) GetSnapshotByHash(hash common.Hash) *Snapshot {
+	c.snapshotMu.RLock()
+	defer c.snapshotMu.RUnlock()
+	if c.snapshots == nil {
+		if current := c.current.Load(); current.BlockHash == hash {
+			return current
+		}
+		return nil
+	}
+	return c.snapshots.get(hash)
+}
+
 // GetContractState returns the cached state for a specific contract.
-// Returns ErrNotFound if the contract is not in the cache.
+// Returns ErrNotPopulated if the contract is watched but no update has cached
+// it yet, and ErrNotFound if it is not in the cache otherwise.
+// The returned state is shared with all other readers and must be treated as
+// read-only; use GetContractStateCopy to obtain a mutable copy.
 func (c *Cache) GetContractState(addr common.Address) (*ContractState, error) {
+	return c.contractStateIn(c.GetSnapshot(), addr)
+}
+
+// GetContractStateWithBlock is GetContractState also returning the hash and
+// number of the block the state was read from. Consumers combining several
+// reads should compare the returned blocks, or read them all from a single
+// GetSnapshot, to detect a new block landing in between.
+func (c *Cache) GetContractStateWithBlock(addr common.Address) (*ContractState, common.Hash, uint64, error) {
 	snapshot := c.GetSnapshot()
+	state, err := c.contractStateIn(snapshot, addr)
+	if err != nil {
+		return nil, common.Hash{}, 0, err
+	}
+	return state, snapshot.BlockHash, snapshot.BlockNumber, nil
+}
+
+// contractStateIn looks up a contract in the given snapshot, counting the hit
+// or miss.
+func (c *Cache) contractStateIn(snapshot *Snapshot, addr common.Address) (*ContractState, error) {
 	state, ok := snapshot.Contracts[addr]
 	if !ok {
 		c.stats.Misses.Add(1)
+		if c.metrics != nil {
+			c.metrics.misses.Inc(1)
+		}
+		if c.IsEnabled() && c.IsWatched(addr) {
+			c.recordAccess(addr, false)
+			return nil, ErrNotPopulated
+		}
 		return nil, ErrNotFound
 	}
 	c.stats.Hits.Add(1)
+	if c.metrics != nil {
+		c.metrics.hits.Inc(1)
+	}
+	c.recordAccess(addr, true)
 	return state, nil
 }
 
+// GetContractStateCopy returns a deep copy of the cached state for a specific
+// contract, which the caller may freely modify. GetContractState returns the
+// state shared by all readers of the snapshot, which must not be modified.
+func (c *Cache) GetContractStateCopy(addr common.Address) (*ContractState, error) {
+	state, err := c.GetContractState(addr)
+	if err != nil {
+		return nil, err
+	}
+	return state.Copy(), nil
+}
+
 // GetRawSlot returns a raw storage slot value for a contract.
 func (c *Cache) GetRawSlot(addr common.Address, slot common.Hash) (common.Hash, error) {
 	state, err := c.GetContractState(addr)
 	if err != nil {
 		return common.Hash{}, err
 	}
-	value, ok := state.RawSlots[slot]
+	value, ok := state.rawSlots[slot]
 	if !ok {
 		return common.Hash{}, fmt.Errorf("slot %s not found", slot.Hex())
 	}
 	return value, nil
 }
 
-// GetStatistics returns the current cache statistics.
-func (c *Cache) GetStatistics() Statistics {
-	return c.stats
+// GetMappingSlot returns the cached value of the mapping entry watched with
+// WatchMappingSlot for the same mapping slot and keys.
+func (c *Cache) GetMappingSlot(addr common.Address, mappingSlot common.Hash, keys ...[]byte) (common.Hash, error) {
+	return c.GetRawSlot(addr, NestedMappingSlot(mappingSlot, keys...))
+}
+
+// GetStorageAt returns a raw storage slot value if the current snapshot was
+// built for the given block hash and holds the slot. Otherwise it reports false
+// and counts a fallback, the caller is expected to read the canonical state.
+func (c *Cache) GetStorageAt(blockHash common.Hash, addr common.Address, slot common.Hash) (common.Hash, bool) {
+	snapshot := c.GetSnapshot()
+	if snapshot.BlockHash == blockHash || c.GetSnapshotByHash(blockHash) == snapshot {
+		if state, ok := snapshot.Contracts[addr]; ok {
+			if value, ok := state.rawSlots[slot]; ok {
+				c.stats.Hits.Add(1)
+				if c.metrics != nil {
+					c.metrics.hits.Inc(1)
+				}
+				return value, true
+			}
+		}
+	}
+	c.stats.Fallbacks.Add(1)
+	if c.metrics != nil {
+		c.metrics.fallbacks.Inc(1)
+	}
+	return common.Hash{}, false
+}
+
+// GetStatistics returns a snapshot of the current cache statistics.
+func (c *Cache) GetStatistics() StatisticsSnapshot {
+	return c.stats.snapshot()
+}
+
+// LastUpdateStats returns the number of contracts updated, skipped and failed
+// by the most recent update, including updates that were aborted. Comparing
+// Updated with Skipped shows the work saved by UpdateIncremental. The zero
+// value is returned before the first update.
+func (c *Cache) LastUpdateStats() UpdateStats {
+	if stats := c.lastUpdate.Load(); stats != nil {
+		return *stats
+	}
+	return UpdateStats{}
+}
+
+// EstimatedReadsSaved returns the number of state reads served by the cache
+// instead of the state trie, i.e. the hit count.
+func (c *Cache) EstimatedReadsSaved() uint64 {
+	return c.stats.Hits.Load()
+}
+
+// EstimatedTimeSaved roughly estimates the time saved by the cache, assuming
+// every hit spared a state read costing perReadNanos nanoseconds. The
+// estimate saturates at the maximum duration.
+func (c *Cache) EstimatedTimeSaved(perReadNanos uint64) time.Duration {
+	hi, lo := bits.Mul64(c.EstimatedReadsSaved(), perReadNanos)
+	if hi != 0 || lo > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(lo)
 }
 
 // ContractDecoder defines the interface for decoding contract-specific state.
@@ -248,3 +1248,98 @@ type ContractDecoder interface {
 	RequiredSlots() []common.Hash
 }
 
+// DependentSlotDecoder is implemented by decoders whose storage locations depend
+// on the values of other slots, e.g. an array entry indexed by a counter. The
+// cache first reads RequiredSlots, then the slots returned by RequiredSlotsFor,
+// and decodes the union of both.
+type DependentSlotDecoder interface {
+	ContractDecoder
+	
+	// RequiredSlotsFor returns the additional storage slots needed for decoding
+	// given the values of the slots returned by RequiredSlots
+	RequiredSlotsFor(slots map[common.Hash]common.Hash) []common.Hash
+}
+
+// OptionalSlotDecoder is implemented by decoders that can make use of slots
+// they do not need, e.g. fields only some forks of a contract set. Optional
+// slots are read after RequiredSlots unless Config.SkipOptionalSlots is set,
+// and Decode must not fail if they are absent.
+type OptionalSlotDecoder interface {
+	ContractDecoder
+	
+	// OptionalSlots returns the storage slots decoded if present
+	OptionalSlots() []common.Hash
+}
+
+// ExternalStorageDecoder is implemented by decoders whose state is held by
+// another contract, e.g. a pool whose balances live in a vault. All raw slots
+// of the contract are read from, and validated against, StorageAddress.
+type ExternalStorageDecoder interface {
+	ContractDecoder
+	
+	// StorageAddress returns the contract holding the decoded storage
+	StorageAddress() common.Address
+}
+
+// StateDecoder is implemented by decoders that need more than the contract's
+// storage, e.g. the native ETH balance of a pool or its code. If the
+// StateReader passed to the cache implements ExtendedStateReader, DecodeState
+// is called instead of Decode, otherwise Decode is used as for any decoder.
+//
+// Values read through the reader are not part of the raw slots, so they are not
+// checked by validation and changes to them do not count as touching the
+// contract for UpdateIncremental.
+type StateDecoder interface {
+	ContractDecoder
+	
+	// DecodeState decodes the contract at addr from its raw storage slots and
+	// any further state obtained from the reader
+	DecodeState(addr common.Address, slots map[common.Hash]common.Hash, state ExtendedStateReader) (interface{}, error)
+}
+
+// NativeBalanceDecoder is implemented by decoders that need the contract's
+// native balance, e.g. WETH whose supply is its ETH balance. If the StateReader
+// passed to the cache implements ExtendedStateReader, the balance is read into
+// ContractState.NativeBalance and DecodeBalance is called instead of Decode;
+// otherwise DecodeBalance is called with a nil balance.
+//
+// Like values read by a StateDecoder, the balance is not checked by validation
+// and balance changes do not count as touching the contract for
+// UpdateIncremental.
+type NativeBalanceDecoder interface {
+	ContractDecoder
+	
+	// DecodeBalance decodes raw storage slots and the contract's native
+	// balance, which is nil if unavailable
+	DecodeBalance(slots map[common.Hash]common.Hash, balance *big.Int) (interface{}, error)
+}
+
+// StatelessDecoder is implemented by decoders that keep no state besides their
+// configuration, so that instances of the same type and configuration are
+// interchangeable. RegisterDecoder shares a single instance among all
+// contracts registered with such decoders, saving memory on large watchlists.
+type StatelessDecoder interface {
+	ContractDecoder
+	
+	// Stateless reports whether the decoder may be shared
+	Stateless() bool
+}
+
+// tokenDecimalsState is implemented by decoded states quoting token amounts.
+// They are annotated with the decimals registered through SetTokenDecimals.
+type tokenDecimalsState interface {
+	setTokenDecimals(lookup func(token common.Address) (uint8, bool))
+}
+
+// ForeignSlotDecoder is implemented by decoders that additionally need slots of
+// other contracts, e.g. a lending market whose cash is its balance in the
+// underlying token. The foreign values are stored in the raw slots under their
+// slot keys, which must not collide with the contract's own slots; mapping
+// entries are hashed and thus safe.
+type ForeignSlotDecoder interface {
+	ContractDecoder
+	
+	// ForeignSlots returns the additional slots to read, each mapped to the
+	// contract holding it
+	ForeignSlots() map[common.Hash]common.Address
+}
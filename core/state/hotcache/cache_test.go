@@ -17,7 +17,11 @@
 package hotcache
 
 import (
+	"errors"
+	"math"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -109,10 +113,71 @@ func TestRegisterDecoder(t *testing.T) {
 	addr := common.HexToAddress("0x1")
 	decoder := &UniswapV2Decoder{}
 	
+	if cache.HasDecoder(addr) {
+		t.Fatal("Expected no decoder before registration")
+	}
 	cache.RegisterDecoder(addr, decoder)
-	
-	// Verify decoder was registered (internal state, can't directly test)
-	// Just ensure no panic
+	if !cache.HasDecoder(addr) {
+		t.Fatal("Expected decoder after registration")
+	}
+	cache.UnregisterDecoder(addr)
+	if cache.HasDecoder(addr) {
+		t.Fatal("Expected no decoder after unregistration")
+	}
+	// Unregistering an unknown address is a no-op
+	cache.UnregisterDecoder(addr)
+}
+
+func TestRequiredSlots(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	if _, ok := cache.RequiredSlots(pool, newMockStateReader()); ok {
+		t.Error("Expected no slots without a decoder")
+	}
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	cache.WatchSlots(pool, []common.Hash{common.HexToHash("0xff")})
+	slots, ok := cache.RequiredSlots(pool, newMockStateReader())
+	if !ok || len(slots) != 6 {
+		t.Fatalf("Expected the 6 V2 slots, got %v", slots)
+	}
+	for i, slot := range uniswapV2Layout.Slots() {
+		if slots[i] != slot {
+			t.Errorf("Slot %d: expected %s, got %s", i, slot.Hex(), slots[i].Hex())
+		}
+	}
+}
+
+func TestUnregisterDecoderKeepsRawSlots(t *testing.T) {
+	addr := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	custom := common.HexToHash("0xff")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	cache.RegisterDecoder(addr, &UniswapV2Decoder{})
+	cache.WatchSlots(addr, []common.Hash{custom})
+
+	reader := newMockStateReader()
+	reader.setState(addr, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	reader.setState(addr, custom, common.HexToHash("0x01"))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if state, _ := cache.GetContractState(addr); state.Decoded == nil {
+		t.Fatal("Expected decoded state with a decoder")
+	}
+
+	cache.UnregisterDecoder(addr)
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(addr)
+	if err != nil {
+		t.Fatalf("Contract no longer cached: %v", err)
+	}
+	if state.Decoded != nil || state.Type != ContractTypeUnknown {
+		t.Errorf("Expected undecoded state, got type %v decoded %v", state.Type, state.Decoded)
+	}
+	if len(state.rawSlots) != 1 || state.rawSlots[custom] != common.HexToHash("0x01") {
+		t.Errorf("Expected only the custom slot, got %v", state.rawSlots)
+	}
 }
 
 func TestGetStatistics(t *testing.T) {
@@ -125,14 +190,51 @@ func TestGetStatistics(t *testing.T) {
 	stats := cache.GetStatistics()
 	
 	// Initially all stats should be 0
-	if stats.Hits.Load() != 0 {
-		t.Errorf("Expected 0 hits, got %d", stats.Hits.Load())
+	if stats.Hits != 0 {
+		t.Errorf("Expected 0 hits, got %d", stats.Hits)
 	}
-	if stats.Misses.Load() != 0 {
-		t.Errorf("Expected 0 misses, got %d", stats.Misses.Load())
+	if stats.Misses != 0 {
+		t.Errorf("Expected 0 misses, got %d", stats.Misses)
 	}
-	if stats.Updates.Load() != 0 {
-		t.Errorf("Expected 0 updates, got %d", stats.Updates.Load())
+	if stats.Updates != 0 {
+		t.Errorf("Expected 0 updates, got %d", stats.Updates)
+	}
+
+	// Returned statistics are a copy and reflect later activity only on re-read
+	cache.GetContractState(common.HexToAddress("0x1"))
+	if stats.Misses != 0 {
+		t.Errorf("Expected snapshot to be unaffected, got %d misses", stats.Misses)
+	}
+	if misses := cache.GetStatistics().Misses; misses != 1 {
+		t.Errorf("Expected 1 miss, got %d", misses)
+	}
+}
+
+func TestEstimatedTimeSaved(t *testing.T) {
+	pool := common.HexToAddress("0x1")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.current.Store(&Snapshot{Contracts: map[common.Address]*ContractState{
+		pool: {Address: pool},
+	}})
+	if saved := cache.EstimatedTimeSaved(1000); saved != 0 {
+		t.Errorf("Expected nothing saved without hits, got %v", saved)
+	}
+	for i := 1; i <= 3; i++ {
+		cache.GetContractState(pool)
+		if reads := cache.EstimatedReadsSaved(); reads != uint64(i) {
+			t.Errorf("Expected %d reads saved, got %d", i, reads)
+		}
+		if saved := cache.EstimatedTimeSaved(1500); saved != time.Duration(i)*1500*time.Nanosecond {
+			t.Errorf("Expected %d reads of 1.5µs saved, got %v", i, saved)
+		}
+	}
+	// Misses save nothing
+	cache.GetContractState(common.HexToAddress("0x2"))
+	if reads := cache.EstimatedReadsSaved(); reads != 3 {
+		t.Errorf("Expected misses not to count, got %d reads saved", reads)
+	}
+	if saved := cache.EstimatedTimeSaved(math.MaxUint64); saved != time.Duration(math.MaxInt64) {
+		t.Errorf("Expected saturated estimate, got %v", saved)
 	}
 }
 
@@ -170,3 +272,492 @@ func BenchmarkGetContractState(b *testing.B) {
 	}
 }
 
+
+func TestIsStale(t *testing.T) {
+	cache := New(Config{Enabled: true})
+	if !cache.IsStale(0, 10) {
+		t.Error("Expected cache without any block to be stale")
+	}
+	if err := cache.Update(testHeader(100), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	tests := []struct {
+		head, maxLag uint64
+		stale        bool
+	}{
+		{100, 0, false}, // at head
+		{101, 0, true},  // one block behind with no tolerance
+		{102, 2, false}, // lag == maxLag
+		{103, 2, true},  // lag == maxLag+1
+		{99, 0, false},  // cache ahead of the reported head
+	}
+	for _, tt := range tests {
+		if stale := cache.IsStale(tt.head, tt.maxLag); stale != tt.stale {
+			t.Errorf("head %d, maxLag %d: expected stale %v, got %v", tt.head, tt.maxLag, tt.stale, stale)
+		}
+	}
+}
+
+func TestSnapshotAge(t *testing.T) {
+	snapshot := &Snapshot{BlockTime: 1000}
+	if age := snapshot.Age(1012); age != 12 {
+		t.Errorf("Expected age 12, got %d", age)
+	}
+	if age := snapshot.Age(1000); age != 0 {
+		t.Errorf("Expected age 0 at block time, got %d", age)
+	}
+	if age := snapshot.Age(900); age != 0 {
+		t.Errorf("Expected age 0 for clock behind block time, got %d", age)
+	}
+}
+
+func TestLatestFresh(t *testing.T) {
+	cache := New(Config{Enabled: true})
+	if _, fresh := cache.LatestFresh(time.Hour); fresh {
+		t.Error("Expected no fresh snapshot before the first update")
+	}
+	now := uint64(time.Now().Unix())
+	cache.current.Store(&Snapshot{BlockNumber: 1, BlockHash: common.HexToHash("0x1"), BlockTime: now})
+	if snapshot, fresh := cache.LatestFresh(time.Minute); !fresh || snapshot.BlockNumber != 1 {
+		t.Errorf("Expected the current snapshot to be fresh, got %v", snapshot)
+	}
+	// The chain halted a minute ago
+	cache.current.Store(&Snapshot{BlockNumber: 2, BlockHash: common.HexToHash("0x2"), BlockTime: now - 60})
+	if snapshot, fresh := cache.LatestFresh(500 * time.Millisecond); fresh || snapshot != nil {
+		t.Errorf("Expected a stale snapshot, got %v", snapshot)
+	}
+}
+
+func TestGetContractStateCopy(t *testing.T) {
+	addr := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	cache.RegisterDecoder(addr, &UniswapV2Decoder{})
+
+	reader := newMockStateReader()
+	reader.setState(addr, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// Mutating the shared state leaks into every other reader
+	shared, _ := cache.GetContractState(addr)
+	shared.rawSlots[uniswapV2SlotKLast] = common.HexToHash("0xdead")
+	if again, _ := cache.GetContractState(addr); again.rawSlots[uniswapV2SlotKLast] != common.HexToHash("0xdead") {
+		t.Fatal("Expected GetContractState to alias the snapshot")
+	}
+	delete(shared.rawSlots, uniswapV2SlotKLast)
+
+	// Mutating a copy does not
+	cpy, err := cache.GetContractStateCopy(addr)
+	if err != nil {
+		t.Fatalf("GetContractStateCopy failed: %v", err)
+	}
+	cpy.rawSlots[uniswapV2SlotKLast] = common.HexToHash("0xdead")
+	cpy.Decoded.(*UniswapV2State).Reserve0.SetInt64(1)
+
+	state, _ := cache.GetContractState(addr)
+	if state.rawSlots[uniswapV2SlotKLast] != (common.Hash{}) {
+		t.Error("Copy shares raw slots with the snapshot")
+	}
+	if reserve0 := state.Decoded.(*UniswapV2State).Reserve0; reserve0.Int64() != 1000 {
+		t.Errorf("Copy shares decoded state with the snapshot, reserve0 %s", reserve0)
+	}
+	if _, err := cache.GetContractStateCopy(common.HexToAddress("0x1")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestContractStateForEachSlot(t *testing.T) {
+	addr := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	cache.RegisterDecoder(addr, &UniswapV2Decoder{})
+
+	reader := newMockStateReader()
+	reader.setState(addr, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, _ := cache.GetContractState(addr)
+	required := uniswapV2Layout.Slots()
+	if state.SlotCount() != len(required) {
+		t.Fatalf("Expected %d slots, got %d", len(required), state.SlotCount())
+	}
+	seen := make(map[common.Hash]common.Hash)
+	state.ForEachSlot(func(slot, value common.Hash) {
+		seen[slot] = value
+		// The callback only receives copies of the cached values
+		value[31] = 0xff
+	})
+	for _, slot := range required {
+		if _, ok := seen[slot]; !ok {
+			t.Errorf("Slot %s not visited", slot.Hex())
+		}
+	}
+	if seen[uniswapV2SlotReserves] != common.BigToHash(big.NewInt(1000)) {
+		t.Errorf("Unexpected reserves slot %s", seen[uniswapV2SlotReserves].Hex())
+	}
+	if value, _ := cache.GetRawSlot(addr, uniswapV2SlotReserves); value != common.BigToHash(big.NewInt(1000)) {
+		t.Errorf("Snapshot slot was mutated through ForEachSlot: %s", value.Hex())
+	}
+}
+
+func TestGetContractStateNotPopulated(t *testing.T) {
+	var (
+		watched = common.HexToAddress("0x1")
+		unknown = common.HexToAddress("0x2")
+		slot    = common.BigToHash(big.NewInt(1))
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{watched}})
+	cache.RegisterDecoder(watched, &rawSlotDecoder{slots: []common.Hash{slot}})
+
+	// Watched but not yet updated
+	if _, err := cache.GetContractState(watched); !errors.Is(err, ErrNotPopulated) {
+		t.Errorf("Expected ErrNotPopulated before the first update, got %v", err)
+	}
+	if _, err := cache.GetContractState(unknown); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unwatched contract, got %v", err)
+	}
+	// Populated
+	if err := cache.Update(testHeader(1), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := cache.GetContractState(watched); err != nil {
+		t.Errorf("Expected cached contract, got %v", err)
+	}
+	if _, err := cache.GetRawSlot(unknown, slot); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unwatched slot, got %v", err)
+	}
+}
+
+// testContractType is registered once per test binary, as registrations are
+// global and cannot be undone.
+var testContractType = RegisterContractType("TestCustomPool")
+
+func TestRegisterContractType(t *testing.T) {
+	if testContractType < firstCustomContractType {
+		t.Fatalf("Custom type %d collides with the built-in types", testContractType)
+	}
+	if name := testContractType.String(); name != "TestCustomPool" {
+		t.Errorf("Expected name TestCustomPool, got %q", name)
+	}
+	text, err := testContractType.MarshalText()
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var decoded ContractType
+	if err := decoded.UnmarshalText(text); err != nil || decoded != testContractType {
+		t.Errorf("Round trip returned %v (err %v)", decoded, err)
+	}
+	if name := ContractTypeUniswapV2.String(); name != "UniswapV2" {
+		t.Errorf("Built-in type renamed to %q", name)
+	}
+	if name := ContractType(255).String(); name != "Unknown" {
+		t.Errorf("Expected unassigned type to be Unknown, got %q", name)
+	}
+
+	for _, name := range []string{"TestCustomPool", "UniswapV2"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected duplicate registration of %s to panic", name)
+				}
+			}()
+			RegisterContractType(name)
+		}()
+	}
+}
+
+func TestGetContractStateWithBlock(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+
+	reader := newMockStateReader()
+	for n := uint64(1); n <= 2; n++ {
+		header := testHeader(n)
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		state, hash, number, err := cache.GetContractStateWithBlock(pool)
+		if err != nil {
+			t.Fatalf("Contract not cached: %v", err)
+		}
+		snapshot := cache.GetSnapshot()
+		if hash != header.Hash() || number != n || hash != snapshot.BlockHash || number != snapshot.BlockNumber {
+			t.Errorf("Expected block %d (%s), got %d (%s)", n, header.Hash().Hex(), number, hash.Hex())
+		}
+		if state != snapshot.Contracts[pool] {
+			t.Error("Expected the state of the returned block's snapshot")
+		}
+	}
+	if _, _, _, err := cache.GetContractStateWithBlock(common.HexToAddress("0x1")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"valid", Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{pool}}, true},
+		{"disabled", Config{}, true},
+		{"enabled with empty watchlist", Config{Enabled: true}, false},
+		{"duplicate address", Config{Enabled: true, Watchlist: []common.Address{pool, pool}}, false},
+		{"zero address", Config{Enabled: true, Watchlist: []common.Address{{}}}, false},
+		{"negative max snapshots", Config{Enabled: true, Watchlist: []common.Address{pool}, MaxSnapshots: -1}, false},
+		{"shadow mode while disabled", Config{ShadowMode: true}, true},
+		{"default", DefaultConfig(), true},
+		{"negative sample rate", Config{Enabled: true, Watchlist: []common.Address{pool}, ValidationSampleRate: -0.1}, false},
+		{"sample rate above one", Config{Enabled: true, Watchlist: []common.Address{pool}, ValidationSampleRate: 1.5}, false},
+		{"negative max active contracts", Config{Enabled: true, Watchlist: []common.Address{pool}, MaxActiveContracts: -1}, false},
+		{"negative max watchlist", Config{Enabled: true, Watchlist: []common.Address{pool}, MaxWatchlist: -1}, false},
+	}
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", tt.name, err)
+		}
+		cache, err := NewWithError(tt.config)
+		if tt.valid != (err == nil) || tt.valid != (cache != nil) {
+			t.Errorf("%s: NewWithError returned cache %v, err %v", tt.name, cache != nil, err)
+		}
+	}
+}
+
+func TestNewDedupsWatchlist(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool, pool}})
+	if watchlist := cache.Watchlist(); len(watchlist) != 1 || watchlist[0] != pool {
+		t.Errorf("Expected deduplicated watchlist, got %v", watchlist)
+	}
+	if len(cache.config.Watchlist) != 1 {
+		t.Errorf("Expected deduplicated config watchlist, got %v", cache.config.Watchlist)
+	}
+}
+
+func TestMaxWatchlist(t *testing.T) {
+	var (
+		a = common.HexToAddress("0x1")
+		b = common.HexToAddress("0x2")
+		c = common.HexToAddress("0x3")
+	)
+	// Oversized configured watchlists are truncated, keeping the first entries
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{a, b, c}, MaxWatchlist: 2})
+	if watchlist := cache.Watchlist(); len(watchlist) != 2 || !cache.IsWatched(a) || !cache.IsWatched(b) {
+		t.Fatalf("Expected watchlist truncated to %s and %s, got %v", a.Hex(), b.Hex(), watchlist)
+	}
+	if len(cache.config.Watchlist) != 2 {
+		t.Errorf("Expected truncated config watchlist, got %v", cache.config.Watchlist)
+	}
+
+	// Additions beyond the cap are rejected, re-adding watched ones is not
+	if err := cache.AddToWatchlist(c); !errors.Is(err, ErrWatchlistFull) {
+		t.Errorf("Expected ErrWatchlistFull, got %v", err)
+	}
+	if err := cache.AddToWatchlist(a); err != nil {
+		t.Errorf("Expected re-adding a watched contract to succeed, got %v", err)
+	}
+	if err := cache.WatchSlots(c, []common.Hash{{0x1}}); !errors.Is(err, ErrWatchlistFull) {
+		t.Errorf("Expected ErrWatchlistFull watching slots, got %v", err)
+	}
+	if cache.IsWatched(c) || len(cache.customSlots[c]) != 0 {
+		t.Error("Rejected contract left watched or with custom slots")
+	}
+
+	// Replacements beyond the cap are rejected as a whole
+	if err := cache.ReplaceWatchlist([]common.Address{a, b, c}, nil); !errors.Is(err, ErrWatchlistFull) {
+		t.Errorf("Expected ErrWatchlistFull replacing the watchlist, got %v", err)
+	}
+	if watchlist := cache.Watchlist(); len(watchlist) != 2 || cache.IsWatched(c) {
+		t.Errorf("Expected rejected replacement to keep the watchlist, got %v", watchlist)
+	}
+	if err := cache.ReplaceWatchlist([]common.Address{b, c, c}, nil); err != nil {
+		t.Errorf("Expected replacement within the cap to succeed, got %v", err)
+	}
+	if err := cache.AddToWatchlist(a); !errors.Is(err, ErrWatchlistFull) {
+		t.Errorf("Expected ErrWatchlistFull after replacement, got %v", err)
+	}
+}
+
+func TestAddToWatchlistIdempotent(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	cache.AddToWatchlist(pool)
+	cache.AddToWatchlist(pool)
+	if watchlist := cache.Watchlist(); len(watchlist) != 1 {
+		t.Fatalf("Expected a single watched contract, got %v", watchlist)
+	}
+	if err := cache.Update(testHeader(1), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if contracts := len(cache.GetSnapshot().Contracts); contracts != 1 {
+		t.Errorf("Expected 1 cached contract, got %d", contracts)
+	}
+	if _, err := cache.GetContractState(pool); err != nil {
+		t.Fatalf("Contract not cached: %v", err)
+	}
+	if stats := cache.GetStatistics(); stats.Hits != 1 || stats.Updates != 1 {
+		t.Errorf("Expected 1 hit and 1 update, got %+v", stats)
+	}
+	if watched := cache.metrics.watchlist.Snapshot().Value(); watched != 1 {
+		t.Errorf("Expected watchlist gauge 1, got %d", watched)
+	}
+}
+
+func TestGetStorageAt(t *testing.T) {
+	addr := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	cache.RegisterDecoder(addr, &UniswapV2Decoder{})
+
+	reader := newMockStateReader()
+	reader.setState(addr, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	header := testHeader(1)
+	if err := cache.Update(header, reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if value, ok := cache.GetStorageAt(header.Hash(), addr, uniswapV2SlotReserves); !ok || value != common.BigToHash(big.NewInt(1000)) {
+		t.Errorf("Expected cached reserves, got %x (hit %v)", value, ok)
+	}
+	// Unknown slots, unwatched contracts and other blocks fall back
+	misses := []struct {
+		block common.Hash
+		addr  common.Address
+		slot  common.Hash
+	}{
+		{header.Hash(), addr, common.HexToHash("0xff")},
+		{header.Hash(), common.HexToAddress("0x1"), uniswapV2SlotReserves},
+		{testHeader(2).Hash(), addr, uniswapV2SlotReserves},
+	}
+	for i, miss := range misses {
+		if _, ok := cache.GetStorageAt(miss.block, miss.addr, miss.slot); ok {
+			t.Errorf("miss %d: unexpected cache hit", i)
+		}
+	}
+	if stats := cache.GetStatistics(); stats.Hits != 1 || stats.Fallbacks != uint64(len(misses)) {
+		t.Errorf("Expected 1 hit and %d fallbacks, got %+v", len(misses), stats)
+	}
+}
+
+// staticReader is a fake Reader serving a fixed snapshot.
+type staticReader struct {
+	snapshot *Snapshot
+}
+
+func (r *staticReader) GetSnapshot() *Snapshot { return r.snapshot }
+
+func (r *staticReader) GetContractState(addr common.Address) (*ContractState, error) {
+	if state, ok := r.snapshot.Contracts[addr]; ok {
+		return state, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *staticReader) GetRawSlot(addr common.Address, slot common.Hash) (common.Hash, error) {
+	state, err := r.GetContractState(addr)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return state.rawSlots[slot], nil
+}
+
+func (r *staticReader) IsWatched(addr common.Address) bool {
+	_, ok := r.snapshot.Contracts[addr]
+	return ok
+}
+
+func (r *staticReader) GetStatistics() StatisticsSnapshot { return StatisticsSnapshot{} }
+
+// readReserve0 is a consumer depending only on the Reader interface.
+func readReserve0(reader Reader, pool common.Address) (*big.Int, error) {
+	state, err := reader.GetContractState(pool)
+	if err != nil {
+		return nil, err
+	}
+	v2, ok := state.Decoded.(*UniswapV2State)
+	if !ok {
+		return nil, errors.New("not a V2 pool")
+	}
+	return v2.Reserve0, nil
+}
+
+func TestReader(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+
+	fake := &staticReader{snapshot: &Snapshot{Contracts: map[common.Address]*ContractState{
+		pool: {Address: pool, Decoded: &UniswapV2State{Reserve0: big.NewInt(42)}},
+	}}}
+	if reserve0, err := readReserve0(fake, pool); err != nil || reserve0.Int64() != 42 {
+		t.Errorf("Unexpected reserve from fake reader: %v, %v", reserve0, err)
+	}
+
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	reader := newMockStateReader()
+	reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if reserve0, err := readReserve0(cache, pool); err != nil || reserve0.Int64() != 1000 {
+		t.Errorf("Unexpected reserve from cache: %v, %v", reserve0, err)
+	}
+}
+
+func TestRegisterDecoderInterning(t *testing.T) {
+	var (
+		pair1 = common.HexToAddress("0x1")
+		pair2 = common.HexToAddress("0x2")
+		sushi = common.HexToAddress("0x3")
+		raw1  = common.HexToAddress("0x4")
+		raw2  = common.HexToAddress("0x5")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pair1, pair2, sushi, raw1, raw2}})
+	cache.RegisterDecoder(pair1, &UniswapV2Decoder{})
+	cache.RegisterDecoder(pair2, &UniswapV2Decoder{})
+	cache.RegisterDecoder(sushi, NewUniswapV2ForkDecoder(ContractTypeSushiSwap))
+	cache.RegisterDecoder(raw1, &rawSlotDecoder{})
+	cache.RegisterDecoder(raw2, &rawSlotDecoder{})
+
+	cache.decoderMu.RLock()
+	defer cache.decoderMu.RUnlock()
+	if cache.decoders[pair1] != cache.decoders[pair2] {
+		t.Error("Expected identical stateless decoders to share one instance")
+	}
+	if cache.decoders[pair1] == cache.decoders[sushi] {
+		t.Error("Expected differently configured decoders to stay separate")
+	}
+	if cache.decoders[raw1] == cache.decoders[raw2] {
+		t.Error("Expected decoders not declaring themselves stateless to stay separate")
+	}
+}
+
+func TestGetContractsByType(t *testing.T) {
+	var (
+		pool1 = common.HexToAddress("0x3")
+		pool2 = common.HexToAddress("0x1")
+		other = common.HexToAddress("0x2")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool1, pool2, other}})
+	cache.current.Store(&Snapshot{Contracts: map[common.Address]*ContractState{
+		pool1: {Address: pool1, Type: ContractTypeUniswapV2, Decoded: &UniswapV2State{}},
+		pool2: {Address: pool2, Type: ContractTypeUniswapV2, Decoded: &UniswapV2State{}},
+		other: {Address: other, Type: ContractTypeUnknown},
+	}})
+
+	pools := cache.GetContractsByType(ContractTypeUniswapV2)
+	if len(pools) != 2 || pools[0].Address != pool2 || pools[1].Address != pool1 {
+		t.Fatalf("Expected both V2 pools sorted by address, got %v", pools)
+	}
+	if unknown := cache.GetContractsByType(ContractTypeUnknown); len(unknown) != 1 || unknown[0].Address != other {
+		t.Errorf("Expected the unknown contract only, got %v", unknown)
+	}
+	if v3 := cache.GetContractsByType(ContractTypeUniswapV3); len(v3) != 0 {
+		t.Errorf("Expected no V3 pools, got %v", v3)
+	}
+}
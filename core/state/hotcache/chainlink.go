@@ -0,0 +1,138 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Chainlink OffchainAggregator storage layout (relevant parts):
+// s_hotVars: packed, from the least significant bit:
+//            latestConfigDigest (bytes16), latestEpochAndRound (uint40),
+//            threshold (uint8), latestAggregatorRoundId (uint32)
+// s_transmissions: mapping(uint32 => Transmission), where each Transmission
+//            packs answer (int192) in the low 192 bits and timestamp (uint64)
+//            in the high 64 bits of a single slot.
+//
+// The transmission slot depends on the latest round id, so decoding takes two
+// reads: the hot vars slot first, then keccak256(pad32(roundId) . pad32(transmissionsSlot)).
+
+var (
+	chainlinkMask192 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 192), big.NewInt(1))
+	chainlinkSign192 = new(big.Int).Lsh(big.NewInt(1), 191)
+	chainlinkMod192  = new(big.Int).Lsh(big.NewInt(1), 192)
+)
+
+//go:generate go run github.com/fjl/gencodec -type ChainlinkState -field-override chainlinkStateMarshaling -out gen_chainlink_json.go
+
+// ChainlinkState represents the latest round of a Chainlink aggregator.
+type ChainlinkState struct {
+	Answer    *big.Int `json:"answer"` // int192
+	UpdatedAt uint64   `json:"updatedAt"`
+	RoundID   uint64   `json:"roundId"`
+}
+
+// chainlinkStateMarshaling renders big integers as decimal strings.
+type chainlinkStateMarshaling struct {
+	Answer *math.Decimal256
+}
+
+// String returns a human-readable representation of the feed state.
+func (s *ChainlinkState) String() string {
+	return fmt.Sprintf("Chainlink{answer: %s, updatedAt: %d, roundId: %d}", s.Answer.String(), s.UpdatedAt, s.RoundID)
+}
+
+// Copy returns a deep copy of the feed state.
+func (s *ChainlinkState) Copy() *ChainlinkState {
+	cpy := *s
+	cpy.Answer = copyBig(s.Answer)
+	return &cpy
+}
+
+// ChainlinkDecoder decodes the latest round of a Chainlink OffchainAggregator.
+type ChainlinkDecoder struct {
+	hotVarsSlot       common.Hash
+	transmissionsSlot common.Hash
+}
+
+// NewChainlinkDecoder creates a decoder for an aggregator storing its hot vars
+// and transmissions mapping at the given slots.
+func NewChainlinkDecoder(hotVarsSlot, transmissionsSlot common.Hash) *ChainlinkDecoder {
+	return &ChainlinkDecoder{
+		hotVarsSlot:       hotVarsSlot,
+		transmissionsSlot: transmissionsSlot,
+	}
+}
+
+// Type returns the contract type.
+func (d *ChainlinkDecoder) Type() ContractType {
+	return ContractTypeChainlink
+}
+
+// RequiredSlots returns the storage slots needed to locate the latest round.
+func (d *ChainlinkDecoder) RequiredSlots() []common.Hash {
+	return []common.Hash{d.hotVarsSlot}
+}
+
+// RequiredSlotsFor returns the transmission slot of the latest round.
+func (d *ChainlinkDecoder) RequiredSlotsFor(slots map[common.Hash]common.Hash) []common.Hash {
+	hotVars, ok := slots[d.hotVarsSlot]
+	if !ok {
+		return nil
+	}
+	return []common.Hash{d.transmissionSlot(chainlinkRoundID(hotVars))}
+}
+
+// transmissionSlot computes the storage slot of the given round's transmission.
+func (d *ChainlinkDecoder) transmissionSlot(roundID uint32) common.Hash {
+	return MappingSlot(common.BigToHash(new(big.Int).SetUint64(uint64(roundID))).Bytes(), d.transmissionsSlot)
+}
+
+// chainlinkRoundID extracts latestAggregatorRoundId (bits 176..207) from the
+// hot vars slot.
+func chainlinkRoundID(hotVars common.Hash) uint32 {
+	return binary.BigEndian.Uint32(hotVars[6:10])
+}
+
+// Decode decodes raw storage slots into ChainlinkState.
+func (d *ChainlinkDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	hotVars, ok := slots[d.hotVarsSlot]
+	if !ok {
+		return nil, fmt.Errorf("%w: hot vars", ErrMissingSlot)
+	}
+	roundID := chainlinkRoundID(hotVars)
+
+	transmission, ok := slots[d.transmissionSlot(roundID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: transmission of round %d", ErrMissingSlot, roundID)
+	}
+	// Answer is a signed 192-bit integer in the low bits, sign-extend it
+	answer := new(big.Int).And(transmission.Big(), chainlinkMask192)
+	if answer.Cmp(chainlinkSign192) >= 0 {
+		answer.Sub(answer, chainlinkMod192)
+	}
+	return &ChainlinkState{
+		Answer:    answer,
+		UpdatedAt: binary.BigEndian.Uint64(transmission[0:8]),
+		RoundID:   uint64(roundID),
+	}, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	chainlinkTestHotVarsSlot       = common.BigToHash(big.NewInt(43))
+	chainlinkTestTransmissionsSlot = common.BigToHash(big.NewInt(44))
+)
+
+// chainlinkHotVars packs a round id into the latestAggregatorRoundId field,
+// leaving junk in the lower fields to check they are masked off.
+func chainlinkHotVars(roundID uint32) common.Hash {
+	var h common.Hash
+	for i := 10; i < 32; i++ {
+		h[i] = 0xaa
+	}
+	h[6], h[7], h[8], h[9] = byte(roundID>>24), byte(roundID>>16), byte(roundID>>8), byte(roundID)
+	return h
+}
+
+// chainlinkTransmission packs an int192 answer and uint64 timestamp.
+func chainlinkTransmission(answer *big.Int, timestamp uint64) common.Hash {
+	packed := new(big.Int).Lsh(new(big.Int).SetUint64(timestamp), 192)
+	packed.Or(packed, new(big.Int).And(new(big.Int).Add(answer, chainlinkMod192), chainlinkMask192))
+	return common.BigToHash(packed)
+}
+
+func TestChainlinkDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		roundID   uint32
+		answer    *big.Int
+		timestamp uint64
+	}{
+		{"positive answer", 19523, big.NewInt(200012345678), 1700000000},
+		{"negative answer", 7, big.NewInt(-42), 1700000123},
+	}
+	decoder := NewChainlinkDecoder(chainlinkTestHotVarsSlot, chainlinkTestTransmissionsSlot)
+
+	for _, tt := range tests {
+		hotVars := chainlinkHotVars(tt.roundID)
+		slots := map[common.Hash]common.Hash{chainlinkTestHotVarsSlot: hotVars}
+
+		extra := decoder.RequiredSlotsFor(slots)
+		if len(extra) != 1 {
+			t.Fatalf("%s: expected 1 dependent slot, got %d", tt.name, len(extra))
+		}
+		if _, err := decoder.Decode(slots); err == nil {
+			t.Errorf("%s: expected error for missing transmission slot", tt.name)
+		}
+		slots[extra[0]] = chainlinkTransmission(tt.answer, tt.timestamp)
+
+		decoded, err := decoder.Decode(slots)
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %v", tt.name, err)
+		}
+		state, ok := decoded.(*ChainlinkState)
+		if !ok {
+			t.Fatalf("%s: decoded value is not ChainlinkState", tt.name)
+		}
+		if state.Answer.Cmp(tt.answer) != 0 {
+			t.Errorf("%s: expected answer %s, got %s", tt.name, tt.answer, state.Answer)
+		}
+		if state.UpdatedAt != tt.timestamp {
+			t.Errorf("%s: expected updatedAt %d, got %d", tt.name, tt.timestamp, state.UpdatedAt)
+		}
+		if state.RoundID != uint64(tt.roundID) {
+			t.Errorf("%s: expected round id %d, got %d", tt.name, tt.roundID, state.RoundID)
+		}
+	}
+}
+
+func TestChainlinkUpdateReadsDependentSlots(t *testing.T) {
+	feed := common.HexToAddress("0x5f4ec3df9cbd43714fe2740f5e3616155c5b8419")
+	decoder := NewChainlinkDecoder(chainlinkTestHotVarsSlot, chainlinkTestTransmissionsSlot)
+
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{feed}})
+	cache.RegisterDecoder(feed, decoder)
+
+	reader := newMockStateReader()
+	reader.setState(feed, chainlinkTestHotVarsSlot, chainlinkHotVars(100))
+	reader.setState(feed, decoder.transmissionSlot(100), chainlinkTransmission(big.NewInt(3000), 1700000000))
+
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	state, err := cache.GetContractState(feed)
+	if err != nil {
+		t.Fatalf("failed to get state: %v", err)
+	}
+	decoded, ok := state.Decoded.(*ChainlinkState)
+	if !ok {
+		t.Fatalf("decoded value is not ChainlinkState: %v", state.Decoded)
+	}
+	if decoded.Answer.Int64() != 3000 || decoded.RoundID != 100 {
+		t.Errorf("unexpected feed state %v", decoded)
+	}
+}
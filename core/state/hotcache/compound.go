@@ -0,0 +1,165 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Compound V2 cToken (CErc20Delegator) storage layout (CTokenStorage):
+// slot 0: _notEntered (bool)
+// slot 1: name (string)
+// slot 2: symbol (string)
+// slot 3: decimals (uint8) + admin (address)
+// slot 4: pendingAdmin (address)
+// slot 5: comptroller (address)
+// slot 6: interestRateModel (address)
+// slot 7: initialExchangeRateMantissa (uint256)
+// slot 8: reserveFactorMantissa (uint256)
+// slot 9: accrualBlockNumber (uint256)
+// slot 10: borrowIndex (uint256)
+// slot 11: totalBorrows (uint256)
+// slot 12: totalReserves (uint256)
+// slot 13: totalSupply (uint256)
+//
+// The market's cash is its balance of the underlying token, which lives in the
+// underlying token's storage rather than the cToken's.
+
+var (
+	compoundSlotAccrualBlockNumber = common.BigToHash(big.NewInt(9))
+	compoundSlotTotalBorrows       = common.BigToHash(big.NewInt(11))
+	compoundSlotTotalReserves      = common.BigToHash(big.NewInt(12))
+	compoundSlotTotalSupply        = common.BigToHash(big.NewInt(13))
+)
+
+//go:generate go run github.com/fjl/gencodec -type CompoundState -field-override compoundStateMarshaling -out gen_compound_json.go
+
+// CompoundState represents the decoded state of a Compound V2 cToken market.
+type CompoundState struct {
+	Cash               *big.Int `json:"cash"`
+	TotalBorrows       *big.Int `json:"totalBorrows"`
+	TotalReserves      *big.Int `json:"totalReserves"`
+	TotalSupply        *big.Int `json:"totalSupply"`
+	AccrualBlockNumber uint64   `json:"accrualBlockNumber"`
+}
+
+// compoundStateMarshaling renders big integers as decimal strings.
+type compoundStateMarshaling struct {
+	Cash          *math.Decimal256
+	TotalBorrows  *math.Decimal256
+	TotalReserves *math.Decimal256
+	TotalSupply   *math.Decimal256
+}
+
+// String returns a human-readable representation of the market state.
+func (s *CompoundState) String() string {
+	return fmt.Sprintf("Compound{cash: %s, borrows: %s, reserves: %s, supply: %s, accrual: %d}",
+		s.Cash, s.TotalBorrows, s.TotalReserves, s.TotalSupply, s.AccrualBlockNumber)
+}
+
+// Copy returns a deep copy of the market state.
+func (s *CompoundState) Copy() *CompoundState {
+	return &CompoundState{
+		Cash:               copyBig(s.Cash),
+		TotalBorrows:       copyBig(s.TotalBorrows),
+		TotalReserves:      copyBig(s.TotalReserves),
+		TotalSupply:        copyBig(s.TotalSupply),
+		AccrualBlockNumber: s.AccrualBlockNumber,
+	}
+}
+
+// ExchangeRate returns the amount of underlying (in raw units) redeemable per
+// raw cToken unit as of the last accrual:
+// (cash + totalBorrows - totalReserves) / totalSupply
+// The contract's exchangeRateStored is this value scaled by 1e18. Zero is
+// returned for a market without supply.
+func (s *CompoundState) ExchangeRate() *big.Float {
+	if s.TotalSupply.Sign() == 0 {
+		return big.NewFloat(0)
+	}
+	underlying := new(big.Int).Add(s.Cash, s.TotalBorrows)
+	underlying.Sub(underlying, s.TotalReserves)
+
+	rate := new(big.Float).SetPrec(256).SetInt(underlying)
+	return rate.Quo(rate, new(big.Float).SetPrec(256).SetInt(s.TotalSupply))
+}
+
+// CompoundDecoder decodes a Compound V2 cToken market. Its cash is read from
+// the balances mapping of the underlying ERC20 token, so markets of the native
+// currency (cETH) are not supported.
+type CompoundDecoder struct {
+	underlying common.Address
+	cashSlot   common.Hash // balance slot of the cToken in the underlying token
+}
+
+// NewCompoundDecoder creates a decoder for the cToken market whose underlying
+// token keeps balances in the mapping at balancesSlot, e.g.
+// ERC20OpenZeppelinBalancesSlot.
+func NewCompoundDecoder(cToken, underlying common.Address, balancesSlot common.Hash) *CompoundDecoder {
+	return &CompoundDecoder{
+		underlying: underlying,
+		cashSlot:   erc20BalanceSlot(cToken, balancesSlot),
+	}
+}
+
+// Type returns the contract type.
+func (d *CompoundDecoder) Type() ContractType {
+	return ContractTypeCompound
+}
+
+// RequiredSlots returns the cToken storage slots needed for decoding.
+func (d *CompoundDecoder) RequiredSlots() []common.Hash {
+	return []common.Hash{
+		compoundSlotAccrualBlockNumber,
+		compoundSlotTotalBorrows,
+		compoundSlotTotalReserves,
+		compoundSlotTotalSupply,
+	}
+}
+
+// ForeignSlots returns the cToken's balance slot in the underlying token.
+func (d *CompoundDecoder) ForeignSlots() map[common.Hash]common.Address {
+	return map[common.Hash]common.Address{d.cashSlot: d.underlying}
+}
+
+// Decode decodes raw storage slots into CompoundState.
+func (d *CompoundDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	for _, slot := range append(d.RequiredSlots(), d.cashSlot) {
+		if _, ok := slots[slot]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingSlot, slot.Hex())
+		}
+	}
+	accrual := slots[compoundSlotAccrualBlockNumber].Big()
+	if !accrual.IsUint64() {
+		return nil, fmt.Errorf("accrual block number %s overflows uint64", accrual)
+	}
+	state := &CompoundState{
+		Cash:               slots[d.cashSlot].Big(),
+		TotalBorrows:       slots[compoundSlotTotalBorrows].Big(),
+		TotalReserves:      slots[compoundSlotTotalReserves].Big(),
+		TotalSupply:        slots[compoundSlotTotalSupply].Big(),
+		AccrualBlockNumber: accrual.Uint64(),
+	}
+	if new(big.Int).Add(state.Cash, state.TotalBorrows).Cmp(state.TotalReserves) < 0 {
+		return nil, fmt.Errorf("reserves %s exceed cash %s and borrows %s", state.TotalReserves, state.Cash, state.TotalBorrows)
+	}
+	return state, nil
+}
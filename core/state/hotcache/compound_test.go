@@ -0,0 +1,121 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	compoundTestCToken       = common.HexToAddress("0x39AA39c021dfbaE8faC545936693aC917d5E7563") // cUSDC
+	compoundTestUnderlying   = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48") // USDC
+	compoundTestBalancesSlot = common.BigToHash(big.NewInt(9))                                   // FiatToken balances
+)
+
+// compoundTestSlots returns the cToken slots of a cUSDC market and the cash
+// slot in USDC, with 6 decimal USDC amounts and 8 decimal cUSDC supply.
+func compoundTestSlots() (cToken map[common.Hash]common.Hash, cashSlot, cash common.Hash) {
+	cToken = map[common.Hash]common.Hash{
+		compoundSlotAccrualBlockNumber: common.BigToHash(big.NewInt(19000000)),
+		compoundSlotTotalBorrows:       common.BigToHash(big.NewInt(40_000_000e6)),
+		compoundSlotTotalReserves:      common.BigToHash(big.NewInt(5_000_000e6)),
+		compoundSlotTotalSupply:        common.BigToHash(big.NewInt(500_000_000e8)),
+	}
+	cashSlot = crypto.Keccak256Hash(common.LeftPadBytes(compoundTestCToken.Bytes(), 32), compoundTestBalancesSlot[:])
+	cash = common.BigToHash(big.NewInt(15_000_000e6))
+	return cToken, cashSlot, cash
+}
+
+func TestCompoundDecode(t *testing.T) {
+	decoder := NewCompoundDecoder(compoundTestCToken, compoundTestUnderlying, compoundTestBalancesSlot)
+	if decoder.Type() != ContractTypeCompound {
+		t.Fatalf("Unexpected decoder type %v", decoder.Type())
+	}
+	slots, cashSlot, cash := compoundTestSlots()
+	if foreign := decoder.ForeignSlots(); len(foreign) != 1 || foreign[cashSlot] != compoundTestUnderlying {
+		t.Fatalf("Unexpected foreign slots %v, want %s in %s", foreign, cashSlot.Hex(), compoundTestUnderlying.Hex())
+	}
+	slots[cashSlot] = cash
+
+	decoded, err := decoder.Decode(slots)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	state := decoded.(*CompoundState)
+	if state.Cash.Int64() != 15_000_000e6 || state.TotalBorrows.Int64() != 40_000_000e6 ||
+		state.TotalReserves.Int64() != 5_000_000e6 || state.TotalSupply.Int64() != 500_000_000e8 {
+		t.Errorf("Unexpected market state %v", state)
+	}
+	if state.AccrualBlockNumber != 19000000 {
+		t.Errorf("Expected accrual block 19000000, got %d", state.AccrualBlockNumber)
+	}
+	// (15M + 40M - 5M) USDC / 500M cUSDC = 0.1 USDC per cUSDC, i.e. 0.1e6 / 1e8 raw
+	rate, _ := state.ExchangeRate().Float64()
+	if rate < 0.9999e-3 || rate > 1.0001e-3 {
+		t.Errorf("Expected exchange rate ~1e-3, got %v", rate)
+	}
+	empty := &CompoundState{Cash: new(big.Int), TotalBorrows: new(big.Int), TotalReserves: new(big.Int), TotalSupply: new(big.Int)}
+	if empty.ExchangeRate().Sign() != 0 {
+		t.Error("Expected zero exchange rate without supply")
+	}
+
+	delete(slots, cashSlot)
+	if _, err := decoder.Decode(slots); err == nil {
+		t.Error("Expected error for missing cash slot")
+	}
+	slots[cashSlot] = common.Hash{}
+	slots[compoundSlotTotalReserves] = common.BigToHash(big.NewInt(50_000_000e6))
+	if _, err := decoder.Decode(slots); err == nil {
+		t.Error("Expected error for reserves exceeding the market's funds")
+	}
+}
+
+func TestCompoundReadsUnderlyingStorage(t *testing.T) {
+	decoder := NewCompoundDecoder(compoundTestCToken, compoundTestUnderlying, compoundTestBalancesSlot)
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{compoundTestCToken}})
+	cache.RegisterDecoder(compoundTestCToken, decoder)
+
+	reader := newMockStateReader()
+	slots, cashSlot, cash := compoundTestSlots()
+	for slot, value := range slots {
+		reader.setState(compoundTestCToken, slot, value)
+	}
+	reader.setState(compoundTestUnderlying, cashSlot, cash)
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(compoundTestCToken)
+	if err != nil {
+		t.Fatalf("Market not cached: %v", err)
+	}
+	if market := state.Decoded.(*CompoundState); market.Cash.Cmp(cash.Big()) != 0 {
+		t.Errorf("Expected cash read from the underlying token, got %s", market.Cash)
+	}
+	if err := cache.Validate(reader); err != nil {
+		t.Errorf("Expected validation against both contracts to pass: %v", err)
+	}
+
+	// A change of the underlying balance is detected by validation
+	reader.setState(compoundTestUnderlying, cashSlot, common.BigToHash(big.NewInt(1)))
+	if err := cache.Validate(reader); err == nil {
+		t.Error("Expected validation to detect the changed cash")
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"maps"
+	"math/big"
+	"reflect"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SlotChange describes a raw storage slot whose cached value differs between
+// two snapshots. Slots missing from a snapshot are reported as zero.
+type SlotChange struct {
+	Slot common.Hash `json:"slot"`
+	Old  common.Hash `json:"old"`
+	New  common.Hash `json:"new"`
+}
+
+// ContractChange describes how the cached state of a contract differs between
+// two snapshots.
+type ContractChange struct {
+	Address common.Address `json:"address"`
+	Added   bool           `json:"added,omitempty"`   // not cached in the previous snapshot
+	Removed bool           `json:"removed,omitempty"` // not cached in the newer snapshot
+	Slots   []SlotChange   `json:"slots"`
+
+	// Reserve deltas (new - old) of Uniswap V2 (or fork) pools decoded in
+	// both snapshots, nil otherwise
+	Reserve0Delta *big.Int `json:"reserve0Delta,omitempty"`
+	Reserve1Delta *big.Int `json:"reserve1Delta,omitempty"`
+}
+
+// Diff returns the contracts whose cached state changed since prev, ordered
+// by address with their changed slots ordered by slot. Unchanged contracts are
+// omitted.
+func (s *Snapshot) Diff(prev *Snapshot) []ContractChange {
+	var changes []ContractChange
+	for addr, state := range s.Contracts {
+		old, ok := prev.Contracts[addr]
+		if !ok {
+			change := ContractChange{Address: addr, Added: true}
+			change.Slots = diffSlots(nil, state.rawSlots)
+			changes = append(changes, change)
+			continue
+		}
+		if old == state {
+			continue // carried forward unchanged
+		}
+		slots := diffSlots(old.rawSlots, state.rawSlots)
+		if len(slots) == 0 {
+			continue
+		}
+		change := ContractChange{Address: addr, Slots: slots}
+		oldV2, okOld := old.Decoded.(*UniswapV2State)
+		newV2, okNew := state.Decoded.(*UniswapV2State)
+		if okOld && okNew {
+			change.Reserve0Delta = new(big.Int).Sub(newV2.Reserve0, oldV2.Reserve0)
+			change.Reserve1Delta = new(big.Int).Sub(newV2.Reserve1, oldV2.Reserve1)
+		}
+		changes = append(changes, change)
+	}
+	for addr, old := range prev.Contracts {
+		if _, ok := s.Contracts[addr]; !ok {
+			change := ContractChange{Address: addr, Removed: true}
+			change.Slots = diffSlots(old.rawSlots, nil)
+			changes = append(changes, change)
+		}
+	}
+	slices.SortFunc(changes, func(a, b ContractChange) int { return a.Address.Cmp(b.Address) })
+	return changes
+}
+
+// SnapshotSummary briefly describes a published snapshot, for notifying
+// clients that fetch the state they need on demand.
+type SnapshotSummary struct {
+	BlockNumber uint64           `json:"blockNumber"`
+	BlockHash   common.Hash      `json:"blockHash"`
+	Changed     []common.Address `json:"changed"` // contracts changed since the previous snapshot, sorted
+}
+
+// Summary returns the summary of the snapshot, listing the contracts changed
+// since prev as reported by Diff.
+func (s *Snapshot) Summary(prev *Snapshot) SnapshotSummary {
+	summary := SnapshotSummary{
+		BlockNumber: s.BlockNumber,
+		BlockHash:   s.BlockHash,
+		Changed:     []common.Address{},
+	}
+	for _, change := range s.Diff(prev) {
+		summary.Changed = append(summary.Changed, change.Address)
+	}
+	return summary
+}
+
+// SignificantChanges returns the contracts whose state changed significantly
+// since the snapshot the cache built this one on, sorted. Any change counts,
+// except that the reserves of Uniswap V2 (or fork) pools with a change
+// threshold must move by more than it; see Cache.SetChangeThreshold.
+// Snapshots not built by the cache, e.g. loaded from disk, report none.
+func (s *Snapshot) SignificantChanges() []common.Address {
+	return slices.SortedFunc(maps.Keys(s.significant), func(a, b common.Address) int { return a.Cmp(b) })
+}
+
+// significantChange reports whether a contract changed significantly from
+// old, nil if it was not cached, given its reserve change threshold in basis
+// points.
+func significantChange(old, state *ContractState, bps uint64) bool {
+	if old == nil {
+		return true
+	}
+	if old == state || (maps.Equal(old.rawSlots, state.rawSlots) && equalBig(old.NativeBalance, state.NativeBalance)) {
+		return false
+	}
+	oldV2, okOld := old.Decoded.(*UniswapV2State)
+	newV2, okNew := state.Decoded.(*UniswapV2State)
+	if bps == 0 || !okOld || !okNew {
+		return true
+	}
+	return exceedsThreshold(oldV2.Reserve0, newV2.Reserve0, bps) || exceedsThreshold(oldV2.Reserve1, newV2.Reserve1, bps)
+}
+
+// exceedsThreshold reports whether a reserve moved from before to after by
+// more than bps basis points of before.
+func exceedsThreshold(before, after *big.Int, bps uint64) bool {
+	delta := new(big.Int).Sub(after, before)
+	delta.Abs(delta).Mul(delta, big.NewInt(10000))
+	limit := new(big.Int).Mul(before, new(big.Int).SetUint64(bps))
+	return delta.Cmp(limit) > 0
+}
+
+// diffSlots returns the slots whose values differ between two raw slot maps.
+func diffSlots(before, after map[common.Hash]common.Hash) []SlotChange {
+	var changes []SlotChange
+	for slot, value := range after {
+		if prev := before[slot]; prev != value {
+			changes = append(changes, SlotChange{Slot: slot, Old: prev, New: value})
+		}
+	}
+	for slot, prev := range before {
+		if _, ok := after[slot]; !ok && prev != (common.Hash{}) {
+			changes = append(changes, SlotChange{Slot: slot, Old: prev})
+		}
+	}
+	slices.SortFunc(changes, func(a, b SlotChange) int { return a.Slot.Cmp(b.Slot) })
+	return changes
+}
+
+// Equal reports whether two snapshots hold the same block metadata and equal
+// state for the same contracts, see ContractState.Equal.
+func (s *Snapshot) Equal(other *Snapshot) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if s.BlockNumber != other.BlockNumber || s.BlockHash != other.BlockHash || s.BlockTime != other.BlockTime || s.StateRoot != other.StateRoot {
+		return false
+	}
+	if len(s.Contracts) != len(other.Contracts) {
+		return false
+	}
+	for addr, state := range s.Contracts {
+		if !state.Equal(other.Contracts[addr]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether two contract states are equal by value. Decoded
+// states are compared by their JSON encoding, so big integers holding the same
+// value are equal; decoded values that cannot be encoded are compared with
+// reflect.DeepEqual. Decode errors are compared by message.
+func (s *ContractState) Equal(other *ContractState) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if s.Address != other.Address || s.Type != other.Type || s.Suspect != other.Suspect || s.Dead != other.Dead || s.LastUpdated != other.LastUpdated {
+		return false
+	}
+	if (s.DecodeError == nil) != (other.DecodeError == nil) {
+		return false
+	}
+	if s.DecodeError != nil && s.DecodeError.Error() != other.DecodeError.Error() {
+		return false
+	}
+	if !maps.Equal(s.rawSlots, other.rawSlots) || !equalBig(s.NativeBalance, other.NativeBalance) {
+		return false
+	}
+	return decodedEqual(s.Decoded, other.Decoded)
+}
+
+// Checksum returns the keccak256 hash of a canonical encoding of the
+// snapshot's contract states, for detecting corruption of persisted or
+// transmitted snapshots. For each contract in address order, the encoding
+// holds the address, the contract type, the raw slots and their values in slot
+// order, the native balance if one was read, the decode error message, the
+// suspect and dead flags, and the JSON encoding of the decoded state. Block
+// metadata is not covered.
+func (s *Snapshot) Checksum() common.Hash {
+	return s.checksum(func(state *ContractState) interface{} { return state.Decoded })
+}
+
+// checksum computes Snapshot.Checksum over the decoded states returned by
+// decoded, so that encodings which drop some decoded states can checksum the
+// snapshot as it will be restored.
+func (s *Snapshot) checksum(decoded func(*ContractState) interface{}) common.Hash {
+	var (
+		hasher     = crypto.NewKeccakState()
+		writeBytes = func(b []byte) {
+			hasher.Write(binary.BigEndian.AppendUint64(nil, uint64(len(b))))
+			hasher.Write(b)
+		}
+		writeBool = func(v bool) {
+			if v {
+				hasher.Write([]byte{1})
+			} else {
+				hasher.Write([]byte{0})
+			}
+		}
+	)
+	for _, addr := range slices.SortedFunc(maps.Keys(s.Contracts), common.Address.Cmp) {
+		state := s.Contracts[addr]
+		hasher.Write(addr[:])
+		writeBytes([]byte(state.Type.String()))
+		hasher.Write(binary.BigEndian.AppendUint64(nil, uint64(len(state.rawSlots))))
+		for _, slot := range slices.SortedFunc(maps.Keys(state.rawSlots), common.Hash.Cmp) {
+			value := state.rawSlots[slot]
+			hasher.Write(slot[:])
+			hasher.Write(value[:])
+		}
+		writeBool(state.NativeBalance != nil)
+		if state.NativeBalance != nil {
+			balance := common.BigToHash(state.NativeBalance)
+			hasher.Write(balance[:])
+		}
+		var decodeError string
+		if state.DecodeError != nil {
+			decodeError = state.DecodeError.Error()
+		}
+		writeBytes([]byte(decodeError))
+		writeBool(state.Suspect)
+		writeBool(state.Dead)
+
+		// Decoded states that fail to encode are hashed as absent; persisting
+		// them fails anyway.
+		var enc []byte
+		if d := decoded(state); d != nil {
+			enc, _ = json.Marshal(d)
+		}
+		writeBytes(enc)
+	}
+	var hash common.Hash
+	hasher.Read(hash[:])
+	return hash
+}
+
+// equalBig reports whether two optional big integers are both nil or equal.
+func equalBig(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Cmp(b) == 0
+}
+
+// decodedEqual reports whether two decoded states are of the same type and
+// hold the same value.
+func decodedEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	encA, errA := json.Marshal(a)
+	encB, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return bytes.Equal(encA, encB)
+}
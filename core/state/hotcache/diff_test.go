@@ -0,0 +1,179 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	var (
+		pool      = common.HexToAddress("0x1")
+		token     = common.HexToAddress("0x2")
+		added     = common.HexToAddress("0x3")
+		removed   = common.HexToAddress("0x4")
+		slot      = common.HexToHash("0x01")
+		unchanged = &ContractState{Address: token, rawSlots: map[common.Hash]common.Hash{slot: common.HexToHash("0xaa")}}
+	)
+	prev := &Snapshot{
+		BlockNumber: 1,
+		Contracts: map[common.Address]*ContractState{
+			pool: {
+				Address:  pool,
+				Type:     ContractTypeUniswapV2,
+				rawSlots: map[common.Hash]common.Hash{uniswapV2SlotReserves: common.HexToHash("0x10"), slot: common.HexToHash("0x01")},
+				Decoded:  &UniswapV2State{Reserve0: big.NewInt(1000), Reserve1: big.NewInt(500)},
+			},
+			token:   unchanged,
+			removed: {Address: removed, rawSlots: map[common.Hash]common.Hash{slot: common.HexToHash("0xbb")}},
+		},
+	}
+	next := &Snapshot{
+		BlockNumber: 2,
+		Contracts: map[common.Address]*ContractState{
+			pool: {
+				Address:  pool,
+				Type:     ContractTypeUniswapV2,
+				rawSlots: map[common.Hash]common.Hash{uniswapV2SlotReserves: common.HexToHash("0x20"), slot: common.HexToHash("0x01")},
+				Decoded:  &UniswapV2State{Reserve0: big.NewInt(1100), Reserve1: big.NewInt(455)},
+			},
+			token: {Address: token, rawSlots: map[common.Hash]common.Hash{slot: common.HexToHash("0xaa")}},
+			added: {Address: added, rawSlots: map[common.Hash]common.Hash{slot: common.HexToHash("0xcc")}},
+		},
+	}
+	changes := next.Diff(prev)
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changed contracts, got %d: %+v", len(changes), changes)
+	}
+	// Ordered by address: pool, added, removed
+	v2 := changes[0]
+	if v2.Address != pool || len(v2.Slots) != 1 || v2.Slots[0] != (SlotChange{Slot: uniswapV2SlotReserves, Old: common.HexToHash("0x10"), New: common.HexToHash("0x20")}) {
+		t.Errorf("Unexpected pool change %+v", v2)
+	}
+	if v2.Reserve0Delta.Int64() != 100 || v2.Reserve1Delta.Int64() != -45 {
+		t.Errorf("Unexpected reserve deltas %s/%s", v2.Reserve0Delta, v2.Reserve1Delta)
+	}
+	if c := changes[1]; c.Address != added || !c.Added || len(c.Slots) != 1 || c.Slots[0].New != common.HexToHash("0xcc") || c.Reserve0Delta != nil {
+		t.Errorf("Unexpected added contract change %+v", c)
+	}
+	if c := changes[2]; c.Address != removed || !c.Removed || len(c.Slots) != 1 || c.Slots[0].Old != common.HexToHash("0xbb") {
+		t.Errorf("Unexpected removed contract change %+v", c)
+	}
+	if changes := next.Diff(next); len(changes) != 0 {
+		t.Errorf("Expected no changes against itself, got %+v", changes)
+	}
+}
+
+func TestSnapshotEqual(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		slot  = common.HexToHash("0x01")
+	)
+	build := func(balance int64) *Snapshot {
+		cache := New(Config{Enabled: true, Watchlist: []common.Address{pool, token}})
+		cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+		cache.WatchSlots(token, []common.Hash{slot})
+
+		reader := newMockStateReader()
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+		reader.setState(token, slot, common.BigToHash(big.NewInt(balance)))
+		if err := cache.Update(testHeader(1), reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		return cache.GetSnapshot()
+	}
+	a, b := build(7), build(7)
+	if a.Contracts[pool].Decoded == b.Contracts[pool].Decoded {
+		t.Fatal("Expected independently decoded states")
+	}
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Error("Expected snapshots built from the same state to be equal")
+	}
+	if !a.Contracts[pool].Equal(a.Contracts[pool].Copy()) {
+		t.Error("Expected a contract state to equal its copy")
+	}
+
+	// A single differing slot
+	c := build(8)
+	if a.Equal(c) {
+		t.Error("Expected snapshots differing in a slot to be unequal")
+	}
+	if a.Contracts[token].Equal(c.Contracts[token]) {
+		t.Error("Expected contract states differing in a slot to be unequal")
+	}
+	if !a.Contracts[pool].Equal(c.Contracts[pool]) {
+		t.Error("Expected unchanged contracts to stay equal")
+	}
+	// Differing decoded state
+	d := a.Contracts[pool].Copy()
+	d.Decoded.(*UniswapV2State).Reserve0.SetInt64(1001)
+	if a.Contracts[pool].Equal(d) {
+		t.Error("Expected contract states differing in decoded state to be unequal")
+	}
+	if a.Equal(nil) || !(*Snapshot)(nil).Equal(nil) {
+		t.Error("Unexpected nil snapshot comparison")
+	}
+}
+
+func TestSnapshotChecksum(t *testing.T) {
+	var (
+		pool = common.HexToAddress("0x1")
+		weth = common.HexToAddress("0x2")
+		slot = common.HexToHash("0x8")
+	)
+	build := func(value common.Hash, balance *big.Int) *Snapshot {
+		return &Snapshot{
+			BlockNumber: 1,
+			Contracts: map[common.Address]*ContractState{
+				pool: {Address: pool, rawSlots: map[common.Hash]common.Hash{slot: value, {0x9}: {0x1}}},
+				weth: {Address: weth, rawSlots: map[common.Hash]common.Hash{}, NativeBalance: balance},
+			},
+		}
+	}
+	base := build(common.HexToHash("0x1234"), big.NewInt(5))
+	if base.Checksum() != build(common.HexToHash("0x1234"), big.NewInt(5)).Checksum() {
+		t.Fatal("Equal snapshots have different checksums")
+	}
+	if base.Checksum() == build(common.HexToHash("0x1235"), big.NewInt(5)).Checksum() {
+		t.Error("Checksum unchanged after changing a single slot value")
+	}
+	if base.Checksum() == build(common.HexToHash("0x1234"), nil).Checksum() {
+		t.Error("Checksum unchanged after dropping the native balance")
+	}
+	if base.Checksum() == (&Snapshot{}).Checksum() {
+		t.Error("Checksum of an empty snapshot matches")
+	}
+	// Contract metadata and decoded states are covered too
+	for name, corrupt := range map[string]func(*ContractState){
+		"type":         func(s *ContractState) { s.Type = ContractTypeUniswapV2 },
+		"decode error": func(s *ContractState) { s.DecodeError = errors.New("corrupt") },
+		"suspect":      func(s *ContractState) { s.Suspect = true },
+		"dead":         func(s *ContractState) { s.Dead = true },
+		"decoded":      func(s *ContractState) { s.Decoded = &UniswapV2State{Reserve0: big.NewInt(1)} },
+	} {
+		snapshot := build(common.HexToHash("0x1234"), big.NewInt(5))
+		corrupt(snapshot.Contracts[pool])
+		if base.Checksum() == snapshot.Checksum() {
+			t.Errorf("Checksum unchanged after changing the %s", name)
+		}
+	}
+}
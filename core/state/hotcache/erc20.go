@@ -0,0 +1,130 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// ERC20 storage layout differs between implementations. OpenZeppelin's ERC20
+// (v4) uses:
+// slot 0: _balances (mapping(address => uint256))
+// slot 1: _allowances (mapping(address => mapping(address => uint256)))
+// slot 2: _totalSupply (uint256)
+//
+// The balance of a holder lives at keccak256(pad32(holder) . pad32(balancesSlot)).
+
+var (
+	// Storage slots of OpenZeppelin's ERC20 implementation
+	ERC20OpenZeppelinBalancesSlot    = common.BigToHash(big.NewInt(0))
+	ERC20OpenZeppelinTotalSupplySlot = common.BigToHash(big.NewInt(2))
+)
+
+//go:generate go run github.com/fjl/gencodec -type ERC20State -field-override erc20StateMarshaling -out gen_erc20_json.go
+
+// ERC20State represents the decoded state of an ERC20 token.
+type ERC20State struct {
+	TotalSupply *big.Int                    `json:"totalSupply"`
+	Balances    map[common.Address]*big.Int `json:"balances"`
+}
+
+// erc20StateMarshaling renders big integers as decimal strings.
+type erc20StateMarshaling struct {
+	TotalSupply *math.Decimal256
+	Balances    map[common.Address]*math.Decimal256
+}
+
+// String returns a human-readable representation of the token state.
+func (s *ERC20State) String() string {
+	return fmt.Sprintf("ERC20{totalSupply: %s, holders: %d}", s.TotalSupply.String(), len(s.Balances))
+}
+
+// Copy returns a deep copy of the token state.
+func (s *ERC20State) Copy() *ERC20State {
+	cpy := &ERC20State{TotalSupply: copyBig(s.TotalSupply)}
+	if s.Balances != nil {
+		cpy.Balances = make(map[common.Address]*big.Int, len(s.Balances))
+		for holder, balance := range s.Balances {
+			cpy.Balances[holder] = copyBig(balance)
+		}
+	}
+	return cpy
+}
+
+// ERC20Decoder decodes an ERC20 token's total supply and the balances of a
+// fixed set of holders from raw storage slots.
+type ERC20Decoder struct {
+	totalSupplySlot common.Hash
+	holders         []common.Address
+	balanceSlots    []common.Hash // balance slot of holders[i]
+}
+
+// NewERC20Decoder creates a decoder for a token storing balances in the mapping
+// at balancesSlot and its total supply at totalSupplySlot, tracking the given
+// holders.
+func NewERC20Decoder(balancesSlot, totalSupplySlot common.Hash, holders []common.Address) *ERC20Decoder {
+	d := &ERC20Decoder{
+		totalSupplySlot: totalSupplySlot,
+		holders:         append([]common.Address(nil), holders...),
+		balanceSlots:    make([]common.Hash, len(holders)),
+	}
+	for i, holder := range holders {
+		d.balanceSlots[i] = erc20BalanceSlot(holder, balancesSlot)
+	}
+	return d
+}
+
+// erc20BalanceSlot computes the storage slot of a holder's balance.
+func erc20BalanceSlot(holder common.Address, balancesSlot common.Hash) common.Hash {
+	return MappingSlot(common.LeftPadBytes(holder.Bytes(), 32), balancesSlot)
+}
+
+// Type returns the contract type.
+func (d *ERC20Decoder) Type() ContractType {
+	return ContractTypeERC20
+}
+
+// RequiredSlots returns the storage slots needed for decoding.
+func (d *ERC20Decoder) RequiredSlots() []common.Hash {
+	slots := make([]common.Hash, 0, len(d.balanceSlots)+1)
+	slots = append(slots, d.totalSupplySlot)
+	return append(slots, d.balanceSlots...)
+}
+
+// Decode decodes raw storage slots into ERC20State.
+func (d *ERC20Decoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	totalSupply, ok := slots[d.totalSupplySlot]
+	if !ok {
+		return nil, fmt.Errorf("%w: totalSupply", ErrMissingSlot)
+	}
+	state := &ERC20State{
+		TotalSupply: totalSupply.Big(),
+		Balances:    make(map[common.Address]*big.Int, len(d.holders)),
+	}
+	for i, holder := range d.holders {
+		balance, ok := slots[d.balanceSlots[i]]
+		if !ok {
+			return nil, fmt.Errorf("%w: balance of holder %s", ErrMissingSlot, holder.Hex())
+		}
+		state.Balances[holder] = balance.Big()
+	}
+	return state, nil
+}
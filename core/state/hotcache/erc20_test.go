@@ -0,0 +1,80 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestERC20BalanceSlot(t *testing.T) {
+	// keccak256(abi.encode(address(0), 0)) and keccak256(abi.encode(address(0), 1))
+	tests := []struct {
+		slot common.Hash
+		want common.Hash
+	}{
+		{common.BigToHash(big.NewInt(0)), common.HexToHash("0xad3228b676f7d3cd4284a5443f17f1962b36e491b30a40b2405849e597ba5fb5")},
+		{common.BigToHash(big.NewInt(1)), common.HexToHash("0xa6eef7e35abe7026729641147f7915573c7e97b47efa546f5f6e3230263bcb49")},
+	}
+	for _, tt := range tests {
+		if got := erc20BalanceSlot(common.Address{}, tt.slot); got != tt.want {
+			t.Errorf("Balance slot for mapping %s: expected %s, got %s", tt.slot.Hex(), tt.want.Hex(), got.Hex())
+		}
+	}
+}
+
+func TestERC20Decode(t *testing.T) {
+	var (
+		alice  = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+		bob    = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+		supply = new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1e18))
+	)
+	decoder := NewERC20Decoder(ERC20OpenZeppelinBalancesSlot, ERC20OpenZeppelinTotalSupplySlot, []common.Address{alice, bob})
+	if decoder.Type() != ContractTypeERC20 {
+		t.Errorf("Expected contract type %v, got %v", ContractTypeERC20, decoder.Type())
+	}
+	if n := len(decoder.RequiredSlots()); n != 3 {
+		t.Fatalf("Expected 3 required slots, got %d", n)
+	}
+
+	slots := map[common.Hash]common.Hash{
+		ERC20OpenZeppelinTotalSupplySlot:                       common.BigToHash(supply),
+		erc20BalanceSlot(alice, ERC20OpenZeppelinBalancesSlot): common.BigToHash(big.NewInt(1500)),
+		erc20BalanceSlot(bob, ERC20OpenZeppelinBalancesSlot):   common.BigToHash(big.NewInt(0)),
+	}
+	decoded, err := decoder.Decode(slots)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	state := decoded.(*ERC20State)
+	if state.TotalSupply.Cmp(supply) != 0 {
+		t.Errorf("Expected total supply %s, got %s", supply, state.TotalSupply)
+	}
+	if state.Balances[alice].Int64() != 1500 {
+		t.Errorf("Expected alice balance 1500, got %s", state.Balances[alice])
+	}
+	if state.Balances[bob].Sign() != 0 {
+		t.Errorf("Expected bob balance 0, got %s", state.Balances[bob])
+	}
+
+	delete(slots, erc20BalanceSlot(bob, ERC20OpenZeppelinBalancesSlot))
+	if _, err := decoder.Decode(slots); err == nil {
+		t.Error("Expected error for missing balance slot")
+	}
+}
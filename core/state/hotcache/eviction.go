@@ -0,0 +1,152 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EvictionPolicy selects the watched contracts that stop being updated once
+// more than Config.MaxActiveContracts are watched.
+type EvictionPolicy uint8
+
+const (
+	// EvictLRU evicts the contracts read least recently, breaking ties by
+	// the number of reads
+	EvictLRU EvictionPolicy = iota
+
+	// EvictLFU evicts the contracts read least often, breaking ties by the
+	// time of the last read
+	EvictLFU
+)
+
+// contractAccess tracks the reads of a watched contract for eviction.
+type contractAccess struct {
+	lastRead atomic.Uint64 // number of the cached head block at the last read
+	reads    atomic.Uint64 // number of reads
+	rewarm   atomic.Bool   // read while evicted, admit on the next update
+}
+
+// recordAccess counts a read of a watched contract. A contract read while not
+// cached is admitted by the next update, evicting a colder one instead. It is
+// a no-op unless Config.MaxActiveContracts is set.
+func (c *Cache) recordAccess(addr common.Address, cached bool) {
+	if c.config.MaxActiveContracts <= 0 {
+		return
+	}
+	c.accessMu.RLock()
+	access, ok := c.access[addr]
+	c.accessMu.RUnlock()
+	if !ok {
+		return
+	}
+	access.lastRead.Store(c.headNumber.Load())
+	access.reads.Add(1)
+	if !cached {
+		access.rewarm.Store(true)
+	}
+}
+
+// activeContracts returns the watched contracts to update, evicting the
+// coldest ones according to the eviction policy if more than
+// MaxActiveContracts are watched. Contracts read while evicted are admitted
+// first. Evicted contracts stay watched and are dropped from the snapshot.
+func (c *Cache) activeContracts(watched []common.Address) []common.Address {
+	limit := c.config.MaxActiveContracts
+	if limit <= 0 {
+		return watched
+	}
+	c.accessMu.Lock()
+	defer c.accessMu.Unlock()
+
+	// Track new contracts as read at the current head, and forget the
+	// reads of contracts no longer watched
+	head := c.headNumber.Load()
+	current := make(map[common.Address]bool, len(watched))
+	for _, addr := range watched {
+		current[addr] = true
+		if _, ok := c.access[addr]; !ok {
+			access := new(contractAccess)
+			access.lastRead.Store(head)
+			c.access[addr] = access
+		}
+	}
+	for addr := range c.access {
+		if !current[addr] {
+			delete(c.access, addr)
+		}
+	}
+	if len(watched) <= limit {
+		return watched
+	}
+	// Order the contracts from hottest to coldest, rewarmed ones first
+	type candidate struct {
+		addr     common.Address
+		rewarm   bool
+		lastRead uint64
+		reads    uint64
+	}
+	candidates := make([]candidate, len(watched))
+	for i, addr := range watched {
+		access := c.access[addr]
+		candidates[i] = candidate{
+			addr:     addr,
+			rewarm:   access.rewarm.Load(),
+			lastRead: access.lastRead.Load(),
+			reads:    access.reads.Load(),
+		}
+	}
+	recency := func(a, b candidate) int {
+		if n := cmp.Compare(b.lastRead, a.lastRead); n != 0 {
+			return n
+		}
+		return cmp.Compare(b.reads, a.reads)
+	}
+	frequency := func(a, b candidate) int {
+		if n := cmp.Compare(b.reads, a.reads); n != 0 {
+			return n
+		}
+		return cmp.Compare(b.lastRead, a.lastRead)
+	}
+	hotness := recency
+	if c.config.EvictionPolicy == EvictLFU {
+		hotness = frequency
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.rewarm != b.rewarm {
+			if a.rewarm {
+				return -1
+			}
+			return 1
+		}
+		if n := hotness(a, b); n != 0 {
+			return n
+		}
+		return a.addr.Cmp(b.addr)
+	})
+	active := make([]common.Address, limit)
+	for i, candidate := range candidates[:limit] {
+		active[i] = candidate.addr
+		c.access[candidate.addr].rewarm.Store(false)
+	}
+	c.logger.Debug("Evicted cold contracts from hot cache", "active", limit, "evicted", len(watched)-limit)
+	return active
+}
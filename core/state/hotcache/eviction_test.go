@@ -0,0 +1,150 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// cachedContracts returns whether each of the given contracts is cached in the
+// current snapshot.
+func cachedContracts(cache *Cache, addrs ...common.Address) []bool {
+	snapshot := cache.GetSnapshot()
+	cached := make([]bool, len(addrs))
+	for i, addr := range addrs {
+		_, cached[i] = snapshot.Contracts[addr]
+	}
+	return cached
+}
+
+func TestEvictionLRU(t *testing.T) {
+	var (
+		a = common.HexToAddress("0x1")
+		b = common.HexToAddress("0x2")
+		c = common.HexToAddress("0x3")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{a, b, c}, MaxActiveContracts: 2})
+	reader := newMockStateReader()
+	update := func(n uint64) {
+		t.Helper()
+		if err := cache.Update(testHeader(n), reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	// Without reads, ties are resolved by address
+	update(1)
+	if got := cachedContracts(cache, a, b, c); !got[0] || !got[1] || got[2] {
+		t.Fatalf("Expected the first two contracts to be active, got %v", got)
+	}
+	// Reading the evicted contract re-warms it on the next update, evicting
+	// the least recently read one instead
+	cache.GetContractState(a)
+	if _, err := cache.GetContractState(c); !errors.Is(err, ErrNotPopulated) {
+		t.Fatalf("Expected ErrNotPopulated for an evicted contract, got %v", err)
+	}
+	update(2)
+	if got := cachedContracts(cache, a, b, c); !got[0] || got[1] || !got[2] {
+		t.Fatalf("Expected the unread contract to be evicted, got %v", got)
+	}
+	if !cache.IsWatched(b) {
+		t.Error("Expected the evicted contract to stay watched")
+	}
+	// A later read of b makes it more recent than a
+	cache.GetContractState(c)
+	cache.GetContractState(b)
+	update(3)
+	if got := cachedContracts(cache, a, b, c); got[0] || !got[1] || !got[2] {
+		t.Fatalf("Expected the least recently read contract to be evicted, got %v", got)
+	}
+	if _, err := cache.GetContractState(b); err != nil {
+		t.Errorf("Expected the re-warmed contract to be cached: %v", err)
+	}
+}
+
+func TestEvictionLFU(t *testing.T) {
+	var (
+		a = common.HexToAddress("0x1")
+		b = common.HexToAddress("0x2")
+		c = common.HexToAddress("0x3")
+	)
+	cache := New(Config{
+		Enabled:            true,
+		Watchlist:          []common.Address{a, b, c},
+		MaxActiveContracts: 2,
+		EvictionPolicy:     EvictLFU,
+	})
+	reader := newMockStateReader()
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	// a is read often, b once and most recently
+	for i := 0; i < 3; i++ {
+		cache.GetContractState(a)
+	}
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	cache.GetContractState(b)
+	cache.GetContractState(c) // evicted, re-warmed
+	if err := cache.Update(testHeader(3), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := cachedContracts(cache, a, b, c); !got[0] || got[1] || !got[2] {
+		t.Fatalf("Expected the least frequently read contract to be evicted, got %v", got)
+	}
+	// Without a limit every contract is updated
+	unlimited := New(Config{Enabled: true, Watchlist: []common.Address{a, b, c}})
+	if err := unlimited.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := cachedContracts(unlimited, a, b, c); !got[0] || !got[1] || !got[2] {
+		t.Errorf("Expected all contracts to be active, got %v", got)
+	}
+}
+
+// TestEvictionRemovedContract checks that the reads of a contract removed from
+// the watchlist are forgotten, so it starts cold when watched again.
+func TestEvictionRemovedContract(t *testing.T) {
+	var (
+		a = common.HexToAddress("0x1")
+		b = common.HexToAddress("0x2")
+		c = common.HexToAddress("0x3")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{a, b, c}, MaxActiveContracts: 2, EvictionPolicy: EvictLFU})
+	reader := newMockStateReader()
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	cache.GetContractState(a)
+	cache.GetContractState(b)
+	for i := 0; i < 5; i++ {
+		cache.GetContractState(c)
+	}
+	cache.RemoveFromWatchlist(c)
+	if err := cache.AddToWatchlist(c); err != nil {
+		t.Fatalf("AddToWatchlist failed: %v", err)
+	}
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := cachedContracts(cache, a, b, c); !got[0] || !got[1] || got[2] {
+		t.Fatalf("Expected the re-added contract to start cold, got %v", got)
+	}
+}
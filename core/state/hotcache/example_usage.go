@@ -105,9 +105,9 @@ func ExampleUsage() {
 	
 	// Example 5: Statistics
 	stats := cache.GetStatistics()
-	fmt.Printf("Cache hits: %d\n", stats.Hits.Load())
-	fmt.Printf("Cache misses: %d\n", stats.Misses.Load())
-	fmt.Printf("Validation errors: %d\n", stats.ValidationErrors.Load())
+	fmt.Printf("Cache hits: %d\n", stats.Hits)
+	fmt.Printf("Cache misses: %d\n", stats.Misses)
+	fmt.Printf("Validation errors: %d\n", stats.ValidationErrors)
 	
 	// If validation errors > 0, investigate immediately!
 	// This indicates cache inconsistency and should never happen in production.
@@ -0,0 +1,132 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// pairCreatedTopic identifies the Uniswap V2 factory event
+// PairCreated(address indexed token0, address indexed token1, address pair, uint256).
+var pairCreatedTopic = crypto.Keccak256Hash([]byte("PairCreated(address,address,address,uint256)"))
+
+// PairCreated is a decoded Uniswap V2 factory PairCreated event.
+type PairCreated struct {
+	Factory common.Address
+	Token0  common.Address
+	Token1  common.Address
+	Pair    common.Address
+}
+
+// DecodePairCreated decodes a Uniswap V2 factory PairCreated log.
+func DecodePairCreated(entry *types.Log) (*PairCreated, error) {
+	if len(entry.Topics) != 3 || entry.Topics[0] != pairCreatedTopic {
+		return nil, errors.New("not a PairCreated log")
+	}
+	if len(entry.Data) != 2*common.HashLength {
+		return nil, fmt.Errorf("invalid PairCreated data length %d", len(entry.Data))
+	}
+	event := &PairCreated{Factory: entry.Address}
+	for _, field := range []struct {
+		word common.Hash
+		addr *common.Address
+	}{
+		{entry.Topics[1], &event.Token0},
+		{entry.Topics[2], &event.Token1},
+		{common.BytesToHash(entry.Data[:common.HashLength]), &event.Pair},
+	} {
+		if common.BytesToHash(field.word[common.HashLength-common.AddressLength:]) != field.word {
+			return nil, fmt.Errorf("invalid address word %s in PairCreated log", field.word.Hex())
+		}
+		*field.addr = common.BytesToAddress(field.word.Bytes())
+	}
+	return event, nil
+}
+
+// WatchFactory starts watching every pair created by the given Uniswap V2
+// factory from now on, as reported to ProcessLogs. Pairs that already exist
+// must be added to the watchlist explicitly.
+func (c *Cache) WatchFactory(factory common.Address) {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	c.factories[factory] = true
+	c.logger.Debug("Watching pairs of factory", "factory", factory)
+}
+
+// ProcessLogs adds the pairs announced by PairCreated logs of watched factories
+// to the watchlist with a UniswapV2Decoder, returning the added pairs. The
+// block processor calls it with the logs of each imported block before
+// updating the cache, so new pairs are cached from their creation block on.
+// It does nothing unless Config.WatchFactoryPairs is set.
+func (c *Cache) ProcessLogs(logs []*types.Log) []common.Address {
+	if !c.config.WatchFactoryPairs || !c.IsEnabled() {
+		return nil
+	}
+	var added []common.Address
+	for _, entry := range logs {
+		if len(entry.Topics) == 0 || entry.Topics[0] != pairCreatedTopic || !c.isFactory(entry.Address) {
+			continue
+		}
+		event, err := DecodePairCreated(entry)
+		if err != nil {
+			c.logger.Warn("Failed to decode PairCreated log", "factory", entry.Address, "tx", entry.TxHash, "err", err)
+			continue
+		}
+		ok, err := c.watchPair(event.Pair)
+		if err != nil {
+			c.logger.Warn("Not watching new pair", "factory", event.Factory, "pair", event.Pair, "err", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		added = append(added, event.Pair)
+		c.logger.Debug("Watching new pair", "factory", event.Factory, "pair", event.Pair, "token0", event.Token0, "token1", event.Token1)
+	}
+	return added
+}
+
+// watchPair adds a new pair to the watchlist, registering a UniswapV2Decoder
+// for it unless it already has a decoder. It reports false if the pair was
+// watched already. Both happen in one watchMu critical section, with the
+// watchlist limit checked before registering, so a pair that cannot be
+// watched is left without a decoder and decoders registered concurrently by
+// others are never replaced or removed.
+func (c *Cache) watchPair(pair common.Address) (bool, error) {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	if c.watchlist[pair] {
+		return false, nil
+	}
+	if err := c.checkWatchlistLimit(); err != nil {
+		return false, err
+	}
+	c.registerDecoderIfAbsent(pair, &UniswapV2Decoder{})
+	return true, c.addToWatchlist(pair)
+}
+
+// isFactory reports whether pairs of the given factory are watched.
+func (c *Cache) isFactory(addr common.Address) bool {
+	c.watchMu.RLock()
+	defer c.watchMu.RUnlock()
+	return c.factories[addr]
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// pairCreatedLog builds a PairCreated log as emitted by a V2 factory.
+func pairCreatedLog(factory, token0, token1, pair common.Address, index int64) *types.Log {
+	data := append(common.BytesToHash(pair.Bytes()).Bytes(), common.BigToHash(big.NewInt(index)).Bytes()...)
+	return &types.Log{
+		Address: factory,
+		Topics:  []common.Hash{pairCreatedTopic, common.BytesToHash(token0.Bytes()), common.BytesToHash(token1.Bytes())},
+		Data:    data,
+	}
+}
+
+func TestWatchFactory(t *testing.T) {
+	var (
+		factory = common.HexToAddress("0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f")
+		other   = common.HexToAddress("0xC0AEe478e3658e2610c5F7A4A2E1777cE9e4f2Ac")
+		usdc    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		weth    = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+		pool    = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		pair    = common.HexToAddress("0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852")
+		foreign = common.HexToAddress("0x397FF1542f962076d0BFE58eA045FfA2d347ACa0")
+	)
+	logs := []*types.Log{
+		pairCreatedLog(factory, usdc, weth, pair, 1),
+		pairCreatedLog(other, usdc, weth, foreign, 1),
+	}
+
+	// Disabled by default
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.WatchFactory(factory)
+	if added := cache.ProcessLogs(logs); len(added) != 0 || cache.IsWatched(pair) {
+		t.Fatalf("Expected no pairs added without WatchFactoryPairs, got %v", added)
+	}
+
+	cache = New(Config{Enabled: true, Watchlist: []common.Address{pool}, WatchFactoryPairs: true})
+	cache.WatchFactory(factory)
+	if added := cache.ProcessLogs(logs); !slices.Equal(added, []common.Address{pair}) {
+		t.Fatalf("Expected pair %s added, got %v", pair.Hex(), added)
+	}
+	if !cache.IsWatched(pair) || !cache.HasDecoder(pair) {
+		t.Error("Expected the new pair to be watched with a decoder")
+	}
+	if cache.IsWatched(foreign) {
+		t.Error("Expected pairs of unwatched factories to be ignored")
+	}
+	// Replayed logs do not add the pair twice
+	if added := cache.ProcessLogs(logs); len(added) != 0 {
+		t.Errorf("Expected no pairs added again, got %v", added)
+	}
+
+	reader := newMockStateReader()
+	reader.setState(pair, uniswapV2SlotToken0, common.BytesToHash(usdc.Bytes()))
+	reader.setState(pair, uniswapV2SlotToken1, common.BytesToHash(weth.Bytes()))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(pair)
+	if err != nil {
+		t.Fatalf("Pair not cached: %v", err)
+	}
+	if v2, ok := state.Decoded.(*UniswapV2State); !ok || v2.Token0 != usdc || v2.Token1 != weth {
+		t.Errorf("Unexpected decoded pair state %v", state.Decoded)
+	}
+}
+
+// TestProcessLogsExistingDecoder checks that a pair that cannot be watched is
+// left without a decoder, and that decoders registered by others are neither
+// replaced nor removed.
+func TestProcessLogsExistingDecoder(t *testing.T) {
+	var (
+		factory = common.HexToAddress("0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f")
+		pool    = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		pair    = common.HexToAddress("0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852")
+		custom  = common.HexToAddress("0x397FF1542f962076d0BFE58eA045FfA2d347ACa0")
+		decoder = &rawSlotDecoder{slots: []common.Hash{{0x1}}}
+		logs    = []*types.Log{
+			pairCreatedLog(factory, common.HexToAddress("0x1"), common.HexToAddress("0x2"), pair, 1),
+			pairCreatedLog(factory, common.HexToAddress("0x1"), common.HexToAddress("0x3"), custom, 2),
+		}
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}, WatchFactoryPairs: true, MaxWatchlist: 1})
+	cache.WatchFactory(factory)
+	cache.RegisterDecoder(custom, decoder)
+	if added := cache.ProcessLogs(logs); len(added) != 0 {
+		t.Fatalf("Expected no pairs added to a full watchlist, got %v", added)
+	}
+	if cache.HasDecoder(pair) {
+		t.Error("Expected no decoder for a pair that is not watched")
+	}
+	if cache.decoders[custom] != decoder {
+		t.Error("Expected the existing decoder to be kept")
+	}
+
+	cache.RemoveFromWatchlist(pool)
+	cache.RegisterDecoder(custom, decoder)
+	if added := cache.ProcessLogs(logs[1:]); !slices.Equal(added, []common.Address{custom}) {
+		t.Fatalf("Expected pair %s added, got %v", custom.Hex(), added)
+	}
+	if cache.decoders[custom] != decoder {
+		t.Error("Expected the existing decoder not to be replaced")
+	}
+}
+
+func TestDecodePairCreated(t *testing.T) {
+	if want := common.HexToHash("0x0d3648bd0f6ba80134a33ba9275ac585d9d315f0ad8355cddefde31afa28d0e9"); pairCreatedTopic != want {
+		t.Fatalf("Unexpected PairCreated topic %s", pairCreatedTopic.Hex())
+	}
+	var (
+		factory = common.HexToAddress("0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f")
+		token0  = common.HexToAddress("0x1")
+		token1  = common.HexToAddress("0x2")
+		pair    = common.HexToAddress("0x3")
+	)
+	event, err := DecodePairCreated(pairCreatedLog(factory, token0, token1, pair, 7))
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if want := (PairCreated{Factory: factory, Token0: token0, Token1: token1, Pair: pair}); *event != want {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	dirty := pairCreatedLog(factory, token0, token1, pair, 7)
+	dirty.Data[0] = 0x01
+	truncated := pairCreatedLog(factory, token0, token1, pair, 7)
+	truncated.Data = truncated.Data[:32]
+	other := pairCreatedLog(factory, token0, token1, pair, 7)
+	other.Topics[0] = common.HexToHash("0x01")
+	for name, entry := range map[string]*types.Log{"dirty": dirty, "truncated": truncated, "other event": other} {
+		if _, err := DecodePairCreated(entry); err == nil {
+			t.Errorf("%s: expected decode error", name)
+		}
+	}
+}
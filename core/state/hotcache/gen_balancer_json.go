@@ -0,0 +1,66 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+var _ = (*balancerStateMarshaling)(nil)
+
+// MarshalJSON marshals as JSON.
+func (b BalancerState) MarshalJSON() ([]byte, error) {
+	type BalancerState struct {
+		Tokens   []common.Address   `json:"tokens"`
+		Weights  []*math.Decimal256 `json:"weights"`
+		Balances []*math.Decimal256 `json:"balances"`
+	}
+	var enc BalancerState
+	enc.Tokens = b.Tokens
+	if b.Weights != nil {
+		enc.Weights = make([]*math.Decimal256, len(b.Weights))
+		for k, v := range b.Weights {
+			enc.Weights[k] = (*math.Decimal256)(v)
+		}
+	}
+	if b.Balances != nil {
+		enc.Balances = make([]*math.Decimal256, len(b.Balances))
+		for k, v := range b.Balances {
+			enc.Balances[k] = (*math.Decimal256)(v)
+		}
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (b *BalancerState) UnmarshalJSON(input []byte) error {
+	type BalancerState struct {
+		Tokens   []common.Address   `json:"tokens"`
+		Weights  []*math.Decimal256 `json:"weights"`
+		Balances []*math.Decimal256 `json:"balances"`
+	}
+	var dec BalancerState
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Tokens != nil {
+		b.Tokens = dec.Tokens
+	}
+	if dec.Weights != nil {
+		b.Weights = make([]*big.Int, len(dec.Weights))
+		for k, v := range dec.Weights {
+			b.Weights[k] = (*big.Int)(v)
+		}
+	}
+	if dec.Balances != nil {
+		b.Balances = make([]*big.Int, len(dec.Balances))
+		for k, v := range dec.Balances {
+			b.Balances[k] = (*big.Int)(v)
+		}
+	}
+	return nil
+}
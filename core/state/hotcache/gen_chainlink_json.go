@@ -0,0 +1,49 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+var _ = (*chainlinkStateMarshaling)(nil)
+
+// MarshalJSON marshals as JSON.
+func (c ChainlinkState) MarshalJSON() ([]byte, error) {
+	type ChainlinkState struct {
+		Answer    *math.Decimal256 `json:"answer"`
+		UpdatedAt uint64           `json:"updatedAt"`
+		RoundID   uint64           `json:"roundId"`
+	}
+	var enc ChainlinkState
+	enc.Answer = (*math.Decimal256)(c.Answer)
+	enc.UpdatedAt = c.UpdatedAt
+	enc.RoundID = c.RoundID
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (c *ChainlinkState) UnmarshalJSON(input []byte) error {
+	type ChainlinkState struct {
+		Answer    *math.Decimal256 `json:"answer"`
+		UpdatedAt *uint64          `json:"updatedAt"`
+		RoundID   *uint64          `json:"roundId"`
+	}
+	var dec ChainlinkState
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Answer != nil {
+		c.Answer = (*big.Int)(dec.Answer)
+	}
+	if dec.UpdatedAt != nil {
+		c.UpdatedAt = *dec.UpdatedAt
+	}
+	if dec.RoundID != nil {
+		c.RoundID = *dec.RoundID
+	}
+	return nil
+}
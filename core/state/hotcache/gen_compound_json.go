@@ -0,0 +1,61 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+var _ = (*compoundStateMarshaling)(nil)
+
+// MarshalJSON marshals as JSON.
+func (c CompoundState) MarshalJSON() ([]byte, error) {
+	type CompoundState struct {
+		Cash               *math.Decimal256 `json:"cash"`
+		TotalBorrows       *math.Decimal256 `json:"totalBorrows"`
+		TotalReserves      *math.Decimal256 `json:"totalReserves"`
+		TotalSupply        *math.Decimal256 `json:"totalSupply"`
+		AccrualBlockNumber uint64           `json:"accrualBlockNumber"`
+	}
+	var enc CompoundState
+	enc.Cash = (*math.Decimal256)(c.Cash)
+	enc.TotalBorrows = (*math.Decimal256)(c.TotalBorrows)
+	enc.TotalReserves = (*math.Decimal256)(c.TotalReserves)
+	enc.TotalSupply = (*math.Decimal256)(c.TotalSupply)
+	enc.AccrualBlockNumber = c.AccrualBlockNumber
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (c *CompoundState) UnmarshalJSON(input []byte) error {
+	type CompoundState struct {
+		Cash               *math.Decimal256 `json:"cash"`
+		TotalBorrows       *math.Decimal256 `json:"totalBorrows"`
+		TotalReserves      *math.Decimal256 `json:"totalReserves"`
+		TotalSupply        *math.Decimal256 `json:"totalSupply"`
+		AccrualBlockNumber *uint64          `json:"accrualBlockNumber"`
+	}
+	var dec CompoundState
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Cash != nil {
+		c.Cash = (*big.Int)(dec.Cash)
+	}
+	if dec.TotalBorrows != nil {
+		c.TotalBorrows = (*big.Int)(dec.TotalBorrows)
+	}
+	if dec.TotalReserves != nil {
+		c.TotalReserves = (*big.Int)(dec.TotalReserves)
+	}
+	if dec.TotalSupply != nil {
+		c.TotalSupply = (*big.Int)(dec.TotalSupply)
+	}
+	if dec.AccrualBlockNumber != nil {
+		c.AccrualBlockNumber = *dec.AccrualBlockNumber
+	}
+	return nil
+}
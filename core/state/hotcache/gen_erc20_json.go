@@ -0,0 +1,52 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+var _ = (*erc20StateMarshaling)(nil)
+
+// MarshalJSON marshals as JSON.
+func (e ERC20State) MarshalJSON() ([]byte, error) {
+	type ERC20State struct {
+		TotalSupply *math.Decimal256                    `json:"totalSupply"`
+		Balances    map[common.Address]*math.Decimal256 `json:"balances"`
+	}
+	var enc ERC20State
+	enc.TotalSupply = (*math.Decimal256)(e.TotalSupply)
+	if e.Balances != nil {
+		enc.Balances = make(map[common.Address]*math.Decimal256, len(e.Balances))
+		for k, v := range e.Balances {
+			enc.Balances[k] = (*math.Decimal256)(v)
+		}
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (e *ERC20State) UnmarshalJSON(input []byte) error {
+	type ERC20State struct {
+		TotalSupply *math.Decimal256                    `json:"totalSupply"`
+		Balances    map[common.Address]*math.Decimal256 `json:"balances"`
+	}
+	var dec ERC20State
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.TotalSupply != nil {
+		e.TotalSupply = (*big.Int)(dec.TotalSupply)
+	}
+	if dec.Balances != nil {
+		e.Balances = make(map[common.Address]*big.Int, len(dec.Balances))
+		for k, v := range dec.Balances {
+			e.Balances[k] = (*big.Int)(v)
+		}
+	}
+	return nil
+}
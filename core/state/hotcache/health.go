@@ -0,0 +1,74 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import "github.com/ethereum/go-ethereum/common"
+
+// HealthReport bundles the signals telling whether the cache can be trusted,
+// see Cache.Health.
+type HealthReport struct {
+	Enabled          bool   `json:"enabled"`          // serving state, cleared after MaxValidationErrors
+	Healthy          bool   `json:"healthy"`          // not disabled by validation failures
+	ShadowMode       bool   `json:"shadowMode"`       // validating against canonical state
+	ValidationErrors uint64 `json:"validationErrors"` // mismatched slots found so far
+
+	// Staleness against the head passed to Health
+	HeadNumber uint64 `json:"headNumber"`
+	Lag        uint64 `json:"lag"`   // blocks the cache is behind the head
+	Stale      bool   `json:"stale"` // lag exceeds the tolerated lag, or nothing cached yet
+
+	// Snapshots retained for reorg protection, MaxSnapshots is zero if
+	// reorg protection is disabled
+	Snapshots    int `json:"snapshots"`
+	MaxSnapshots int `json:"maxSnapshots"`
+
+	// Last update
+	BlockNumber uint64      `json:"blockNumber"` // block the cache was last updated to
+	BlockHash   common.Hash `json:"blockHash"`   // block of the current snapshot
+	BlockTime   uint64      `json:"blockTime"`   // timestamp of the current snapshot's block
+	Contracts   int         `json:"contracts"`   // contracts in the current snapshot
+}
+
+// Health returns a report of the cache's safety signals, with staleness
+// measured against the given chain head: the cache is stale if it lags by
+// more than maxLag blocks, see IsStale. Trading code should only trust the
+// cache if it is enabled and not stale.
+func (c *Cache) Health(headNumber, maxLag uint64) HealthReport {
+	snapshot := c.GetSnapshot()
+	report := HealthReport{
+		Enabled:          c.IsEnabled(),
+		Healthy:          c.Healthy(),
+		ShadowMode:       c.config.ShadowMode,
+		ValidationErrors: c.stats.ValidationErrors.Load(),
+		HeadNumber:       headNumber,
+		Stale:            c.IsStale(headNumber, maxLag),
+		BlockNumber:      c.headNumber.Load(),
+		BlockHash:        snapshot.BlockHash,
+		BlockTime:        snapshot.BlockTime,
+		Contracts:        len(snapshot.Contracts),
+	}
+	if report.BlockNumber < headNumber {
+		report.Lag = headNumber - report.BlockNumber
+	}
+	c.snapshotMu.RLock()
+	report.Snapshots = c.snapshots.len()
+	c.snapshotMu.RUnlock()
+	if c.snapshots != nil {
+		report.MaxSnapshots = c.config.MaxSnapshots
+	}
+	return report
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestHealth(t *testing.T) {
+	var (
+		addr = common.HexToAddress("0x1")
+		slot = common.HexToHash("0x01")
+	)
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{addr}, MaxSnapshots: 4})
+	cache.RegisterDecoder(addr, &rawSlotDecoder{slots: []common.Hash{slot}})
+	if report := cache.Health(0, 0); !report.Stale || report.Contracts != 0 {
+		t.Errorf("Expected an empty cache to be stale, got %+v", report)
+	}
+
+	reader := newMockStateReader()
+	reader.setState(addr, slot, common.HexToHash("0x0a"))
+	header := testHeader(1)
+	header.Time = 1000
+	if err := cache.Update(header, reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	report := cache.Health(1, 0)
+	want := HealthReport{
+		Enabled:      true,
+		Healthy:      true,
+		ShadowMode:   true,
+		HeadNumber:   1,
+		Snapshots:    1,
+		MaxSnapshots: 4,
+		BlockNumber:  1,
+		BlockHash:    header.Hash(),
+		BlockTime:    1000,
+		Contracts:    1,
+	}
+	if report != want {
+		t.Errorf("Unexpected report after update:\nhave %+v\nwant %+v", report, want)
+	}
+
+	// Plant a validation error and let the chain move ahead of the cache
+	reader.setState(addr, slot, common.HexToHash("0x0b"))
+	if err := cache.Validate(reader); err == nil {
+		t.Fatal("Expected validation to fail")
+	}
+	report = cache.Health(10, 2)
+	if report.ValidationErrors != 1 {
+		t.Errorf("Expected 1 validation error, got %d", report.ValidationErrors)
+	}
+	if !report.Stale || report.Lag != 9 || report.HeadNumber != 10 {
+		t.Errorf("Expected a stale cache 9 blocks behind, got %+v", report)
+	}
+}
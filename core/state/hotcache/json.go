@@ -0,0 +1,158 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// knownContractTypes lists the built-in contract types for name lookups.
+var knownContractTypes = []ContractType{
+	ContractTypeUnknown,
+	ContractTypeUniswapV2,
+	ContractTypeUniswapV3,
+	ContractTypeAave,
+	ContractTypeCurve,
+	ContractTypeSushiSwap,
+	ContractTypeERC20,
+	ContractTypeChainlink,
+	ContractTypeBalancer,
+	ContractTypeCompound,
+	ContractTypeWETH,
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t ContractType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *ContractType) UnmarshalText(input []byte) error {
+	customContractTypesMu.RLock()
+	defer customContractTypesMu.RUnlock()
+	known, ok := contractTypeByName(string(input))
+	if !ok {
+		return fmt.Errorf("unknown contract type %q", input)
+	}
+	*t = known
+	return nil
+}
+
+// newDecodedState returns an empty decoded state value for the given contract
+// type, or nil if the type has no decoded representation.
+func newDecodedState(t ContractType) interface{} {
+	switch t {
+	case ContractTypeUniswapV2, ContractTypeSushiSwap:
+		return new(UniswapV2State)
+	case ContractTypeUniswapV3:
+		return new(UniswapV3State)
+	case ContractTypeERC20:
+		return new(ERC20State)
+	case ContractTypeChainlink:
+		return new(ChainlinkState)
+	case ContractTypeBalancer:
+		return new(BalancerState)
+	case ContractTypeCompound:
+		return new(CompoundState)
+	case ContractTypeWETH:
+		return new(WETHState)
+	default:
+		return nil
+	}
+}
+
+// contractStateJSON is the JSON representation of a ContractState. The type
+// tag makes the decoded payload self-describing.
+type contractStateJSON struct {
+	Address       common.Address              `json:"address"`
+	Type          ContractType                `json:"type"`
+	RawSlots      map[common.Hash]common.Hash `json:"rawSlots"`
+	Decoded       json.RawMessage             `json:"decoded,omitempty"`
+	NativeBalance *math.Decimal256            `json:"nativeBalance,omitempty"`
+	DecodeError   string                      `json:"decodeError,omitempty"`
+	Suspect       bool                        `json:"suspect,omitempty"`
+	Dead          bool                        `json:"dead,omitempty"`
+	LastUpdated   uint64                      `json:"lastUpdated"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s *ContractState) MarshalJSON() ([]byte, error) {
+	enc := contractStateJSON{
+		Address:       s.Address,
+		Type:          s.Type,
+		RawSlots:      s.rawSlots,
+		NativeBalance: (*math.Decimal256)(s.NativeBalance),
+		LastUpdated:   s.LastUpdated,
+		Suspect:       s.Suspect,
+		Dead:          s.Dead,
+	}
+	if s.DecodeError != nil {
+		enc.DecodeError = s.DecodeError.Error()
+	}
+	if s.Decoded != nil {
+		decoded, err := json.Marshal(s.Decoded)
+		if err != nil {
+			return nil, err
+		}
+		enc.Decoded = decoded
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *ContractState) UnmarshalJSON(input []byte) error {
+	var dec contractStateJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	s.Address = dec.Address
+	s.Type = dec.Type
+	s.rawSlots = dec.RawSlots
+	s.LastUpdated = dec.LastUpdated
+	s.Suspect = dec.Suspect
+	s.Dead = dec.Dead
+	s.NativeBalance = (*big.Int)(dec.NativeBalance)
+	s.Decoded = nil
+	s.DecodeError = nil
+	if dec.DecodeError != "" {
+		s.DecodeError = errors.New(dec.DecodeError)
+	}
+
+	if len(dec.Decoded) == 0 || string(dec.Decoded) == "null" {
+		return nil
+	}
+	decoded := newDecodedState(dec.Type)
+	if decoded == nil {
+		return fmt.Errorf("no decoded representation for contract type %s", dec.Type)
+	}
+	if err := json.Unmarshal(dec.Decoded, decoded); err != nil {
+		return fmt.Errorf("failed to decode %s state: %w", dec.Type, err)
+	}
+	s.Decoded = decoded
+	return nil
+}
+
+// SnapshotJSON returns the current snapshot encoded as JSON.
+func (c *Cache) SnapshotJSON() ([]byte, error) {
+	return json.Marshal(c.GetSnapshot())
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSnapshotJSONUniswapV2(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	reserve0, _ := new(big.Int).SetString("123456789012345678901234", 10)
+	v2 := &UniswapV2State{
+		Token0:             common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+		Token1:             common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		Reserve0:           reserve0,
+		Reserve1:           big.NewInt(500),
+		BlockTimestampLast: 1234567890,
+		Price0Cumulative:   big.NewInt(1),
+		Price1Cumulative:   big.NewInt(2),
+		KLast:              big.NewInt(3),
+	}
+	cache := New(Config{Enabled: true})
+	cache.current.Store(&Snapshot{
+		BlockNumber: 42,
+		BlockHash:   common.HexToHash("0xabcd"),
+		Contracts: map[common.Address]*ContractState{
+			pool: {
+				Address:     pool,
+				Type:        ContractTypeUniswapV2,
+				rawSlots:    map[common.Hash]common.Hash{uniswapV2SlotKLast: common.BigToHash(big.NewInt(3))},
+				Decoded:     v2,
+				LastUpdated: 42,
+			},
+		},
+	})
+
+	blob, err := cache.SnapshotJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	for _, want := range []string{
+		`"reserve0":"123456789012345678901234"`,
+		`"reserve1":"500"`,
+		`"type":"UniswapV2"`,
+		`"address":"` + strings.ToLower(pool.Hex()) + `"`,
+	} {
+		if !strings.Contains(string(blob), want) {
+			t.Errorf("Expected %s in JSON output: %s", want, blob)
+		}
+	}
+
+	// The type tag makes the decoded payload round-trippable
+	var restored Snapshot
+	if err := json.Unmarshal(blob, &restored); err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %v", err)
+	}
+	state := restored.Contracts[pool]
+	if state == nil {
+		t.Fatal("Pool missing from restored snapshot")
+	}
+	decoded, ok := state.Decoded.(*UniswapV2State)
+	if !ok {
+		t.Fatalf("Expected *UniswapV2State, got %T", state.Decoded)
+	}
+	if decoded.Reserve0.Cmp(reserve0) != 0 || decoded.Token1 != v2.Token1 {
+		t.Errorf("Round-trip mismatch: %s", decoded)
+	}
+	if restored.BlockNumber != 42 || state.rawSlots[uniswapV2SlotKLast] != common.BigToHash(big.NewInt(3)) {
+		t.Error("Round-trip lost snapshot metadata or raw slots")
+	}
+}
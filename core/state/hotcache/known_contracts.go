@@ -41,6 +41,40 @@ var UniswapV2PairsMainnet = map[string]common.Address{
 	"USDC/USDT": common.HexToAddress("0x3041CbD36888bECc7bbCBc0045E3B1f144466f5f"),
 }
 
+// Common mainnet SushiSwap pairs (Uniswap V2 fork, identical storage layout)
+var SushiSwapPairsMainnet = map[string]common.Address{
+	"WETH/USDC": common.HexToAddress("0x397FF1542f962076d0BFE58eA045FfA2d347ACa0"),
+	"WETH/USDT": common.HexToAddress("0x06da0fd433C1A5d7a4faa01111c044910A184553"),
+	"DAI/WETH":  common.HexToAddress("0xC3D03e4F041Fd4cD388c549Ee2A29a9E5075882f"),
+	"WBTC/WETH": common.HexToAddress("0xCEfF51756c56CeFFCA006cD410B03FFC46dd3a58"),
+}
+
+// Common mainnet Uniswap V3 pools (high-value pools for testing)
+var UniswapV3PoolsMainnet = map[string]common.Address{
+	"USDC/WETH 0.05%": common.HexToAddress("0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640"),
+	"USDC/WETH 0.3%":  common.HexToAddress("0x8ad599c3A0ff1De082011EFDDc58f1908eb6e6D8"),
+	"WETH/USDT 0.05%": common.HexToAddress("0x11b815efB8f581194ae79006d24E0d814B7697F6"),
+	"WBTC/WETH 0.3%":  common.HexToAddress("0xCBCdF9626bC03E24f779434178A73a0B4bad62eD"),
+	"DAI/USDC 0.01%":  common.HexToAddress("0x5777d92f208679DB4b9778590Fa3CAB3aC9e2168"),
+}
+
+// uniswapV3Immutables are the tokens and fee a Uniswap V3 pool is deployed
+// with, which are not held in its storage.
+type uniswapV3Immutables struct {
+	token0, token1 common.Address
+	fee            uint32
+}
+
+// uniswapV3PoolImmutablesMainnet holds the immutables of the pools in
+// UniswapV3PoolsMainnet, keyed by pool address.
+var uniswapV3PoolImmutablesMainnet = map[common.Address]uniswapV3Immutables{
+	UniswapV3PoolsMainnet["USDC/WETH 0.05%"]: {TokenAddresses.Mainnet.USDC, TokenAddresses.Mainnet.WETH, 500},
+	UniswapV3PoolsMainnet["USDC/WETH 0.3%"]:  {TokenAddresses.Mainnet.USDC, TokenAddresses.Mainnet.WETH, 3000},
+	UniswapV3PoolsMainnet["WETH/USDT 0.05%"]: {TokenAddresses.Mainnet.WETH, TokenAddresses.Mainnet.USDT, 500},
+	UniswapV3PoolsMainnet["WBTC/WETH 0.3%"]:  {TokenAddresses.Mainnet.WBTC, TokenAddresses.Mainnet.WETH, 3000},
+	UniswapV3PoolsMainnet["DAI/USDC 0.01%"]:  {TokenAddresses.Mainnet.DAI, TokenAddresses.Mainnet.USDC, 100},
+}
+
 // Token addresses for reference
 var TokenAddresses = struct {
 	Mainnet struct {
@@ -94,15 +128,29 @@ func GetDefaultWatchlist(chainID uint64) []common.Address {
 	}
 }
 
-// RegisterDefaultDecoders registers decoders for all known Uniswap V2 pairs.
+// RegisterDefaultDecoders registers decoders for all known Uniswap V2 and
+// SushiSwap pairs and Uniswap V3 pools. Each V3 pool gets a decoder carrying
+// its tokens, fee and tick spacing; pools added to UniswapV3PoolsMainnet
+// without known immutables get a bare decoder.
 func RegisterDefaultDecoders(cache *Cache, chainID uint64) {
 	decoder := &UniswapV2Decoder{}
+	sushiDecoder := NewUniswapV2ForkDecoder(ContractTypeSushiSwap)
 	
 	switch chainID {
 	case 1: // Mainnet
 		for _, addr := range UniswapV2PairsMainnet {
 			cache.RegisterDecoder(addr, decoder)
 		}
+		for _, addr := range SushiSwapPairsMainnet {
+			cache.RegisterDecoder(addr, sushiDecoder)
+		}
+		for _, addr := range UniswapV3PoolsMainnet {
+			v3Decoder := &UniswapV3Decoder{}
+			if pool, ok := uniswapV3PoolImmutablesMainnet[addr]; ok {
+				v3Decoder = NewUniswapV3Decoder(pool.token0, pool.token1, pool.fee)
+			}
+			cache.RegisterDecoder(addr, v3Decoder)
+		}
 	case 11155111: // Sepolia
 		// Register decoders for Sepolia pairs when discovered
 	}
@@ -0,0 +1,83 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindowSize is the number of most recent update durations kept for
+// percentile readouts.
+const latencyWindowSize = 1024
+
+// latencyWindow keeps the most recent update durations. Unlike the metrics
+// histograms it records regardless of whether metrics collection is enabled,
+// and is not shared between caches.
+type latencyWindow struct {
+	samples [latencyWindowSize]time.Duration
+	next    int // position of the next sample
+	count   int // number of samples held, at most latencyWindowSize
+	mu      sync.Mutex
+}
+
+// add records a duration, displacing the oldest one once the window is full.
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+	w.count = min(w.count+1, latencyWindowSize)
+}
+
+// percentile returns the nearest-rank p-th percentile (0 < p <= 1) of the
+// recorded durations, or zero if none was recorded.
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	w.mu.Lock()
+	sorted := slices.Clone(w.samples[:w.count])
+	w.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0
+	}
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// recordUpdateTime records the duration of an update.
+func (c *Cache) recordUpdateTime(d time.Duration) {
+	c.updateLatency.add(d)
+	if c.metrics != nil {
+		c.metrics.updateTime.Update(d.Nanoseconds())
+	}
+}
+
+// UpdateLatencyP50 returns the median duration of the most recent updates,
+// or zero if there were none.
+func (c *Cache) UpdateLatencyP50() time.Duration {
+	return c.updateLatency.percentile(0.50)
+}
+
+// UpdateLatencyP99 returns the 99th percentile duration of the most recent
+// updates, or zero if there were none. Watch it to notice when the watchlist
+// grows large enough to slow down block import.
+func (c *Cache) UpdateLatencyP99() time.Duration {
+	return c.updateLatency.percentile(0.99)
+}
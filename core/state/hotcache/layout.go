@@ -0,0 +1,204 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// MappingSlot returns the storage slot of the value stored under key in the
+// Solidity mapping declared at mappingSlot, keccak256(key . mappingSlot).
+// Value type keys such as addresses and integers must be left-padded to 32
+// bytes; string and bytes keys are used unpadded.
+func MappingSlot(key []byte, mappingSlot common.Hash) common.Hash {
+	return crypto.Keccak256Hash(key, mappingSlot[:])
+}
+
+// NestedMappingSlot returns the storage slot of the value reached by indexing
+// the (nested) mapping declared at mappingSlot with each of keys in turn, as in
+// allowance[owner][spender]. Keys are padded as for MappingSlot. Without keys
+// mappingSlot itself is returned.
+func NestedMappingSlot(mappingSlot common.Hash, keys ...[]byte) common.Hash {
+	slot := mappingSlot
+	for _, key := range keys {
+		slot = MappingSlot(key, slot)
+	}
+	return slot
+}
+
+// DynamicArraySlot returns the storage slot of the element at index of the
+// Solidity dynamic array declared at baseSlot, which holds its length. The
+// elements start at keccak256(baseSlot); for elements occupying several slots
+// index must be scaled by the element size, and for elements packed several
+// to a slot divided by their count per slot.
+func DynamicArraySlot(baseSlot common.Hash, index uint64) common.Hash {
+	start := new(uint256.Int).SetBytes32(crypto.Keccak256(baseSlot[:]))
+	return start.AddUint64(start, index).Bytes32()
+}
+
+// FieldType is the Solidity type of a field in contract storage.
+type FieldType uint8
+
+const (
+	FieldUint    FieldType = iota // unsigned integer
+	FieldInt                      // two's complement signed integer
+	FieldAddress                  // address, at most 160 bits of the field may be set
+)
+
+// StorageField locates a value in contract storage. Solidity packs the fields
+// sharing a slot from the least significant bit, so Offset counts bits from
+// the low end of the slot.
+type StorageField struct {
+	Name     string      // key of the value in LayoutValues
+	Slot     common.Hash // storage slot holding the field
+	Offset   uint        // bit offset from the least significant bit
+	Width    uint        // bit width, zero means the rest of the slot
+	Type     FieldType   // how the bits are interpreted
+	Optional bool        // a missing slot decodes to zero instead of failing
+}
+
+// width returns the bit width of the field.
+func (f StorageField) width() uint {
+	if f.Width == 0 {
+		return 256 - f.Offset
+	}
+	return f.Width
+}
+
+// Decode extracts the field from the given slots.
+func (f StorageField) Decode(slots map[common.Hash]common.Hash) (*big.Int, error) {
+	width := f.width()
+	if f.Offset >= 256 || f.Offset+width > 256 {
+		return nil, fmt.Errorf("field %s exceeds its slot (offset %d, width %d)", f.Name, f.Offset, width)
+	}
+	word, ok := slots[f.Slot]
+	if !ok {
+		if f.Optional {
+			return new(big.Int), nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrMissingSlot, f.Name)
+	}
+	value := new(big.Int).SetBytes(word[:])
+	value.Rsh(value, f.Offset)
+	if width < 256 {
+		value.And(value, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), width), big.NewInt(1)))
+	}
+	switch f.Type {
+	case FieldInt:
+		if value.Bit(int(width)-1) == 1 {
+			value.Sub(value, new(big.Int).Lsh(big.NewInt(1), width))
+		}
+	case FieldAddress:
+		if value.BitLen() > 8*common.AddressLength {
+			return nil, fmt.Errorf("non-zero high bits in address field %s", f.Name)
+		}
+	}
+	return value, nil
+}
+
+// StorageLayout describes the storage fields of a contract declaratively, so a
+// decoder can be written as data rather than bit manipulation.
+type StorageLayout []StorageField
+
+// Slots returns the distinct slots of the layout in field order.
+func (l StorageLayout) Slots() []common.Hash {
+	slots := make([]common.Hash, 0, len(l))
+	seen := make(map[common.Hash]bool, len(l))
+	for _, field := range l {
+		if !seen[field.Slot] {
+			seen[field.Slot] = true
+			slots = append(slots, field.Slot)
+		}
+	}
+	return slots
+}
+
+// RequiredSlots returns the distinct slots of the layout holding at least one
+// non-optional field, in field order, suitable as a decoder's RequiredSlots.
+func (l StorageLayout) RequiredSlots() []common.Hash {
+	return l.filterSlots(false)
+}
+
+// OptionalSlots returns the distinct slots of the layout holding only optional
+// fields, in field order, suitable as an OptionalSlotDecoder's OptionalSlots.
+func (l StorageLayout) OptionalSlots() []common.Hash {
+	return l.filterSlots(true)
+}
+
+// filterSlots returns the distinct slots whose fields are all optional, or
+// those with a non-optional field.
+func (l StorageLayout) filterSlots(optional bool) []common.Hash {
+	required := make(map[common.Hash]bool, len(l))
+	for _, field := range l {
+		if !field.Optional {
+			required[field.Slot] = true
+		}
+	}
+	var slots []common.Hash
+	for _, slot := range l.Slots() {
+		if required[slot] != optional {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// Decode extracts every field of the layout from the given slots.
+func (l StorageLayout) Decode(slots map[common.Hash]common.Hash) (LayoutValues, error) {
+	values := make(LayoutValues, len(l))
+	for _, field := range l {
+		value, err := field.Decode(slots)
+		if err != nil {
+			return nil, err
+		}
+		values[field.Name] = value
+	}
+	return values, nil
+}
+
+// LayoutValues holds the fields decoded by a StorageLayout, keyed by name.
+// Absent names read as zero.
+type LayoutValues map[string]*big.Int
+
+// Big returns a copy of the named value.
+func (v LayoutValues) Big(name string) *big.Int {
+	if value, ok := v[name]; ok {
+		return new(big.Int).Set(value)
+	}
+	return new(big.Int)
+}
+
+// Uint64 returns the named value truncated to 64 bits.
+func (v LayoutValues) Uint64(name string) uint64 {
+	if value, ok := v[name]; ok {
+		return value.Uint64()
+	}
+	return 0
+}
+
+// Address returns the named value as an address.
+func (v LayoutValues) Address(name string) common.Address {
+	if value, ok := v[name]; ok {
+		return common.BigToAddress(value)
+	}
+	return common.Address{}
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestStorageFieldDecode(t *testing.T) {
+	slot := common.BigToHash(big.NewInt(1))
+	// 0x...ff80 | 0x2a << 16 | address-sized value in the full word
+	word := common.HexToHash("0x00000000000000000000000000000000000000000000000000000000002aff80")
+	slots := map[common.Hash]common.Hash{slot: word}
+
+	tests := []struct {
+		field StorageField
+		want  *big.Int
+	}{
+		{StorageField{Name: "low", Slot: slot, Width: 8}, big.NewInt(0x80)},
+		{StorageField{Name: "signed", Slot: slot, Width: 8, Type: FieldInt}, big.NewInt(-128)},
+		{StorageField{Name: "mid", Slot: slot, Offset: 16, Width: 8}, big.NewInt(0x2a)},
+		{StorageField{Name: "rest", Slot: slot, Offset: 8}, big.NewInt(0x2aff)},
+		{StorageField{Name: "addr", Slot: slot, Type: FieldAddress}, big.NewInt(0x2aff80)},
+		{StorageField{Name: "missing", Slot: common.Hash{}, Optional: true}, new(big.Int)},
+	}
+	for _, tt := range tests {
+		have, err := tt.field.Decode(slots)
+		if err != nil {
+			t.Errorf("%s: decode failed: %v", tt.field.Name, err)
+			continue
+		}
+		if have.Cmp(tt.want) != 0 {
+			t.Errorf("%s: have %s, want %s", tt.field.Name, have, tt.want)
+		}
+	}
+
+	dirty := map[common.Hash]common.Hash{slot: common.HexToHash("0x01" + common.Bytes2Hex(make([]byte, 31)))}
+	for _, field := range []StorageField{
+		{Name: "required", Slot: common.Hash{}},
+		{Name: "overflow", Slot: slot, Offset: 200, Width: 64},
+		{Name: "dirty", Slot: slot, Type: FieldAddress},
+	} {
+		if _, err := field.Decode(dirty); err == nil {
+			t.Errorf("%s: expected decode error", field.Name)
+		}
+	}
+}
+
+// decodeUniswapV2Manual is the hand-written bit-shifting decode the layout
+// based UniswapV2Decoder replaced, kept as a reference.
+func decodeUniswapV2Manual(slots map[common.Hash]common.Hash) *UniswapV2State {
+	state := &UniswapV2State{
+		Token0:           common.BytesToAddress(slots[uniswapV2SlotToken0].Bytes()),
+		Token1:           common.BytesToAddress(slots[uniswapV2SlotToken1].Bytes()),
+		Reserve0:         new(big.Int),
+		Reserve1:         new(big.Int),
+		Price0Cumulative: new(big.Int).SetBytes(slots[uniswapV2SlotPrice0Cumulative].Bytes()),
+		Price1Cumulative: new(big.Int).SetBytes(slots[uniswapV2SlotPrice1Cumulative].Bytes()),
+		KLast:            new(big.Int).SetBytes(slots[uniswapV2SlotKLast].Bytes()),
+		FeeBps:           uniswapV2DefaultFeeBps,
+	}
+	reserves := slots[uniswapV2SlotReserves]
+	fullValue := new(big.Int).SetBytes(reserves[:])
+	mask112 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 112), big.NewInt(1))
+	state.Reserve0.And(fullValue, mask112)
+	state.Reserve1.And(new(big.Int).Rsh(fullValue, 112), mask112)
+	state.BlockTimestampLast = uint32(new(big.Int).Rsh(fullValue, 224).Uint64())
+	return state
+}
+
+func TestUniswapV2LayoutMatchesManualDecode(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	randomHash := func() common.Hash {
+		var h common.Hash
+		for i := range h {
+			h[i] = byte(rng.UintN(256))
+		}
+		return h
+	}
+	randomAddress := func() common.Hash {
+		var addr common.Address
+		copy(addr[:], randomHash().Bytes())
+		return common.BytesToHash(addr.Bytes())
+	}
+	decoder := &UniswapV2Decoder{}
+	for i := 0; i < 100; i++ {
+		slots := map[common.Hash]common.Hash{
+			uniswapV2SlotToken0:           randomAddress(),
+			uniswapV2SlotToken1:           randomAddress(),
+			uniswapV2SlotReserves:         randomHash(),
+			uniswapV2SlotPrice0Cumulative: randomHash(),
+			uniswapV2SlotPrice1Cumulative: randomHash(),
+			uniswapV2SlotKLast:            randomHash(),
+		}
+		if i%10 == 0 {
+			slots[uniswapV2SlotReserves] = common.Hash{} // leading zero bytes
+		}
+		decoded, err := decoder.Decode(slots)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		have, want := decoded.(*UniswapV2State), decodeUniswapV2Manual(slots)
+		if have.Token0 != want.Token0 || have.Token1 != want.Token1 ||
+			have.Reserve0.Cmp(want.Reserve0) != 0 || have.Reserve1.Cmp(want.Reserve1) != 0 ||
+			have.BlockTimestampLast != want.BlockTimestampLast ||
+			have.Price0Cumulative.Cmp(want.Price0Cumulative) != 0 ||
+			have.Price1Cumulative.Cmp(want.Price1Cumulative) != 0 ||
+			have.KLast.Cmp(want.KLast) != 0 || have.FeeBps != want.FeeBps {
+			t.Fatalf("Layout decode differs from manual decode:\nhave %v\nwant %v", have, want)
+		}
+	}
+}
+
+func TestStorageLayoutOptionalSlots(t *testing.T) {
+	var (
+		a = common.BigToHash(big.NewInt(1))
+		b = common.BigToHash(big.NewInt(2))
+		c = common.BigToHash(big.NewInt(3))
+	)
+	layout := StorageLayout{
+		{Name: "x", Slot: a, Width: 128, Optional: true},
+		{Name: "y", Slot: a, Offset: 128}, // a required field makes the whole slot required
+		{Name: "z", Slot: b, Optional: true},
+		{Name: "w", Slot: c},
+	}
+	if have, want := layout.RequiredSlots(), []common.Hash{a, c}; !slices.Equal(have, want) {
+		t.Errorf("Unexpected required slots: have %v, want %v", have, want)
+	}
+	if have, want := layout.OptionalSlots(), []common.Hash{b}; !slices.Equal(have, want) {
+		t.Errorf("Unexpected optional slots: have %v, want %v", have, want)
+	}
+}
+
+func TestMappingSlot(t *testing.T) {
+	// keccak256(abi.encode(address(0), 0)), the balance of the zero address
+	// in a mapping declared first
+	want := common.HexToHash("0xad3228b676f7d3cd4284a5443f17f1962b36e491b30a40b2405849e597ba5fb5")
+	if got := MappingSlot(common.LeftPadBytes(common.Address{}.Bytes(), 32), common.Hash{}); got != want {
+		t.Errorf("Expected %s, got %s", want.Hex(), got.Hex())
+	}
+	// Unpadded keys hash differently
+	if got := MappingSlot(common.Address{}.Bytes(), common.Hash{}); got == want {
+		t.Error("Expected unpadded key to yield a different slot")
+	}
+}
+
+func TestDynamicArraySlot(t *testing.T) {
+	tests := []struct {
+		base  common.Hash
+		index uint64
+		want  common.Hash
+	}{
+		// keccak256(uint256(0)) and keccak256(uint256(1)), the first element
+		// of arrays declared in slots 0 and 1
+		{common.Hash{}, 0, common.HexToHash("0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563")},
+		{common.Hash{}, 1, common.HexToHash("0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e564")},
+		{common.BigToHash(big.NewInt(1)), 0, common.HexToHash("0xb10e2d527612073b26eecdfd717e6a320cf44b4afac2b0732d9fcbe2b7fa0cf6")},
+		{common.BigToHash(big.NewInt(1)), 0x10, common.HexToHash("0xb10e2d527612073b26eecdfd717e6a320cf44b4afac2b0732d9fcbe2b7fa0d06")},
+	}
+	for _, tt := range tests {
+		if got := DynamicArraySlot(tt.base, tt.index); got != tt.want {
+			t.Errorf("Element %d of array at %s: expected %s, got %s", tt.index, tt.base.Hex(), tt.want.Hex(), got.Hex())
+		}
+	}
+}
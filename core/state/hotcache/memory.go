@@ -0,0 +1,99 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Approximate per-entry costs of the snapshot maps: key, value and the
+// amortized bucket overhead of Go maps.
+const (
+	mapEntryOverhead  = 16
+	contractEntrySize = common.AddressLength + int(unsafe.Sizeof(uintptr(0))) + mapEntryOverhead
+	slotEntrySize     = 2*common.HashLength + mapEntryOverhead
+)
+
+// MemoryStats is an approximate memory footprint of the cached snapshots.
+// Contract states shared between retained snapshots are counted once.
+type MemoryStats struct {
+	CurrentBytes  uint64 `json:"currentBytes"`  // Size of the current snapshot
+	RetainedBytes uint64 `json:"retainedBytes"` // Size of all retained snapshots and the current one
+	Snapshots     int    `json:"snapshots"`     // Number of distinct retained snapshots
+	Contracts     int    `json:"contracts"`     // Contracts in the current snapshot
+	RawSlots      int    `json:"rawSlots"`      // Raw slots in the current snapshot
+}
+
+// MemoryStats estimates the memory held by the current and retained snapshots
+// from the fixed sizes of their structures plus map overheads. Heap-allocated
+// contents of decoded states, such as big integer words, are not included.
+func (c *Cache) MemoryStats() MemoryStats {
+	var (
+		stats     MemoryStats
+		snapshots = make(map[*Snapshot]struct{})
+		states    = make(map[*ContractState]struct{})
+	)
+	c.snapshotMu.RLock()
+	c.snapshots.forEach(func(s *Snapshot) { snapshots[s] = struct{}{} })
+	current := c.current.Load()
+	c.snapshotMu.RUnlock()
+
+	// Size the current snapshot first so it is never discounted as shared
+	stats.CurrentBytes = snapshotBytes(current, states)
+	stats.RetainedBytes = stats.CurrentBytes
+	stats.Contracts = len(current.Contracts)
+	for _, state := range current.Contracts {
+		stats.RawSlots += len(state.rawSlots)
+	}
+	for s := range snapshots {
+		if s != current {
+			stats.RetainedBytes += snapshotBytes(s, states)
+		}
+	}
+	stats.Snapshots = len(snapshots)
+	return stats
+}
+
+// snapshotBytes estimates the size of a snapshot, skipping contract states
+// already in seen and adding the others to it.
+func snapshotBytes(s *Snapshot, seen map[*ContractState]struct{}) uint64 {
+	size := uint64(unsafe.Sizeof(*s)) + uint64(len(s.Contracts)*contractEntrySize)
+	for _, state := range s.Contracts {
+		if _, ok := seen[state]; ok {
+			continue
+		}
+		seen[state] = struct{}{}
+		size += contractStateBytes(state)
+	}
+	return size
+}
+
+// contractStateBytes estimates the size of a contract state.
+func contractStateBytes(state *ContractState) uint64 {
+	size := uint64(unsafe.Sizeof(*state)) + uint64(len(state.rawSlots)*slotEntrySize)
+	if state.Decoded != nil {
+		typ := reflect.TypeOf(state.Decoded)
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		size += uint64(typ.Size())
+	}
+	return size
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMemoryStats(t *testing.T) {
+	measure := func(contracts int) MemoryStats {
+		var watchlist []common.Address
+		for i := 1; i <= contracts; i++ {
+			watchlist = append(watchlist, common.BigToAddress(big.NewInt(int64(i))))
+		}
+		cache := New(Config{Enabled: true, Watchlist: watchlist})
+		for _, addr := range watchlist {
+			cache.RegisterDecoder(addr, &UniswapV2Decoder{})
+		}
+		for n := uint64(1); n <= 3; n++ {
+			if err := cache.Update(testHeader(n), newMockStateReader()); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+		}
+		return cache.MemoryStats()
+	}
+	small, large := measure(2), measure(20)
+
+	if small.Contracts != 2 || large.Contracts != 20 {
+		t.Errorf("Unexpected contract counts %d and %d", small.Contracts, large.Contracts)
+	}
+	slots := len(uniswapV2Layout.Slots())
+	if small.RawSlots != 2*slots || large.RawSlots != 20*slots {
+		t.Errorf("Unexpected raw slot counts %d and %d", small.RawSlots, large.RawSlots)
+	}
+	if small.Snapshots != 3 || large.Snapshots != 3 {
+		t.Errorf("Expected 3 retained snapshots, got %d and %d", small.Snapshots, large.Snapshots)
+	}
+	if large.CurrentBytes <= small.CurrentBytes || large.RetainedBytes <= small.RetainedBytes {
+		t.Errorf("Expected the estimate to grow with the watchlist: %+v vs %+v", small, large)
+	}
+	if small.RetainedBytes < 3*small.CurrentBytes {
+		t.Errorf("Expected retained bytes %d to cover 3 snapshots of %d", small.RetainedBytes, small.CurrentBytes)
+	}
+}
+
+func TestDisableReorgProtection(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}, DisableReorgProtection: true, ReuseSnapshots: true})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+
+	reader := newMockStateReader()
+	chain := testChain(nil, 1, 200, "")
+	var first MemoryStats
+	for i, header := range chain {
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(big.NewInt(int64(i+1))))
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if i == 0 {
+			first = cache.MemoryStats()
+		}
+	}
+	last := cache.MemoryStats()
+	if last.RetainedBytes != first.RetainedBytes || last.RetainedBytes != last.CurrentBytes {
+		t.Errorf("Expected flat memory with retention disabled, first %+v, last %+v", first, last)
+	}
+	if last.Snapshots != 0 {
+		t.Errorf("Expected no retained snapshots, got %d", last.Snapshots)
+	}
+
+	head := chain[len(chain)-1]
+	if cache.GetSnapshotByHash(head.Hash()) != cache.GetSnapshot() {
+		t.Error("Expected the current snapshot by its hash")
+	}
+	if cache.GetSnapshotByHash(chain[0].Hash()) != nil {
+		t.Error("Expected no snapshot of an earlier block")
+	}
+	newChain := testChain(chain[len(chain)-2], 200, 201, "new")
+	if err := cache.HandleReorg(chain[len(chain)-2:], newChain, staticStates(reader)); !errors.Is(err, ErrReorgProtectionDisabled) {
+		t.Errorf("Expected ErrReorgProtectionDisabled, got %v", err)
+	}
+}
+
+// TestDisableReorgProtectionConcurrentReads reads the current snapshot while
+// it is being replaced, which must not race with recycling when no snapshots
+// are retained. Run with -race.
+func TestDisableReorgProtectionConcurrentReads(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}, DisableReorgProtection: true, ReuseSnapshots: true})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+
+	var (
+		stop = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				snapshot := cache.GetSnapshot()
+				for _, state := range snapshot.Contracts {
+					state.ForEachSlot(func(common.Hash, common.Hash) {})
+				}
+			}
+		}()
+	}
+	reader := newMockStateReader()
+	for i, header := range testChain(nil, 1, 1000, "") {
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(big.NewInt(int64(i+1))))
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
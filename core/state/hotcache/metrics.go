@@ -0,0 +1,173 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// cacheMetrics holds the metrics reported by an enabled cache. Disabled caches
+// do not register any metrics.
+type cacheMetrics struct {
+	hits             *metrics.Counter
+	misses           *metrics.Counter
+	updates          *metrics.Counter
+	validationErrors *metrics.Counter
+	reorgs           *metrics.Counter
+	fallbacks        *metrics.Counter
+
+	watchlist *metrics.Gauge
+	snapshots *metrics.Gauge
+	block     *metrics.Gauge
+
+	updateContractTime metrics.Histogram
+	updateTime         metrics.Histogram
+}
+
+// newCacheMetrics registers the cache metrics in the default registry.
+func newCacheMetrics() *cacheMetrics {
+	return &cacheMetrics{
+		hits:             metrics.GetOrRegisterCounter("hotcache/hits", nil),
+		misses:           metrics.GetOrRegisterCounter("hotcache/misses", nil),
+		updates:          metrics.GetOrRegisterCounter("hotcache/updates", nil),
+		validationErrors: metrics.GetOrRegisterCounter("hotcache/validation/errors", nil),
+		reorgs:           metrics.GetOrRegisterCounter("hotcache/reorgs", nil),
+		fallbacks:        metrics.GetOrRegisterCounter("hotcache/fallbacks", nil),
+
+		watchlist: metrics.GetOrRegisterGauge("hotcache/watchlist", nil),
+		snapshots: metrics.GetOrRegisterGauge("hotcache/snapshots", nil),
+		block:     metrics.GetOrRegisterGauge("hotcache/block", nil),
+
+		updateContractTime: metrics.GetOrRegisterHistogramLazy("hotcache/update/contract", nil, func() metrics.Sample {
+			return metrics.NewExpDecaySample(1028, 0.015)
+		}),
+		updateTime: metrics.GetOrRegisterHistogramLazy("hotcache/update/total", nil, func() metrics.Sample {
+			return metrics.NewExpDecaySample(1028, 0.015)
+		}),
+	}
+}
+
+// ExportPoolMetrics registers gauges of the reserves and the price of token0 in
+// terms of token1 (in raw token units) of every cached Uniswap V2 (or fork)
+// pool in registry, or the default registry if nil. The gauges are named
+// hotcache/pool/<address>/{reserve0,reserve1,price}, updated whenever a
+// snapshot is published and unregistered once a pool leaves the cache.
+// Calling it again moves the gauges to the new registry. It fails unless
+// Config.PoolMetrics is set.
+func (c *Cache) ExportPoolMetrics(registry metrics.Registry) error {
+	if !c.config.PoolMetrics {
+		return fmt.Errorf("%w: pool metrics not enabled", ErrInvalidConfig)
+	}
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	c.poolMetrics.export(registry, c.GetSnapshot())
+	return nil
+}
+
+// poolGauges holds the gauges exported for a single Uniswap V2 (or fork) pool.
+type poolGauges struct {
+	reserve0 *metrics.GaugeFloat64
+	reserve1 *metrics.GaugeFloat64
+	price    *metrics.GaugeFloat64
+}
+
+// poolExporter maintains the per-pool gauges registered by ExportPoolMetrics.
+// It does nothing until a registry is set.
+type poolExporter struct {
+	registry metrics.Registry
+	pools    map[common.Address]*poolGauges
+	lock     sync.Mutex
+}
+
+// poolMetricName returns the name of a pool gauge. The go-ethereum registry has
+// no labels, so the pool address is part of the name.
+func poolMetricName(pool common.Address, name string) string {
+	return fmt.Sprintf("hotcache/pool/%s/%s", pool.Hex(), name)
+}
+
+// export switches the gauges to the given registry and updates them from the
+// snapshot. Gauges registered in a previous registry are removed from it.
+func (e *poolExporter) export(registry metrics.Registry, snapshot *Snapshot) {
+	e.lock.Lock()
+	if e.registry != nil {
+		for pool := range e.pools {
+			e.unregister(pool)
+		}
+	}
+	e.registry = registry
+	e.pools = make(map[common.Address]*poolGauges)
+	e.lock.Unlock()
+
+	e.update(snapshot)
+}
+
+// update sets the gauges of every decoded V2 pool in the snapshot, registering
+// them for new pools and unregistering those of pools no longer cached.
+func (e *poolExporter) update(snapshot *Snapshot) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.registry == nil {
+		return
+	}
+	for pool := range e.pools {
+		if state, ok := snapshot.Contracts[pool]; !ok || !isDecodedV2(state) {
+			e.unregister(pool)
+		}
+	}
+	for pool, state := range snapshot.Contracts {
+		if !isDecodedV2(state) {
+			continue
+		}
+		gauges, ok := e.pools[pool]
+		if !ok {
+			gauges = &poolGauges{
+				reserve0: metrics.GetOrRegisterGaugeFloat64(poolMetricName(pool, "reserve0"), e.registry),
+				reserve1: metrics.GetOrRegisterGaugeFloat64(poolMetricName(pool, "reserve1"), e.registry),
+				price:    metrics.GetOrRegisterGaugeFloat64(poolMetricName(pool, "price"), e.registry),
+			}
+			e.pools[pool] = gauges
+		}
+		v2 := state.Decoded.(*UniswapV2State)
+		reserve0, _ := new(big.Float).SetInt(v2.Reserve0).Float64()
+		reserve1, _ := new(big.Float).SetInt(v2.Reserve1).Float64()
+		price, _ := v2.GetPrice().Float64()
+		gauges.reserve0.Update(reserve0)
+		gauges.reserve1.Update(reserve1)
+		gauges.price.Update(price)
+	}
+}
+
+// unregister removes the gauges of a pool. The caller must hold the lock.
+func (e *poolExporter) unregister(pool common.Address) {
+	for _, name := range []string{"reserve0", "reserve1", "price"} {
+		e.registry.Unregister(poolMetricName(pool, name))
+	}
+	delete(e.pools, pool)
+}
+
+// isDecodedV2 reports whether a contract state holds a decoded V2 pool.
+func isDecodedV2(state *ContractState) bool {
+	_, ok := state.Decoded.(*UniswapV2State)
+	return ok && state.DecodeError == nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+func TestCacheMetrics(t *testing.T) {
+	if New(DefaultConfig()).metrics != nil {
+		t.Fatal("Disabled cache should not register metrics")
+	}
+
+	addr := common.HexToAddress("0x1")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	m := cache.metrics
+
+	var (
+		updates = m.updates.Snapshot().Count()
+		hits    = m.hits.Snapshot().Count()
+		misses  = m.misses.Snapshot().Count()
+	)
+	if err := cache.Update(testHeader(7), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	cache.GetContractState(addr)
+	cache.GetContractState(common.HexToAddress("0x2"))
+
+	if n := m.updates.Snapshot().Count() - updates; n != 1 {
+		t.Errorf("Expected 1 update, got %d", n)
+	}
+	if n := m.hits.Snapshot().Count() - hits; n != 1 {
+		t.Errorf("Expected 1 hit, got %d", n)
+	}
+	if n := m.misses.Snapshot().Count() - misses; n != 1 {
+		t.Errorf("Expected 1 miss, got %d", n)
+	}
+	if block := m.block.Snapshot().Value(); block != 7 {
+		t.Errorf("Expected cached block 7, got %d", block)
+	}
+	if n := m.snapshots.Snapshot().Value(); n != 1 {
+		t.Errorf("Expected 1 retained snapshot, got %d", n)
+	}
+	if n := m.watchlist.Snapshot().Value(); n != 1 {
+		t.Errorf("Expected watchlist size 1, got %d", n)
+	}
+}
+
+func TestUpdateLatencyPercentiles(t *testing.T) {
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{common.HexToAddress("0x1")}})
+	if p50, p99 := cache.UpdateLatencyP50(), cache.UpdateLatencyP99(); p50 != 0 || p99 != 0 {
+		t.Fatalf("Expected zero latencies without updates, got %v and %v", p50, p99)
+	}
+	// Durations of 1ms to 100ms in shuffled order
+	for i := 0; i < 100; i++ {
+		cache.recordUpdateTime(time.Duration((i*37)%100+1) * time.Millisecond)
+	}
+	if p50 := cache.UpdateLatencyP50(); p50 != 50*time.Millisecond {
+		t.Errorf("Expected p50 of 50ms, got %v", p50)
+	}
+	if p99 := cache.UpdateLatencyP99(); p99 != 99*time.Millisecond {
+		t.Errorf("Expected p99 of 99ms, got %v", p99)
+	}
+	// Old durations are displaced once the window is full
+	for i := 0; i < latencyWindowSize; i++ {
+		cache.recordUpdateTime(time.Second)
+	}
+	if p50 := cache.UpdateLatencyP50(); p50 != time.Second {
+		t.Errorf("Expected p50 of 1s after displacement, got %v", p50)
+	}
+
+	// Updates record their duration
+	fresh := New(Config{Enabled: true, Watchlist: []common.Address{common.HexToAddress("0x1")}})
+	if err := fresh.Update(testHeader(1), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if fresh.updateLatency.count != 1 {
+		t.Errorf("Expected 1 recorded update, got %d", fresh.updateLatency.count)
+	}
+}
+
+func TestExportPoolMetrics(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	if err := New(Config{Enabled: true}).ExportPoolMetrics(metrics.NewRegistry()); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Expected ErrInvalidConfig without PoolMetrics, got %v", err)
+	}
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}, PoolMetrics: true})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	registry := metrics.NewRegistry()
+	if err := cache.ExportPoolMetrics(registry); err != nil {
+		t.Fatalf("ExportPoolMetrics failed: %v", err)
+	}
+	gauge := func(name string) float64 {
+		g, ok := registry.Get(poolMetricName(pool, name)).(*metrics.GaugeFloat64)
+		if !ok {
+			t.Fatalf("Gauge %s not registered", name)
+		}
+		return g.Snapshot().Value()
+	}
+	reader := newMockStateReader()
+	setReserves := func(reserve0, reserve1 int64) {
+		packed := new(big.Int).Or(big.NewInt(reserve0), new(big.Int).Lsh(big.NewInt(reserve1), 112))
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(packed))
+	}
+
+	setReserves(1000, 2000)
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if r0, r1, price := gauge("reserve0"), gauge("reserve1"), gauge("price"); r0 != 1000 || r1 != 2000 || price != 2 {
+		t.Errorf("Unexpected gauges reserve0 %v, reserve1 %v, price %v", r0, r1, price)
+	}
+	setReserves(1000, 4000)
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if r1, price := gauge("reserve1"), gauge("price"); r1 != 4000 || price != 4 {
+		t.Errorf("Expected gauges to follow the update, reserve1 %v, price %v", r1, price)
+	}
+
+	// Removed pools are unregistered
+	cache.RemoveFromWatchlist(pool)
+	if err := cache.Update(testHeader(3), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if registry.Get(poolMetricName(pool, "reserve0")) != nil {
+		t.Error("Expected the gauges of a removed pool to be unregistered")
+	}
+}
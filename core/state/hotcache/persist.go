@@ -0,0 +1,195 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// persistVersion is the version of the persisted cache format. Bump it on any
+// incompatible change of persistedCache; older files are then rejected and the
+// cache is rebuilt from scratch. Version 3 added the snapshot checksum,
+// version 4 extended it to contract metadata and decoded states.
+const persistVersion = 4
+
+var (
+	// ErrUnsupportedVersion is returned by LoadFrom for files written in an
+	// unknown format version.
+	ErrUnsupportedVersion = errors.New("unsupported hot cache file version")
+
+	// ErrStaleSnapshot is returned by LoadFrom if the persisted snapshot was not
+	// built for the given chain head, e.g. after a reorg during downtime.
+	ErrStaleSnapshot = errors.New("persisted hot cache snapshot does not match chain head")
+
+	// ErrChecksumMismatch is returned by LoadFrom and UnmarshalSnapshot if the
+	// decoded snapshot does not match its stored checksum, see
+	// Snapshot.Checksum.
+	ErrChecksumMismatch = errors.New("hot cache snapshot checksum mismatch")
+)
+
+// persistedCache is the gob-encoded on-disk form of the current snapshot and
+// watchlist.
+type persistedCache struct {
+	Version     uint32
+	Watchlist   []common.Address
+	BlockNumber uint64
+	BlockHash   common.Hash
+	BlockTime   uint64
+	StateRoot   common.Hash
+	Contracts   []persistedContract
+	Checksum    common.Hash // Snapshot.Checksum of the snapshot as restored
+}
+
+// persistedContract is the on-disk form of a ContractState. The decoded state
+// is stored in its JSON encoding, which is dispatched by contract type on load.
+type persistedContract struct {
+	Address       common.Address
+	Type          ContractType
+	RawSlots      map[common.Hash]common.Hash
+	Decoded       []byte
+	NativeBalance *big.Int
+	DecodeError   string
+	Suspect       bool
+	Dead          bool
+	Failures      uint64 // consecutive decode failures
+	LastUpdated   uint64
+}
+
+// SaveTo writes the current snapshot and watchlist to w.
+func (c *Cache) SaveTo(w io.Writer) error {
+	snapshot := c.GetSnapshot()
+	persisted := persistedCache{
+		Version:     persistVersion,
+		Watchlist:   c.Watchlist(),
+		BlockNumber: snapshot.BlockNumber,
+		BlockHash:   snapshot.BlockHash,
+		BlockTime:   snapshot.BlockTime,
+		StateRoot:   snapshot.StateRoot,
+		Contracts:   make([]persistedContract, 0, len(snapshot.Contracts)),
+		Checksum:    snapshot.checksum(persistedDecoded),
+	}
+	for addr, state := range snapshot.Contracts {
+		contract := persistedContract{
+			Address:       addr,
+			Type:          state.Type,
+			RawSlots:      state.rawSlots,
+			NativeBalance: state.NativeBalance,
+			Suspect:       state.Suspect,
+			Dead:          state.Dead,
+			Failures:      state.decodeFailures,
+			LastUpdated:   state.LastUpdated,
+		}
+		if state.Decoded != nil {
+			decoded, err := json.Marshal(state.Decoded)
+			if err != nil {
+				return fmt.Errorf("failed to encode %s state of %s: %w", state.Type, addr.Hex(), err)
+			}
+			contract.Decoded = decoded
+		}
+		if state.DecodeError != nil {
+			contract.DecodeError = state.DecodeError.Error()
+		}
+		persisted.Contracts = append(persisted.Contracts, contract)
+	}
+	return gob.NewEncoder(w).Encode(&persisted)
+}
+
+// persistedDecoded returns the decoded state of a contract as restored by
+// LoadFrom, i.e. nil for contract types without a known representation.
+func persistedDecoded(state *ContractState) interface{} {
+	if newDecodedState(state.Type) == nil {
+		return nil
+	}
+	return state.Decoded
+}
+
+// LoadFrom restores a snapshot and watchlist written by SaveTo. The persisted
+// watchlist is merged into the current one. The snapshot is only published if
+// it was built for the given head; otherwise ErrStaleSnapshot is returned, the
+// snapshot is discarded and the cache is rebuilt by the next Update.
+//
+// Decoded states of contract types without a known representation are dropped,
+// leaving only their raw slots.
+func (c *Cache) LoadFrom(r io.Reader, head *types.Header) error {
+	var persisted persistedCache
+	if err := gob.NewDecoder(r).Decode(&persisted); err != nil {
+		return fmt.Errorf("failed to decode hot cache: %w", err)
+	}
+	if persisted.Version != persistVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, persisted.Version)
+	}
+	for i, addr := range persisted.Watchlist {
+		if err := c.AddToWatchlist(addr); err != nil {
+			c.logger.Warn("Truncated persisted hot cache watchlist", "dropped", len(persisted.Watchlist)-i, "err", err)
+			break
+		}
+	}
+	if head == nil || head.Hash() != persisted.BlockHash {
+		return fmt.Errorf("%w: persisted block %d (%s)", ErrStaleSnapshot, persisted.BlockNumber, persisted.BlockHash.Hex())
+	}
+
+	snapshot := &Snapshot{
+		BlockNumber: persisted.BlockNumber,
+		BlockHash:   persisted.BlockHash,
+		BlockTime:   persisted.BlockTime,
+		StateRoot:   persisted.StateRoot,
+		Contracts:   make(map[common.Address]*ContractState, len(persisted.Contracts)),
+	}
+	for _, contract := range persisted.Contracts {
+		state := &ContractState{
+			Address:        contract.Address,
+			Type:           contract.Type,
+			rawSlots:       contract.RawSlots,
+			NativeBalance:  contract.NativeBalance,
+			Suspect:        contract.Suspect,
+			Dead:           contract.Dead,
+			decodeFailures: contract.Failures,
+			LastUpdated:    contract.LastUpdated,
+		}
+		if state.rawSlots == nil {
+			state.rawSlots = make(map[common.Hash]common.Hash)
+		}
+		if contract.DecodeError != "" {
+			state.DecodeError = errors.New(contract.DecodeError)
+		}
+		if len(contract.Decoded) > 0 {
+			if decoded := newDecodedState(contract.Type); decoded != nil {
+				if err := json.Unmarshal(contract.Decoded, decoded); err != nil {
+					return fmt.Errorf("failed to decode %s state of %s: %w", contract.Type, contract.Address.Hex(), err)
+				}
+				state.Decoded = decoded
+			}
+		}
+		snapshot.Contracts[contract.Address] = state
+	}
+	if checksum := snapshot.Checksum(); checksum != persisted.Checksum {
+		return fmt.Errorf("%w: stored %s, computed %s", ErrChecksumMismatch, persisted.Checksum.Hex(), checksum.Hex())
+	}
+
+	c.publishSnapshot(snapshot)
+
+	c.logger.Info("Restored hot cache snapshot", "block", snapshot.BlockNumber, "contracts", len(snapshot.Contracts))
+	return nil
+}
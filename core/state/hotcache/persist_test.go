@@ -0,0 +1,223 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	var (
+		pair    = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		raw     = common.HexToAddress("0x1")
+		broken  = common.HexToAddress("0x2")
+		runtime = common.HexToAddress("0x3")
+		slot    = common.BigToHash(big.NewInt(1))
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pair, raw, broken}})
+	cache.RegisterDecoder(pair, &UniswapV2Decoder{})
+	cache.RegisterDecoder(raw, &rawSlotDecoder{slots: []common.Hash{slot}})
+	cache.RegisterDecoder(broken, &failingDecoder{rawSlotDecoder{slots: []common.Hash{slot}}})
+	cache.AddToWatchlist(runtime)
+
+	reader := newMockStateReader()
+	reader.setState(pair, uniswapV2SlotReserves, common.BigToHash(new(big.Int).Or(big.NewInt(1000), new(big.Int).Lsh(big.NewInt(2000), 112))))
+	reader.setState(raw, slot, common.HexToHash("0x2a"))
+	head := testHeader(7)
+	head.Root = common.HexToHash("0x5eed")
+	if err := cache.Update(head, reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cache.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo failed: %v", err)
+	}
+	restored := New(Config{Enabled: true})
+	if err := restored.LoadFrom(bytes.NewReader(buf.Bytes()), head); err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+
+	if have, want := restored.Watchlist(), cache.Watchlist(); !reflect.DeepEqual(have, want) {
+		t.Errorf("Watchlist mismatch: have %v, want %v", have, want)
+	}
+	original, loaded := cache.GetSnapshot(), restored.GetSnapshot()
+	if loaded.BlockNumber != 7 || loaded.BlockHash != head.Hash() || loaded.StateRoot != head.Root {
+		t.Errorf("Unexpected restored head %d (%x)", loaded.BlockNumber, loaded.BlockHash)
+	}
+	if len(loaded.Contracts) != len(original.Contracts) {
+		t.Fatalf("Expected %d contracts, got %d", len(original.Contracts), len(loaded.Contracts))
+	}
+	for addr, want := range original.Contracts {
+		have := loaded.Contracts[addr]
+		if have == nil {
+			t.Errorf("Contract %s not restored", addr.Hex())
+			continue
+		}
+		if have.Type != want.Type || have.LastUpdated != want.LastUpdated || !reflect.DeepEqual(have.rawSlots, want.rawSlots) {
+			t.Errorf("Contract %s mismatch: have %+v, want %+v", addr.Hex(), have, want)
+		}
+		// Compare encodings, big.Int internals differ for equal values
+		haveDecoded, _ := json.Marshal(have.Decoded)
+		wantDecoded, _ := json.Marshal(want.Decoded)
+		if !bytes.Equal(haveDecoded, wantDecoded) {
+			t.Errorf("Contract %s decoded mismatch: have %s, want %s", addr.Hex(), haveDecoded, wantDecoded)
+		}
+		if (have.DecodeError == nil) != (want.DecodeError == nil) {
+			t.Errorf("Contract %s decode error mismatch: have %v, want %v", addr.Hex(), have.DecodeError, want.DecodeError)
+		}
+	}
+	if restored.GetSnapshotByHash(head.Hash()) == nil {
+		t.Error("Restored snapshot not retained for reorgs")
+	}
+}
+
+func TestLoadFromStaleHead(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	if err := cache.Update(testHeader(1), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := cache.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo failed: %v", err)
+	}
+
+	// The chain moved on (or reorged) while the node was down
+	restored := New(Config{Enabled: true})
+	err := restored.LoadFrom(&buf, testHeader(2))
+	if !errors.Is(err, ErrStaleSnapshot) {
+		t.Fatalf("Expected ErrStaleSnapshot, got %v", err)
+	}
+	if len(restored.GetSnapshot().Contracts) != 0 {
+		t.Error("Stale snapshot was published")
+	}
+	if !restored.IsWatched(addr) {
+		t.Error("Watchlist not restored from stale file")
+	}
+}
+
+func TestLoadFromChecksumMismatch(t *testing.T) {
+	pool := common.HexToAddress("0x1")
+	persisted := persistedCache{
+		Version:   persistVersion,
+		BlockHash: testHeader(1).Hash(),
+		Contracts: []persistedContract{{
+			Address:  pool,
+			RawSlots: map[common.Hash]common.Hash{{0x8}: {0x1}},
+		}},
+		Checksum: common.Hash{0xba, 0xd},
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&persisted); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	if err := cache.LoadFrom(&buf, testHeader(1)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	if cache.GetSnapshot().BlockNumber != 0 || len(cache.GetSnapshot().Contracts) != 0 {
+		t.Error("Corrupted snapshot was published")
+	}
+}
+
+// TestLoadFromCorruptedDecodedState checks that the checksum covers the
+// decoded states and flags, not only the raw slots they derive from.
+func TestLoadFromCorruptedDecodedState(t *testing.T) {
+	pair := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pair}})
+	cache.RegisterDecoder(pair, &UniswapV2Decoder{})
+	reader := newMockStateReader()
+	reader.setState(pair, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	head := testHeader(1)
+	if err := cache.Update(head, reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := cache.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo failed: %v", err)
+	}
+	var saved persistedCache
+	if err := gob.NewDecoder(&buf).Decode(&saved); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	for name, corrupt := range map[string]func(*persistedContract){
+		"decoded": func(c *persistedContract) { c.Decoded = []byte(`{"reserve0":"0x1"}`) },
+		"type":    func(c *persistedContract) { c.Type = ContractTypeSushiSwap },
+		"suspect": func(c *persistedContract) { c.Suspect = true },
+	} {
+		persisted := saved
+		persisted.Contracts = slices.Clone(saved.Contracts)
+		corrupt(&persisted.Contracts[0])
+
+		var corrupted bytes.Buffer
+		if err := gob.NewEncoder(&corrupted).Encode(&persisted); err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		restored := New(Config{Enabled: true})
+		if err := restored.LoadFrom(&corrupted, head); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Expected ErrChecksumMismatch after corrupting the %s, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadFromUnsupportedVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&persistedCache{Version: persistVersion + 1}); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	cache := New(Config{Enabled: true})
+	if err := cache.LoadFrom(&buf, testHeader(1)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+// TestLoadFromVersion2 checks that a file written before the checksum was
+// added is rejected as outdated rather than as corrupted.
+func TestLoadFromVersion2(t *testing.T) {
+	pool := common.HexToAddress("0x1")
+	persisted := persistedCache{
+		Version:   2,
+		Watchlist: []common.Address{pool},
+		BlockHash: testHeader(1).Hash(),
+		Contracts: []persistedContract{{
+			Address:  pool,
+			RawSlots: map[common.Hash]common.Hash{{0x8}: {0x1}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&persisted); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	if err := cache.LoadFrom(&buf, testHeader(1)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+	if len(cache.GetSnapshot().Contracts) != 0 {
+		t.Error("Outdated snapshot was published")
+	}
+}
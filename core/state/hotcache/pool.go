@@ -0,0 +1,92 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Snapshot lifecycle with ReuseSnapshots enabled:
+//
+//  1. Update builds a snapshot from pooled maps and publishes it. From then on
+//     it is immutable and may be read concurrently.
+//  2. The snapshot stays readable while it is retained for reorg protection,
+//     i.e. until MaxSnapshots newer snapshots have been published.
+//  3. Once evicted from the retention window, its maps are cleared and handed
+//     back to the pools, unless a retained snapshot still shares the state
+//     (incremental updates carry unchanged contract states forward).
+//
+// Readers that hold on to a snapshot or contract state for longer than the
+// retention window must copy what they need. With ReuseSnapshots disabled,
+// or with DisableReorgProtection set, evicted snapshots are left to the
+// garbage collector and may be kept forever.
+
+var (
+	slotMapPool = sync.Pool{
+		New: func() interface{} { return make(map[common.Hash]common.Hash) },
+	}
+	contractMapPool = sync.Pool{
+		New: func() interface{} { return make(map[common.Address]*ContractState) },
+	}
+)
+
+// getSlotMap returns an empty raw slot map from the pool.
+func getSlotMap() map[common.Hash]common.Hash {
+	return slotMapPool.Get().(map[common.Hash]common.Hash)
+}
+
+// getContractMap returns an empty contract map from the pool.
+func getContractMap() map[common.Address]*ContractState {
+	return contractMapPool.Get().(map[common.Address]*ContractState)
+}
+
+// recycleSnapshot returns the maps of an evicted snapshot to the pools. Contract
+// states still referenced by a retained snapshot are left untouched.
+// Must be called with snapshotMu held, after the snapshot was removed from the
+// retention window.
+func (c *Cache) recycleSnapshot(snapshot *Snapshot) {
+	if !c.config.ReuseSnapshots || snapshot == c.current.Load() {
+		return
+	}
+	for addr, state := range snapshot.Contracts {
+		if c.isRetainedState(addr, state) {
+			continue
+		}
+		clear(state.rawSlots)
+		slotMapPool.Put(state.rawSlots)
+		state.rawSlots = nil
+	}
+	clear(snapshot.Contracts)
+	contractMapPool.Put(snapshot.Contracts)
+	snapshot.Contracts = nil
+}
+
+// isRetainedState reports whether the given contract state is still shared by
+// a retained or the currently published snapshot.
+// Must be called with snapshotMu held.
+func (c *Cache) isRetainedState(addr common.Address, state *ContractState) bool {
+	if c.current.Load().Contracts[addr] == state {
+		return true
+	}
+	retained := false
+	c.snapshots.forEach(func(snapshot *Snapshot) {
+		retained = retained || snapshot.Contracts[addr] == state
+	})
+	return retained
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PriceFeedDecimals is the number of decimals of the fixed point prices
+// returned by PriceFeed, i.e. prices are scaled by 1e18.
+const PriceFeedDecimals = 18
+
+var (
+	// ErrNoPool is returned by PriceFeed.Price if no cached pool trades the
+	// requested pair.
+	ErrNoPool = errors.New("no cached pool for pair")
+
+	// ErrUnknownDecimals is returned by PriceFeed.Price if the decimals of a
+	// token of the selected pool are not registered, see SetTokenDecimals.
+	ErrUnknownDecimals = errors.New("token decimals not registered")
+)
+
+// PriceFeed serves prices from the pools of a Cache through the interface
+// expected by oracle consumers.
+type PriceFeed struct {
+	cache *Cache
+}
+
+// NewPriceFeed returns a price feed reading from the given cache.
+func NewPriceFeed(cache *Cache) *PriceFeed {
+	return &PriceFeed{cache: cache}
+}
+
+// Price returns the price of one whole base token in whole quote tokens as a
+// fixed point number with PriceFeedDecimals decimals, rounded down. It is
+// read from the cached Uniswap V2 (or fork) pool of the pair holding the
+// largest quote reserve, ties resolved towards the lower pool address; pools
+// with an empty reserve are skipped. ErrNoPool is returned if no cached pool
+// covers the pair, and ErrUnknownDecimals if the decimals of the pair are not
+// registered.
+func (f *PriceFeed) Price(base, quote common.Address) (*big.Int, error) {
+	var (
+		pool                      common.Address
+		best                      *UniswapV2State
+		baseReserve, quoteReserve *big.Int
+	)
+	for addr, state := range f.cache.GetSnapshot().Contracts {
+		v2, ok := state.Decoded.(*UniswapV2State)
+		if !ok || state.DecodeError != nil || v2.Reserve0.Sign() == 0 || v2.Reserve1.Sign() == 0 {
+			continue
+		}
+		var reserveBase, reserveQuote *big.Int
+		switch {
+		case v2.Token0 == base && v2.Token1 == quote:
+			reserveBase, reserveQuote = v2.Reserve0, v2.Reserve1
+		case v2.Token0 == quote && v2.Token1 == base:
+			reserveBase, reserveQuote = v2.Reserve1, v2.Reserve0
+		default:
+			continue
+		}
+		if best != nil {
+			if n := reserveQuote.Cmp(quoteReserve); n < 0 || (n == 0 && addr.Cmp(pool) > 0) {
+				continue
+			}
+		}
+		pool, best, baseReserve, quoteReserve = addr, v2, reserveBase, reserveQuote
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: %s/%s", ErrNoPool, base.Hex(), quote.Hex())
+	}
+	if best.Decimals0 == nil || best.Decimals1 == nil {
+		return nil, fmt.Errorf("%w: pool %s", ErrUnknownDecimals, pool.Hex())
+	}
+	baseDecimals, quoteDecimals := *best.Decimals0, *best.Decimals1
+	if best.Token0 == quote {
+		baseDecimals, quoteDecimals = quoteDecimals, baseDecimals
+	}
+	// price = quoteReserve/10^quoteDecimals / (baseReserve/10^baseDecimals),
+	// scaled by 10^PriceFeedDecimals
+	price := new(big.Int).Mul(quoteReserve, pow10(uint64(baseDecimals)+PriceFeedDecimals))
+	denominator := new(big.Int).Mul(baseReserve, pow10(uint64(quoteDecimals)))
+	return price.Quo(price, denominator), nil
+}
+
+// pow10 returns 10^n.
+func pow10(n uint64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), new(big.Int).SetUint64(n), nil)
+}
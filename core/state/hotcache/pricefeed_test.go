@@ -0,0 +1,85 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPriceFeed(t *testing.T) {
+	var (
+		usdc    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		weth    = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+		dai     = common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+		wbtc    = common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599")
+		shallow = common.HexToAddress("0x1")
+		deep    = common.HexToAddress("0x2")
+		other   = common.HexToAddress("0x3")
+	)
+	pools := []common.Address{shallow, deep, other}
+	cache := New(Config{Enabled: true, Watchlist: pools})
+	for _, pool := range pools {
+		cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	}
+	cache.SetTokenDecimals(usdc, 6)
+	cache.SetTokenDecimals(weth, 18)
+
+	reader := newMockStateReader()
+	setPool := func(pool, token0, token1 common.Address, reserve0, reserve1 *big.Int) {
+		reader.setState(pool, uniswapV2SlotToken0, common.BytesToHash(token0.Bytes()))
+		reader.setState(pool, uniswapV2SlotToken1, common.BytesToHash(token1.Bytes()))
+		packed := new(big.Int).Or(reserve0, new(big.Int).Lsh(reserve1, 112))
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(packed))
+	}
+	ether := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18)) }
+
+	// 2000 USDC/WETH in the deep pool, a stale 1000 in the shallow one
+	setPool(deep, usdc, weth, big.NewInt(2_000_000e6), ether(1000))
+	setPool(shallow, usdc, weth, big.NewInt(1_000e6), ether(1))
+	setPool(other, usdc, dai, big.NewInt(1_000e6), ether(1000))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	feed := NewPriceFeed(cache)
+
+	price, err := feed.Price(weth, usdc)
+	if err != nil {
+		t.Fatalf("Failed to get WETH price: %v", err)
+	}
+	if want := ether(2000); price.Cmp(want) != 0 {
+		t.Errorf("Unexpected WETH price: have %s, want %s", price, want)
+	}
+	price, err = feed.Price(usdc, weth)
+	if err != nil {
+		t.Fatalf("Failed to get USDC price: %v", err)
+	}
+	if want := big.NewInt(5e14); price.Cmp(want) != 0 { // 0.0005 WETH
+		t.Errorf("Unexpected USDC price: have %s, want %s", price, want)
+	}
+
+	// Uncovered pairs and pairs without registered decimals fail
+	if _, err := feed.Price(wbtc, usdc); !errors.Is(err, ErrNoPool) {
+		t.Errorf("Expected ErrNoPool, got %v", err)
+	}
+	if _, err := feed.Price(dai, usdc); !errors.Is(err, ErrUnknownDecimals) {
+		t.Errorf("Expected ErrUnknownDecimals, got %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Marshal encodes the snapshot in the Protobuf format defined in
+// snapshot.proto, for sharing snapshots with co-located processes. Contracts
+// and raw slots are ordered, so equal snapshots encode identically. Only the
+// decoded state of Uniswap V2 (and fork) pools is encoded; other contracts
+// are restored with their raw slots only, and the checksum covers the snapshot
+// as it is restored.
+func (s *Snapshot) Marshal() ([]byte, error) {
+	b := protowire.AppendTag(nil, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, s.BlockNumber)
+	b = appendBytesField(b, 2, s.BlockHash[:])
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, s.BlockTime)
+	b = appendBytesField(b, 5, s.StateRoot[:])
+	checksum := s.checksum(protobufDecoded)
+	b = appendBytesField(b, 6, checksum[:])
+
+	addrs := make([]common.Address, 0, len(s.Contracts))
+	for addr := range s.Contracts {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, func(a, b common.Address) int { return a.Cmp(b) })
+	for _, addr := range addrs {
+		b = appendBytesField(b, 4, marshalContractState(s.Contracts[addr]))
+	}
+	return b, nil
+}
+
+// protobufDecoded returns the decoded state of a contract as restored by
+// UnmarshalSnapshot, i.e. nil unless it is a Uniswap V2 state.
+func protobufDecoded(state *ContractState) interface{} {
+	if v2, ok := state.Decoded.(*UniswapV2State); ok {
+		return v2
+	}
+	return nil
+}
+
+// UnmarshalSnapshot decodes a snapshot encoded by Snapshot.Marshal. Unknown
+// fields are skipped. ErrChecksumMismatch is returned if the encoding carries
+// no checksum or the decoded snapshot does not match it.
+func UnmarshalSnapshot(input []byte) (*Snapshot, error) {
+	var (
+		snapshot = &Snapshot{Contracts: make(map[common.Address]*ContractState)}
+		checksum *common.Hash
+	)
+	err := parseMessage(input, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			snapshot.BlockNumber = v
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			return n, setFixedBytes(snapshot.BlockHash[:], v, n, "block hash")
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			snapshot.BlockTime = v
+			return n, nil
+		case num == 4 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			state, err := unmarshalContractState(v)
+			if err != nil {
+				return 0, err
+			}
+			snapshot.Contracts[state.Address] = state
+			return n, nil
+		case num == 5 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			return n, setFixedBytes(snapshot.StateRoot[:], v, n, "state root")
+		case num == 6 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			checksum = new(common.Hash)
+			return n, setFixedBytes(checksum[:], v, n, "checksum")
+		}
+		return 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if checksum == nil {
+		return nil, fmt.Errorf("%w: no checksum stored", ErrChecksumMismatch)
+	}
+	if computed := snapshot.Checksum(); computed != *checksum {
+		return nil, fmt.Errorf("%w: stored %s, computed %s", ErrChecksumMismatch, checksum.Hex(), computed.Hex())
+	}
+	return snapshot, nil
+}
+
+// marshalContractState encodes a ContractState message.
+func marshalContractState(state *ContractState) []byte {
+	b := appendBytesField(nil, 1, state.Address[:])
+	b = appendBytesField(b, 2, []byte(state.Type.String()))
+
+	slots := make([]common.Hash, 0, len(state.rawSlots))
+	for slot := range state.rawSlots {
+		slots = append(slots, slot)
+	}
+	slices.SortFunc(slots, func(a, b common.Hash) int { return a.Cmp(b) })
+	for _, slot := range slots {
+		value := state.rawSlots[slot]
+		entry := appendBytesField(nil, 1, slot[:])
+		entry = appendBytesField(entry, 2, value[:])
+		b = appendBytesField(b, 3, entry)
+	}
+	if state.DecodeError != nil {
+		b = appendBytesField(b, 4, []byte(state.DecodeError.Error()))
+	}
+	b = appendBoolField(b, 5, state.Suspect)
+	b = appendBoolField(b, 6, state.Dead)
+	if state.LastUpdated != 0 {
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, state.LastUpdated)
+	}
+	if v2, ok := state.Decoded.(*UniswapV2State); ok {
+		b = appendBytesField(b, 8, marshalUniswapV2State(v2))
+	}
+	b = appendBigField(b, 9, state.NativeBalance)
+	return b
+}
+
+// unmarshalContractState decodes a ContractState message.
+func unmarshalContractState(input []byte) (*ContractState, error) {
+	state := &ContractState{rawSlots: make(map[common.Hash]common.Hash)}
+	err := parseMessage(input, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			switch num {
+			case 5:
+				state.Suspect = v != 0
+			case 6:
+				state.Dead = v != 0
+			case 7:
+				state.LastUpdated = v
+			default:
+				return 0, nil
+			}
+			return n, nil
+		}
+		if typ != protowire.BytesType {
+			return 0, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+		switch num {
+		case 1:
+			return n, setFixedBytes(state.Address[:], v, n, "address")
+		case 2:
+			return n, state.Type.UnmarshalText(v)
+		case 3:
+			var slot, value common.Hash
+			err := parseMessage(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				if typ != protowire.BytesType || (num != 1 && num != 2) {
+					return 0, nil
+				}
+				v, n := protowire.ConsumeBytes(b)
+				if num == 1 {
+					return n, setFixedBytes(slot[:], v, n, "slot")
+				}
+				return n, setFixedBytes(value[:], v, n, "slot value")
+			})
+			if err != nil {
+				return 0, err
+			}
+			state.rawSlots[slot] = value
+		case 4:
+			state.DecodeError = errors.New(string(v))
+		case 8:
+			v2, err := unmarshalUniswapV2State(v)
+			if err != nil {
+				return 0, err
+			}
+			state.Decoded = v2
+		case 9:
+			state.NativeBalance = new(big.Int).SetBytes(v)
+		default:
+			return 0, nil
+		}
+		return n, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// marshalUniswapV2State encodes a UniswapV2State message.
+func marshalUniswapV2State(s *UniswapV2State) []byte {
+	b := appendBytesField(nil, 1, s.Token0[:])
+	b = appendBytesField(b, 2, s.Token1[:])
+	b = appendBigField(b, 3, s.Reserve0)
+	b = appendBigField(b, 4, s.Reserve1)
+	if s.BlockTimestampLast != 0 {
+		b = protowire.AppendTag(b, 5, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(s.BlockTimestampLast))
+	}
+	b = appendBigField(b, 6, s.Price0Cumulative)
+	b = appendBigField(b, 7, s.Price1Cumulative)
+	b = appendBigField(b, 8, s.KLast)
+	if s.FeeBps != 0 {
+		b = protowire.AppendTag(b, 9, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(s.FeeBps))
+	}
+	if s.Decimals0 != nil {
+		b = protowire.AppendTag(b, 10, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*s.Decimals0))
+	}
+	if s.Decimals1 != nil {
+		b = protowire.AppendTag(b, 11, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*s.Decimals1))
+	}
+	if s.OptionalSlotsMissing {
+		b = protowire.AppendTag(b, 12, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+// unmarshalUniswapV2State decodes a UniswapV2State message.
+func unmarshalUniswapV2State(input []byte) (*UniswapV2State, error) {
+	s := new(UniswapV2State)
+	err := parseMessage(input, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			switch num {
+			case 5:
+				s.BlockTimestampLast = uint32(v)
+			case 9:
+				s.FeeBps = uint16(v)
+			case 10:
+				decimals := uint8(v)
+				s.Decimals0 = &decimals
+			case 11:
+				decimals := uint8(v)
+				s.Decimals1 = &decimals
+			case 12:
+				s.OptionalSlotsMissing = v != 0
+			default:
+				return 0, nil
+			}
+			return n, nil
+		}
+		if typ != protowire.BytesType {
+			return 0, nil
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+		switch num {
+		case 1:
+			return n, setFixedBytes(s.Token0[:], v, n, "token0")
+		case 2:
+			return n, setFixedBytes(s.Token1[:], v, n, "token1")
+		case 3:
+			s.Reserve0 = new(big.Int).SetBytes(v)
+		case 4:
+			s.Reserve1 = new(big.Int).SetBytes(v)
+		case 6:
+			s.Price0Cumulative = new(big.Int).SetBytes(v)
+		case 7:
+			s.Price1Cumulative = new(big.Int).SetBytes(v)
+		case 8:
+			s.KLast = new(big.Int).SetBytes(v)
+		default:
+			return 0, nil
+		}
+		return n, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseMessage calls field for every field of an encoded message, with the
+// input starting at the field's value. field returns the length of the value
+// it consumed, zero to skip an unknown field, or a negative protowire error
+// code. Field values must not be empty, which holds for varints and
+// length-prefixed values.
+func parseMessage(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("invalid snapshot encoding: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid snapshot encoding: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// appendBytesField appends a length-delimited field.
+func appendBytesField(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// appendBoolField appends a boolean field if it is set.
+func appendBoolField(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+// appendBigField appends an optional big integer field as unsigned big-endian
+// bytes, omitting nil values.
+func appendBigField(b []byte, num protowire.Number, v *big.Int) []byte {
+	if v == nil {
+		return b
+	}
+	return appendBytesField(b, num, v.Bytes())
+}
+
+// setFixedBytes copies a decoded fixed-size value into dst. It does nothing if
+// the value failed to decode (n < 0), leaving the error to the caller.
+func setFixedBytes(dst, v []byte, n int, name string) error {
+	if n < 0 {
+		return nil
+	}
+	if len(v) != len(dst) {
+		return fmt.Errorf("invalid snapshot encoding: %s of %d bytes", name, len(v))
+	}
+	copy(dst, v)
+	return nil
+}
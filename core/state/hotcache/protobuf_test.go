@@ -0,0 +1,163 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSnapshotProtobufRoundTrip(t *testing.T) {
+	var (
+		pool     = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		decimals = uint8(6)
+	)
+	reserve0, _ := new(big.Int).SetString("123456789012345678901234", 10)
+	snapshot := &Snapshot{
+		BlockNumber: 42,
+		BlockHash:   common.HexToHash("0xabcd"),
+		BlockTime:   1700000000,
+		StateRoot:   common.HexToHash("0x5eed"),
+		Contracts: map[common.Address]*ContractState{
+			pool: {
+				Address: pool,
+				Type:    ContractTypeUniswapV2,
+				rawSlots: map[common.Hash]common.Hash{
+					uniswapV2SlotReserves: common.HexToHash("0x01"),
+					uniswapV2SlotKLast:    common.HexToHash("0x02"),
+				},
+				Decoded: &UniswapV2State{
+					Token0:             token,
+					Token1:             common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+					Reserve0:           reserve0,
+					Reserve1:           big.NewInt(500),
+					BlockTimestampLast: 1234567890,
+					Price0Cumulative:   big.NewInt(1),
+					Price1Cumulative:   new(big.Int),
+					KLast:              big.NewInt(3),
+					FeeBps:             25,
+					Decimals0:          &decimals,
+
+					OptionalSlotsMissing: true,
+				},
+				LastUpdated: 42,
+			},
+			token: {
+				Address:       token,
+				Type:          ContractTypeUnknown,
+				rawSlots:      map[common.Hash]common.Hash{{}: common.HexToHash("0x05")},
+				DecodeError:   errors.New("no decoder"),
+				Suspect:       true,
+				Dead:          true,
+				LastUpdated:   41,
+				NativeBalance: big.NewInt(7),
+			},
+		},
+	}
+	blob, err := snapshot.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	restored, err := UnmarshalSnapshot(blob)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %v", err)
+	}
+	if !restored.Equal(snapshot) {
+		t.Fatalf("Restored snapshot differs: have %+v, want %+v", restored, snapshot)
+	}
+	if state := restored.Contracts[pool].Decoded.(*UniswapV2State); !state.OptionalSlotsMissing {
+		t.Error("Expected OptionalSlotsMissing to survive the round trip")
+	}
+	if err := restored.Contracts[token].DecodeError; err == nil || err.Error() != "no decoder" {
+		t.Errorf("Unexpected decode error: %v", err)
+	}
+	// Encoding is deterministic regardless of map order
+	again, _ := restored.Marshal()
+	if string(again) != string(blob) {
+		t.Error("Re-encoding the restored snapshot changed the output")
+	}
+
+	// The binary form must be considerably smaller than JSON
+	jsonBlob, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot to JSON: %v", err)
+	}
+	if len(blob)*2 > len(jsonBlob) {
+		t.Errorf("Protobuf encoding not smaller than half of JSON: have %d bytes, JSON %d bytes", len(blob), len(jsonBlob))
+	}
+}
+
+// TestSnapshotProtobufDropsDecoded checks that decoded states without a
+// Protobuf representation are left out of the checksum, so that the snapshot
+// restored without them still verifies.
+func TestSnapshotProtobufDropsDecoded(t *testing.T) {
+	token := common.HexToAddress("0x1")
+	snapshot := &Snapshot{
+		BlockNumber: 1,
+		Contracts: map[common.Address]*ContractState{
+			token: {
+				Address:  token,
+				Type:     ContractTypeERC20,
+				rawSlots: map[common.Hash]common.Hash{{0x2}: {0x1}},
+				Decoded:  &ERC20State{TotalSupply: big.NewInt(1)},
+			},
+		},
+	}
+	blob, err := snapshot.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	restored, err := UnmarshalSnapshot(blob)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %v", err)
+	}
+	if restored.Contracts[token].Decoded != nil {
+		t.Error("Expected the ERC20 state to be dropped")
+	}
+}
+
+func TestUnmarshalSnapshotInvalid(t *testing.T) {
+	blob, err := (&Snapshot{BlockNumber: 1, BlockHash: common.HexToHash("0x01")}).Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	if _, err := UnmarshalSnapshot(blob[:len(blob)-1]); err == nil {
+		t.Error("Expected error for truncated input")
+	}
+	// A block hash of the wrong length
+	if _, err := UnmarshalSnapshot([]byte{0x12, 0x01, 0x00}); err == nil {
+		t.Error("Expected error for short block hash")
+	}
+	// Unknown fields are skipped
+	if _, err := UnmarshalSnapshot(append(blob, 0xf8, 0x01, 0x01)); err != nil {
+		t.Errorf("Unexpected error for unknown field: %v", err)
+	}
+	// A checksum not matching the contents, the last occurrence wins
+	corrupt := appendBytesField(blob, 6, make([]byte, common.HashLength))
+	if _, err := UnmarshalSnapshot(corrupt); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	// Encodings without a checksum are rejected
+	if _, err := UnmarshalSnapshot([]byte{0x08, 0x01}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch for missing checksum, got %v", err)
+	}
+}
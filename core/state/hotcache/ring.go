@@ -0,0 +1,114 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import "github.com/ethereum/go-ethereum/common"
+
+// snapshotRing retains the most recently published snapshots in a fixed-size
+// ring buffer, evicting the oldest one once full. A hash index over the ring
+// contents serves reorg lookups. Insertion and eviction are O(1).
+//
+// A snapshot may be retained for several blocks whose watched state did not
+// change (see Config.PublishOnlyOnChange); it is only displaced once none of
+// its blocks is retained anymore.
+//
+// A nil ring retains nothing; see Config.DisableReorgProtection.
+//
+// The ring is not safe for concurrent use, the cache guards it with snapshotMu.
+type snapshotRing struct {
+	items  []*Snapshot         // circular buffer, nil for unused positions
+	hashes []common.Hash       // block hash retained at each position
+	next   int                 // position of the next insertion
+	index  map[common.Hash]int // block hash -> position in items
+	refs   map[*Snapshot]int   // number of positions holding each snapshot
+}
+
+// newSnapshotRing creates a ring retaining up to size snapshots.
+func newSnapshotRing(size int) *snapshotRing {
+	size = max(size, 1)
+	return &snapshotRing{
+		items:  make([]*Snapshot, size),
+		hashes: make([]common.Hash, size),
+		index:  make(map[common.Hash]int, size),
+		refs:   make(map[*Snapshot]int, size),
+	}
+}
+
+// add retains a snapshot for the block it was built from; see addAt.
+func (r *snapshotRing) add(snapshot *Snapshot) *Snapshot {
+	return r.addAt(snapshot.BlockHash, snapshot)
+}
+
+// addAt retains a snapshot as the state at the given block hash and returns
+// the snapshot it displaced, if that is no longer retained for any block. The
+// displaced snapshot is the oldest one when the ring is full, or the previous
+// snapshot of the same block, which is replaced in place.
+func (r *snapshotRing) addAt(hash common.Hash, snapshot *Snapshot) *Snapshot {
+	pos, ok := r.index[hash]
+	if !ok {
+		pos = r.next
+		r.next = (r.next + 1) % len(r.items)
+		if r.items[pos] != nil {
+			delete(r.index, r.hashes[pos])
+		}
+	}
+	displaced := r.items[pos]
+	r.items[pos], r.hashes[pos] = snapshot, hash
+	r.index[hash] = pos
+	r.refs[snapshot]++
+
+	if displaced == nil {
+		return nil
+	}
+	if r.refs[displaced]--; r.refs[displaced] > 0 {
+		return nil
+	}
+	delete(r.refs, displaced)
+	return displaced
+}
+
+// get returns the snapshot retained for the given block hash, or nil.
+func (r *snapshotRing) get(hash common.Hash) *Snapshot {
+	if r == nil {
+		return nil
+	}
+	if pos, ok := r.index[hash]; ok {
+		return r.items[pos]
+	}
+	return nil
+}
+
+// len returns the number of blocks with a retained snapshot.
+func (r *snapshotRing) len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.index)
+}
+
+// forEach calls fn for every retained block's snapshot, oldest first. A
+// snapshot retained for several blocks is visited once per block.
+func (r *snapshotRing) forEach(fn func(*Snapshot)) {
+	if r == nil {
+		return
+	}
+	for i := range r.items {
+		if snapshot := r.items[(r.next+i)%len(r.items)]; snapshot != nil {
+			fn(snapshot)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSnapshotRing(t *testing.T) {
+	ring := newSnapshotRing(3)
+	snapshots := make([]*Snapshot, 5)
+	for i := range snapshots {
+		snapshots[i] = &Snapshot{BlockNumber: uint64(i), BlockHash: common.Hash{byte(i + 1)}}
+	}
+	for i := 0; i < 3; i++ {
+		if evicted := ring.add(snapshots[i]); evicted != nil {
+			t.Fatalf("snapshot %d: unexpected eviction of block %d", i, evicted.BlockNumber)
+		}
+	}
+	// Once full, the oldest snapshot is evicted first
+	if evicted := ring.add(snapshots[3]); evicted != snapshots[0] {
+		t.Fatalf("expected block 0 evicted, got %v", evicted)
+	}
+	if ring.get(snapshots[0].BlockHash) != nil {
+		t.Error("evicted snapshot still indexed")
+	}
+	// Re-publishing a block replaces its snapshot in place
+	replacement := &Snapshot{BlockNumber: 2, BlockHash: snapshots[2].BlockHash}
+	if evicted := ring.add(replacement); evicted != snapshots[2] {
+		t.Fatalf("expected previous block 2 snapshot displaced, got %v", evicted)
+	}
+	if ring.get(snapshots[2].BlockHash) != replacement {
+		t.Error("replacement not indexed")
+	}
+	if evicted := ring.add(replacement); evicted != nil {
+		t.Errorf("re-adding the same snapshot displaced %v", evicted)
+	}
+	if evicted := ring.add(snapshots[4]); evicted != snapshots[1] {
+		t.Fatalf("expected block 1 evicted, got %v", evicted)
+	}
+	var numbers []uint64
+	ring.forEach(func(s *Snapshot) { numbers = append(numbers, s.BlockNumber) })
+	if ring.len() != 3 || len(numbers) != 3 || numbers[0] != 2 || numbers[1] != 3 || numbers[2] != 4 {
+		t.Errorf("unexpected retained snapshots: %v", numbers)
+	}
+}
+
+func TestSnapshotRingAliases(t *testing.T) {
+	ring := newSnapshotRing(2)
+	first := &Snapshot{BlockNumber: 1, BlockHash: common.Hash{1}}
+	ring.add(first)
+	// Retain the same snapshot for an unchanged second block
+	if evicted := ring.addAt(common.Hash{2}, first); evicted != nil {
+		t.Fatalf("Unexpected eviction of block %d", evicted.BlockNumber)
+	}
+	if ring.get(common.Hash{2}) != first || ring.len() != 2 {
+		t.Fatal("Expected snapshot retained for both blocks")
+	}
+	// Evicting block 1 keeps the snapshot, which is still retained for block 2
+	third := &Snapshot{BlockNumber: 3, BlockHash: common.Hash{3}}
+	if evicted := ring.add(third); evicted != nil {
+		t.Fatalf("Unexpected eviction of still retained block %d", evicted.BlockNumber)
+	}
+	if ring.get(common.Hash{1}) != nil {
+		t.Error("Block 1 still indexed")
+	}
+	// Evicting block 2 releases it
+	if evicted := ring.add(&Snapshot{BlockNumber: 4, BlockHash: common.Hash{4}}); evicted != first {
+		t.Fatalf("Expected first snapshot evicted, got %v", evicted)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrInvalidRoute is returned by QuoteRoute if the pools of a path do not form
+// a connected route of cached Uniswap V2 (or fork) pools.
+var ErrInvalidRoute = errors.New("invalid hot cache route")
+
+// BestPool returns the cached Uniswap V2 (or fork) pool trading tokenA for
+// tokenB that yields the largest output for amountIn, along with that output.
+// Pools are matched regardless of their token0/token1 order. If no cached pool
+// for the pair yields any output, the zero address and a zero amount are
+// returned. Ties are resolved towards the lower pool address.
+func (c *Cache) BestPool(tokenA, tokenB common.Address, amountIn *big.Int) (pool common.Address, amountOut *big.Int) {
+	amountOut = new(big.Int)
+	for addr, state := range c.GetSnapshot().Contracts {
+		v2, ok := state.Decoded.(*UniswapV2State)
+		if !ok || state.DecodeError != nil {
+			continue
+		}
+		var zeroForOne bool
+		switch {
+		case v2.Token0 == tokenA && v2.Token1 == tokenB:
+			zeroForOne = true
+		case v2.Token0 == tokenB && v2.Token1 == tokenA:
+			zeroForOne = false
+		default:
+			continue
+		}
+		out := v2.GetAmountOut(amountIn, zeroForOne)
+		if out.Sign() == 0 {
+			continue
+		}
+		if n := out.Cmp(amountOut); n > 0 || (n == 0 && addr.Cmp(pool) < 0) {
+			pool, amountOut = addr, out
+		}
+	}
+	return pool, amountOut
+}
+
+// QuoteRoute returns the output of swapping amountIn along path, a list of
+// cached Uniswap V2 (or fork) pools, by chaining GetAmountOut through them.
+// The direction of each hop is inferred from the token shared with the next
+// pool: the first pool is entered with its other token, and every following
+// pool with the token received from the previous one. All pools are read from
+// the same snapshot. ErrInvalidRoute is returned if the path has fewer than two
+// pools, its first two pools share both tokens, or consecutive pools do not
+// connect.
+func (c *Cache) QuoteRoute(path []common.Address, amountIn *big.Int) (*big.Int, error) {
+	if len(path) < 2 {
+		return nil, fmt.Errorf("%w: need at least two pools to infer the direction, have %d", ErrInvalidRoute, len(path))
+	}
+	snapshot := c.GetSnapshot()
+	pools := make([]*UniswapV2State, len(path))
+	for i, addr := range path {
+		state, ok := snapshot.Contracts[addr]
+		if !ok {
+			return nil, fmt.Errorf("%w: pool %s", ErrNotFound, addr.Hex())
+		}
+		v2, ok := state.Decoded.(*UniswapV2State)
+		if !ok || state.DecodeError != nil {
+			return nil, fmt.Errorf("%w: %s is not a decoded Uniswap V2 pool", ErrInvalidRoute, addr.Hex())
+		}
+		pools[i] = v2
+	}
+	// Enter the first pool with the token it does not share with the second
+	first, second := pools[0], pools[1]
+	shares0 := first.Token0 == second.Token0 || first.Token0 == second.Token1
+	shares1 := first.Token1 == second.Token0 || first.Token1 == second.Token1
+	var tokenIn common.Address
+	switch {
+	case shares0 && shares1:
+		return nil, fmt.Errorf("%w: %s and %s trade the same pair", ErrInvalidRoute, path[0].Hex(), path[1].Hex())
+	case shares1:
+		tokenIn = first.Token0
+	case shares0:
+		tokenIn = first.Token1
+	default:
+		return nil, fmt.Errorf("%w: %s does not connect to %s", ErrInvalidRoute, path[0].Hex(), path[1].Hex())
+	}
+	amount := amountIn
+	for i, pool := range pools {
+		var zeroForOne bool
+		switch tokenIn {
+		case pool.Token0:
+			zeroForOne, tokenIn = true, pool.Token1
+		case pool.Token1:
+			zeroForOne, tokenIn = false, pool.Token0
+		default:
+			return nil, fmt.Errorf("%w: %s does not trade %s", ErrInvalidRoute, path[i].Hex(), tokenIn.Hex())
+		}
+		amount = pool.GetAmountOut(amount, zeroForOne)
+	}
+	return amount, nil
+}
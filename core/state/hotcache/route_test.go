@@ -0,0 +1,154 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestBestPool(t *testing.T) {
+	var (
+		usdc    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		weth    = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+		dai     = common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+		shallow = common.HexToAddress("0x1")
+		deep    = common.HexToAddress("0x2")
+		flipped = common.HexToAddress("0x3")
+		other   = common.HexToAddress("0x4")
+	)
+	pools := []common.Address{shallow, deep, flipped, other}
+	cache := New(Config{Enabled: true, Watchlist: pools})
+	for _, pool := range pools {
+		cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	}
+
+	// Same USDC/WETH price in every pool, at different depths. The flipped
+	// pool lists WETH as token0.
+	reader := newMockStateReader()
+	setPool := func(pool, token0, token1 common.Address, reserve0, reserve1 int64) {
+		reader.setState(pool, uniswapV2SlotToken0, common.BytesToHash(token0.Bytes()))
+		reader.setState(pool, uniswapV2SlotToken1, common.BytesToHash(token1.Bytes()))
+		packed := new(big.Int).Or(big.NewInt(reserve0), new(big.Int).Lsh(big.NewInt(reserve1), 112))
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(packed))
+	}
+	setPool(shallow, usdc, weth, 2_000_000, 1_000)
+	setPool(deep, usdc, weth, 200_000_000, 100_000)
+	setPool(flipped, weth, usdc, 10_000, 20_000_000)
+	setPool(other, usdc, dai, 1_000_000_000, 1_000_000_000)
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	amountIn := big.NewInt(1_000_000)
+	for _, tt := range []struct {
+		tokenIn, tokenOut common.Address
+	}{{usdc, weth}, {weth, usdc}} {
+		if tt.tokenIn == weth {
+			amountIn = big.NewInt(500)
+		}
+		pool, amountOut := cache.BestPool(tt.tokenIn, tt.tokenOut, amountIn)
+		if pool != deep {
+			t.Errorf("%s->%s: expected the deepest pool %s, got %s", tt.tokenIn.Hex(), tt.tokenOut.Hex(), deep.Hex(), pool.Hex())
+		}
+		state, _ := cache.GetContractState(deep)
+		want := state.Decoded.(*UniswapV2State).GetAmountOut(amountIn, tt.tokenIn == usdc)
+		if amountOut.Cmp(want) != 0 {
+			t.Errorf("%s->%s: expected output %s, got %s", tt.tokenIn.Hex(), tt.tokenOut.Hex(), want, amountOut)
+		}
+	}
+
+	if pool, amountOut := cache.BestPool(weth, dai, amountIn); pool != (common.Address{}) || amountOut.Sign() != 0 {
+		t.Errorf("Expected no pool for an uncached pair, got %s with %s", pool.Hex(), amountOut)
+	}
+}
+
+func TestQuoteRoute(t *testing.T) {
+	var (
+		usdc     = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		weth     = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+		dai      = common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+		wbtc     = common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599")
+		usdcWeth = common.HexToAddress("0x1")
+		daiWeth  = common.HexToAddress("0x2")
+		daiWbtc  = common.HexToAddress("0x3")
+		usdcDai  = common.HexToAddress("0x4")
+	)
+	pools := []common.Address{usdcWeth, daiWeth, daiWbtc, usdcDai}
+	cache := New(Config{Enabled: true, Watchlist: pools})
+	for _, pool := range pools {
+		cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	}
+	reader := newMockStateReader()
+	setPool := func(pool, token0, token1 common.Address, reserve0, reserve1 int64) {
+		reader.setState(pool, uniswapV2SlotToken0, common.BytesToHash(token0.Bytes()))
+		reader.setState(pool, uniswapV2SlotToken1, common.BytesToHash(token1.Bytes()))
+		packed := new(big.Int).Or(big.NewInt(reserve0), new(big.Int).Lsh(big.NewInt(reserve1), 112))
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(packed))
+	}
+	setPool(usdcWeth, usdc, weth, 200_000_000, 100_000)
+	setPool(daiWeth, dai, weth, 300_000_000, 100_000)
+	setPool(daiWbtc, dai, wbtc, 500_000_000, 10_000)
+	setPool(usdcDai, usdc, dai, 1_000_000_000, 1_000_000_000)
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	v2 := func(pool common.Address) *UniswapV2State {
+		state, _ := cache.GetContractState(pool)
+		return state.Decoded.(*UniswapV2State)
+	}
+	amountIn := big.NewInt(1_000_000)
+
+	// USDC -> WETH -> DAI, entering the second pool through its token1
+	got, err := cache.QuoteRoute([]common.Address{usdcWeth, daiWeth}, amountIn)
+	if err != nil {
+		t.Fatalf("QuoteRoute failed: %v", err)
+	}
+	want := v2(daiWeth).GetAmountOut(v2(usdcWeth).GetAmountOut(amountIn, true), false)
+	if got.Cmp(want) != 0 {
+		t.Errorf("USDC->WETH->DAI: expected %s, got %s", want, got)
+	}
+	// DAI -> WETH -> USDC, the reverse direction is inferred from the path
+	got, err = cache.QuoteRoute([]common.Address{daiWeth, usdcWeth}, amountIn)
+	if err != nil {
+		t.Fatalf("QuoteRoute failed: %v", err)
+	}
+	want = v2(usdcWeth).GetAmountOut(v2(daiWeth).GetAmountOut(amountIn, true), false)
+	if got.Cmp(want) != 0 {
+		t.Errorf("DAI->WETH->USDC: expected %s, got %s", want, got)
+	}
+
+	for _, tt := range []struct {
+		name string
+		path []common.Address
+		want error
+	}{
+		{"disconnected", []common.Address{usdcWeth, daiWbtc}, ErrInvalidRoute},
+		// USDC -> DAI -> WETH, then a pool not trading WETH
+		{"disconnected later hop", []common.Address{usdcDai, daiWeth, daiWbtc}, ErrInvalidRoute},
+		{"single pool", []common.Address{usdcWeth}, ErrInvalidRoute},
+		{"same pair", []common.Address{usdcWeth, usdcWeth}, ErrInvalidRoute},
+		{"uncached pool", []common.Address{usdcWeth, common.HexToAddress("0x5")}, ErrNotFound},
+	} {
+		if _, err := cache.QuoteRoute(tt.path, amountIn); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
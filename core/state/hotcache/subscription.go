@@ -0,0 +1,74 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import "sync"
+
+// subscriberBuffer is the number of snapshots buffered per subscriber before
+// older undelivered snapshots start being dropped.
+const subscriberBuffer = 4
+
+// SubscribeUpdates returns a channel that receives every snapshot published by
+// Update, along with a function that cancels the subscription and closes the
+// channel.
+//
+// Delivery never blocks block import: if a subscriber falls behind, the oldest
+// undelivered snapshot is dropped to make room for the newest one. Snapshots
+// are immutable once published and are safe to read concurrently.
+func (c *Cache) SubscribeUpdates() (<-chan *Snapshot, func()) {
+	ch := make(chan *Snapshot, subscriberBuffer)
+
+	c.subMu.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.subMu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			c.subMu.Lock()
+			delete(c.subscribers, ch)
+			close(ch)
+			c.subMu.Unlock()
+		})
+	}
+	return ch, unsubscribe
+}
+
+// notifySubscribers delivers a newly published snapshot to all subscribers
+// without blocking.
+func (c *Cache) notifySubscribers(snapshot *Snapshot) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	for ch := range c.subscribers {
+		select {
+		case ch <- snapshot:
+			continue
+		default:
+		}
+		// Subscriber is lagging, drop its oldest pending snapshot so the
+		// latest one is always delivered.
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- snapshot:
+		default:
+		}
+	}
+}
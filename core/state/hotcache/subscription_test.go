@@ -0,0 +1,133 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSubscribeUpdates(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	reader := newMockStateReader()
+
+	updates, unsubscribe := cache.SubscribeUpdates()
+	defer unsubscribe()
+
+	for i := uint64(1); i <= 2; i++ {
+		if err := cache.Update(testHeader(i), reader); err != nil {
+			t.Fatalf("Update %d failed: %v", i, err)
+		}
+	}
+	var latest *Snapshot
+	for len(updates) > 0 {
+		latest = <-updates
+	}
+	if latest == nil {
+		t.Fatal("Expected at least one snapshot")
+	}
+	if latest != cache.GetSnapshot() {
+		t.Errorf("Expected latest published snapshot, got block %d", latest.BlockNumber)
+	}
+	if latest.BlockNumber != 2 {
+		t.Errorf("Expected block 2, got %d", latest.BlockNumber)
+	}
+}
+
+func TestSubscribeUpdatesSlowConsumer(t *testing.T) {
+	cache := New(Config{Enabled: true})
+	reader := newMockStateReader()
+
+	updates, unsubscribe := cache.SubscribeUpdates()
+
+	// Never read while publishing more snapshots than the buffer holds, the
+	// import path must not block and the newest snapshot must be retained.
+	total := uint64(subscriberBuffer * 3)
+	for i := uint64(1); i <= total; i++ {
+		if err := cache.Update(testHeader(i), reader); err != nil {
+			t.Fatalf("Update %d failed: %v", i, err)
+		}
+	}
+	var latest *Snapshot
+	for len(updates) > 0 {
+		latest = <-updates
+	}
+	if latest == nil || latest.BlockNumber != total {
+		t.Fatalf("Expected newest snapshot %d to be delivered", total)
+	}
+
+	unsubscribe()
+	unsubscribe() // must be idempotent
+	if _, ok := <-updates; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+}
+
+func TestSignificantChanges(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		other = common.HexToAddress("0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool, other}})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	cache.RegisterDecoder(other, &UniswapV2Decoder{})
+	cache.SetChangeThreshold(pool, 50) // 0.5%
+
+	updates, unsubscribe := cache.SubscribeUpdates()
+	defer unsubscribe()
+
+	reader := newMockStateReader()
+	update := func(number uint64, reserve0 int64) []common.Address {
+		t.Helper()
+		packed := new(big.Int).Or(new(big.Int).Lsh(big.NewInt(1000000), 112), big.NewInt(reserve0))
+		reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(packed))
+		reader.setState(other, uniswapV2SlotReserves, common.BigToHash(packed))
+		if err := cache.Update(testHeader(number), reader); err != nil {
+			t.Fatalf("Update %d failed: %v", number, err)
+		}
+		return (<-updates).SignificantChanges()
+	}
+	// Newly cached contracts are significant
+	if changed := update(1, 1000000); len(changed) != 2 {
+		t.Fatalf("Expected both pools to be significant initially, got %v", changed)
+	}
+	// A 0.2% move only counts for the pool without a threshold
+	if changed := update(2, 1002000); !slices.Equal(changed, []common.Address{other}) {
+		t.Errorf("Expected only %s to be significant below the threshold, got %v", other.Hex(), changed)
+	}
+	// A 1% move exceeds the threshold
+	if changed := update(3, 1012020); len(changed) != 2 {
+		t.Errorf("Expected both pools to be significant above the threshold, got %v", changed)
+	}
+	// Unchanged pools are never significant
+	if changed := update(4, 1012020); len(changed) != 0 {
+		t.Errorf("Expected no significant changes, got %v", changed)
+	}
+
+	// Removing the threshold makes any move significant again
+	cache.SetChangeThreshold(pool, 0)
+	if bps := cache.ChangeThreshold(pool); bps != 0 {
+		t.Errorf("Expected threshold to be removed, have %d", bps)
+	}
+	if changed := update(5, 1012021); len(changed) != 2 {
+		t.Errorf("Expected both pools to be significant without threshold, got %v", changed)
+	}
+}
@@ -17,10 +17,13 @@
 package hotcache
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 )
 
 // Uniswap V2 storage layout:
@@ -39,18 +42,65 @@ var (
 	uniswapV2SlotPrice0Cumulative = common.BigToHash(big.NewInt(9))
 	uniswapV2SlotPrice1Cumulative = common.BigToHash(big.NewInt(10))
 	uniswapV2SlotKLast            = common.BigToHash(big.NewInt(11))
+	uniswapV2Q112                 = new(big.Int).Lsh(big.NewInt(1), 112)
+	
+	// uniswapV2Layout describes the pair storage. The reserves slot packs
+	// reserve0, reserve1 and blockTimestampLast from the least significant
+	// bit, the token slots must hold nothing but the address.
+	uniswapV2Layout = StorageLayout{
+		{Name: "token0", Slot: uniswapV2SlotToken0, Type: FieldAddress},
+		{Name: "token1", Slot: uniswapV2SlotToken1, Type: FieldAddress},
+		{Name: "reserve0", Slot: uniswapV2SlotReserves, Width: 112},
+		{Name: "reserve1", Slot: uniswapV2SlotReserves, Offset: 112, Width: 112},
+		{Name: "blockTimestampLast", Slot: uniswapV2SlotReserves, Offset: 224, Width: 32},
+		{Name: "price0CumulativeLast", Slot: uniswapV2SlotPrice0Cumulative, Optional: true},
+		{Name: "price1CumulativeLast", Slot: uniswapV2SlotPrice1Cumulative, Optional: true},
+		{Name: "kLast", Slot: uniswapV2SlotKLast, Optional: true},
+	}
+
+	// uniswapV2FeeBps is the swap fee charged by each V2-style protocol, in
+	// basis points.
+	uniswapV2FeeBps = map[ContractType]uint16{
+		ContractTypeUniswapV2: 30,
+		ContractTypeSushiSwap: 30,
+	}
 )
 
+// uniswapV2DefaultFeeBps is the swap fee assumed when none is known (0.3%).
+const uniswapV2DefaultFeeBps = 30
+
+//go:generate go run github.com/fjl/gencodec -type UniswapV2State -field-override uniswapV2StateMarshaling -out gen_uniswap_v2_json.go
+
 // UniswapV2State represents the decoded state of a Uniswap V2 pair.
 type UniswapV2State struct {
-	Token0             common.Address
-	Token1             common.Address
-	Reserve0           *big.Int // uint112
-	Reserve1           *big.Int // uint112
-	BlockTimestampLast uint32
-	Price0Cumulative   *big.Int
-	Price1Cumulative   *big.Int
-	KLast              *big.Int
+	Token0             common.Address `json:"token0"`
+	Token1             common.Address `json:"token1"`
+	Reserve0           *big.Int       `json:"reserve0"` // uint112
+	Reserve1           *big.Int       `json:"reserve1"` // uint112
+	BlockTimestampLast uint32         `json:"blockTimestampLast"`
+	Price0Cumulative   *big.Int       `json:"price0CumulativeLast"`
+	Price1Cumulative   *big.Int       `json:"price1CumulativeLast"`
+	KLast              *big.Int       `json:"kLast"`
+	FeeBps             uint16         `json:"feeBps"`              // swap fee, zero means 30 bps
+	Decimals0          *uint8         `json:"decimals0,omitempty"` // nil if not registered
+	Decimals1          *uint8         `json:"decimals1,omitempty"` // nil if not registered
+
+	// OptionalSlotsMissing is set if the price cumulatives and kLast were
+	// not read, e.g. with Config.SkipOptionalSlots. They are zero then and
+	// must not be used for TWAPs.
+	OptionalSlotsMissing bool `json:"optionalSlotsMissing,omitempty"`
+}
+
+// ErrNotPoolToken is returned when quoting a pool in a token it does not trade.
+var ErrNotPoolToken = errors.New("token not traded by pool")
+
+// uniswapV2StateMarshaling renders big integers as decimal strings.
+type uniswapV2StateMarshaling struct {
+	Reserve0         *math.Decimal256
+	Reserve1         *math.Decimal256
+	Price0Cumulative *math.Decimal256
+	Price1Cumulative *math.Decimal256
+	KLast            *math.Decimal256
 }
 
 // String returns a human-readable representation of the pool state.
@@ -59,101 +109,113 @@ func (s *UniswapV2State) String() string {
 		s.Token0.Hex(), s.Token1.Hex(), s.Reserve0.String(), s.Reserve1.String(), s.BlockTimestampLast)
 }
 
+// Copy returns a deep copy of the pair state.
+func (s *UniswapV2State) Copy() *UniswapV2State {
+	cpy := *s
+	cpy.Reserve0 = copyBig(s.Reserve0)
+	cpy.Reserve1 = copyBig(s.Reserve1)
+	cpy.Price0Cumulative = copyBig(s.Price0Cumulative)
+	cpy.Price1Cumulative = copyBig(s.Price1Cumulative)
+	cpy.KLast = copyBig(s.KLast)
+	if s.Decimals0 != nil {
+		decimals := *s.Decimals0
+		cpy.Decimals0 = &decimals
+	}
+	if s.Decimals1 != nil {
+		decimals := *s.Decimals1
+		cpy.Decimals1 = &decimals
+	}
+	return &cpy
+}
+
+// setTokenDecimals implements tokenDecimalsState.
+func (s *UniswapV2State) setTokenDecimals(lookup func(common.Address) (uint8, bool)) {
+	if decimals, ok := lookup(s.Token0); ok {
+		s.Decimals0 = &decimals
+	}
+	if decimals, ok := lookup(s.Token1); ok {
+		s.Decimals1 = &decimals
+	}
+}
+
 // UniswapV2Decoder decodes Uniswap V2 pair state from raw storage slots.
-type UniswapV2Decoder struct{}
+// The same storage layout is shared by V2 forks such as SushiSwap, so the
+// decoder can be tagged with the fork's contract type.
+type UniswapV2Decoder struct {
+	// Protocol is the contract type reported for decoded pairs. The zero
+	// value reports ContractTypeUniswapV2.
+	Protocol ContractType
+
+	// FeeBps is the swap fee of decoded pairs in basis points. The zero
+	// value uses the fee registered for Protocol (30 bps for Uniswap V2 and
+	// SushiSwap); forks charging a different fee, such as PancakeSwap's
+	// 25 bps, must set it explicitly.
+	FeeBps uint16
+}
+
+// NewUniswapV2ForkDecoder creates a decoder for a Uniswap V2 fork with an
+// identical storage layout, reporting the given contract type.
+func NewUniswapV2ForkDecoder(protocol ContractType) *UniswapV2Decoder {
+	return &UniswapV2Decoder{Protocol: protocol}
+}
 
 // Type returns the contract type.
 func (d *UniswapV2Decoder) Type() ContractType {
-	return ContractTypeUniswapV2
+	if d.Protocol == ContractTypeUnknown {
+		return ContractTypeUniswapV2
+	}
+	return d.Protocol
+}
+
+// Stateless reports that the decoder can be shared between pairs.
+func (d *UniswapV2Decoder) Stateless() bool {
+	return true
+}
+
+// feeBps returns the swap fee of decoded pairs in basis points.
+func (d *UniswapV2Decoder) feeBps() uint16 {
+	if d.FeeBps != 0 {
+		return d.FeeBps
+	}
+	if fee, ok := uniswapV2FeeBps[d.Type()]; ok {
+		return fee
+	}
+	return uniswapV2DefaultFeeBps
 }
 
 // RequiredSlots returns the storage slots needed for decoding.
 func (d *UniswapV2Decoder) RequiredSlots() []common.Hash {
-	return []common.Hash{
-		uniswapV2SlotToken0,
-		uniswapV2SlotToken1,
-		uniswapV2SlotReserves,
-		uniswapV2SlotPrice0Cumulative,
-		uniswapV2SlotPrice1Cumulative,
-		uniswapV2SlotKLast,
-	}
+	return uniswapV2Layout.RequiredSlots()
+}
+
+// OptionalSlots returns the slots of the price accumulators and kLast, which
+// decode to zero if absent.
+func (d *UniswapV2Decoder) OptionalSlots() []common.Hash {
+	return uniswapV2Layout.OptionalSlots()
 }
 
 // Decode decodes raw storage slots into UniswapV2State.
 func (d *UniswapV2Decoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
-	state := &UniswapV2State{
-		Reserve0: new(big.Int),
-		Reserve1: new(big.Int),
-		Price0Cumulative: new(big.Int),
-		Price1Cumulative: new(big.Int),
-		KLast: new(big.Int),
-	}
-	
-	// Decode token0 (slot 6)
-	if token0Value, ok := slots[uniswapV2SlotToken0]; ok {
-		state.Token0 = common.BytesToAddress(token0Value.Bytes())
-	} else {
-		return nil, fmt.Errorf("missing token0 slot")
-	}
-	
-	// Decode token1 (slot 7)
-	if token1Value, ok := slots[uniswapV2SlotToken1]; ok {
-		state.Token1 = common.BytesToAddress(token1Value.Bytes())
-	} else {
-		return nil, fmt.Errorf("missing token1 slot")
-	}
-	
-	// Decode reserves (slot 8) - packed: reserve0 (uint112), reserve1 (uint112), blockTimestampLast (uint32)
-	if reservesValue, ok := slots[uniswapV2SlotReserves]; ok {
-		// Extract from 32-byte slot:
-		// bytes 0-13: reserve0 (14 bytes = 112 bits)
-		// bytes 14-27: reserve1 (14 bytes = 112 bits)
-		// bytes 28-31: blockTimestampLast (4 bytes = 32 bits)
-		bytes := reservesValue.Bytes()
-		if len(bytes) == 32 {
-			// Reserve0: bytes 18-31 (last 14 bytes, since it's right-aligned)
-			// Actually, Solidity packs from right to left:
-			// [0-17: padding][18-31: reserve0 + reserve1 + timestamp]
-			// More precisely: rightmost 14 bytes are reserve0, next 14 are reserve1, leftmost 4 are timestamp
-			
-			// Let's extract correctly:
-			// Byte layout (right to left in storage):
-			// [blockTimestampLast (4 bytes)][reserve1 (14 bytes)][reserve0 (14 bytes)]
-			
-			fullValue := new(big.Int).SetBytes(bytes)
-			
-			// Reserve0 is the rightmost 112 bits (14 bytes)
-			mask112 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 112), big.NewInt(1))
-			state.Reserve0.And(fullValue, mask112)
-			
-			// Reserve1 is the next 112 bits
-			shifted := new(big.Int).Rsh(fullValue, 112)
-			state.Reserve1.And(shifted, mask112)
-			
-			// BlockTimestampLast is the next 32 bits
-			timestampShifted := new(big.Int).Rsh(fullValue, 224) // 112 + 112
-			state.BlockTimestampLast = uint32(timestampShifted.Uint64())
-		}
-	} else {
-		return nil, fmt.Errorf("missing reserves slot")
-	}
-	
-	// Decode price0CumulativeLast (slot 9)
-	if price0Value, ok := slots[uniswapV2SlotPrice0Cumulative]; ok {
-		state.Price0Cumulative.SetBytes(price0Value.Bytes())
-	}
-	
-	// Decode price1CumulativeLast (slot 10)
-	if price1Value, ok := slots[uniswapV2SlotPrice1Cumulative]; ok {
-		state.Price1Cumulative.SetBytes(price1Value.Bytes())
+	values, err := uniswapV2Layout.Decode(slots)
+	if err != nil {
+		return nil, err
 	}
-	
-	// Decode kLast (slot 11)
-	if kLastValue, ok := slots[uniswapV2SlotKLast]; ok {
-		state.KLast.SetBytes(kLastValue.Bytes())
-	}
-	
-	return state, nil
+	_, ok0 := slots[uniswapV2SlotPrice0Cumulative]
+	_, ok1 := slots[uniswapV2SlotPrice1Cumulative]
+	_, okK := slots[uniswapV2SlotKLast]
+	return &UniswapV2State{
+		Token0:             values.Address("token0"),
+		Token1:             values.Address("token1"),
+		Reserve0:           values["reserve0"],
+		Reserve1:           values["reserve1"],
+		BlockTimestampLast: uint32(values.Uint64("blockTimestampLast")), // masked to 32 bits by the layout
+		Price0Cumulative:   values["price0CumulativeLast"],
+		Price1Cumulative:   values["price1CumulativeLast"],
+		KLast:              values["kLast"],
+		FeeBps:             d.feeBps(),
+
+		OptionalSlotsMissing: !ok0 || !ok1 || !okK,
+	}, nil
 }
 
 // GetPrice returns the current price of token0 in terms of token1.
@@ -167,6 +229,23 @@ func (s *UniswapV2State) GetPrice() *big.Float {
 	return new(big.Float).Quo(reserve1Float, reserve0Float)
 }
 
+// GetPriceInto is like GetPrice but stores the price in out and returns it.
+// Intermediates are pooled, so only the division itself allocates on hot
+// paths. The result is rounded to the precision of out, or to that of
+// GetPrice if out has none.
+func (s *UniswapV2State) GetPriceInto(out *big.Float) *big.Float {
+	if s.Reserve0.Sign() == 0 {
+		return out.SetInt64(0)
+	}
+	scratch := quoteScratchPool.Get().(*quoteScratch)
+	defer quoteScratchPool.Put(scratch)
+
+	// Reset the precision so SetInt sizes it exactly, as for a new Float
+	reserve0Float := scratch.f0.SetPrec(0).SetInt(s.Reserve0)
+	reserve1Float := scratch.f1.SetPrec(0).SetInt(s.Reserve1)
+	return out.Quo(reserve1Float, reserve0Float)
+}
+
 // GetInversePrice returns the price of token1 in terms of token0.
 // InversePrice = reserve0 / reserve1
 func (s *UniswapV2State) GetInversePrice() *big.Float {
@@ -178,3 +257,223 @@ func (s *UniswapV2State) GetInversePrice() *big.Float {
 	return new(big.Float).Quo(reserve0Float, reserve1Float)
 }
 
+// QuotedPrice returns the price of the pool's other token in units of
+// quoteToken, i.e. reserveQuote / reserveBase, so QuotedPrice(Token1) equals
+// GetPrice and QuotedPrice(Token0) equals GetInversePrice. ErrNotPoolToken is
+// returned if the pool does not trade quoteToken.
+func (s *UniswapV2State) QuotedPrice(quoteToken common.Address) (*big.Float, error) {
+	switch quoteToken {
+	case s.Token1:
+		return s.GetPrice(), nil
+	case s.Token0:
+		return s.GetInversePrice(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotPoolToken, quoteToken.Hex())
+}
+
+// GetPriceRat returns the price of token0 in terms of token1 as the exact
+// ratio reserve1 / reserve0, or zero if reserve0 is empty. Unlike GetPrice it
+// is free of rounding, so prices of different pools compare exactly.
+func (s *UniswapV2State) GetPriceRat() *big.Rat {
+	if s.Reserve0.Sign() == 0 {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(s.Reserve1, s.Reserve0)
+}
+
+// GetPriceNormalized returns the price of token0 in terms of token1 in whole
+// tokens, i.e. GetPrice divided by 10^(decimals1-decimals0). Returns nil if
+// the decimals of either token are not known; see Cache.SetTokenDecimals.
+func (s *UniswapV2State) GetPriceNormalized() *big.Float {
+	if s.Decimals0 == nil || s.Decimals1 == nil {
+		return nil
+	}
+	return scaleDecimals(s.GetPrice(), int(*s.Decimals0)-int(*s.Decimals1))
+}
+
+// GetInversePriceNormalized returns the price of token1 in terms of token0 in
+// whole tokens, or nil if the decimals of either token are not known.
+func (s *UniswapV2State) GetInversePriceNormalized() *big.Float {
+	if s.Decimals0 == nil || s.Decimals1 == nil {
+		return nil
+	}
+	return scaleDecimals(s.GetInversePrice(), int(*s.Decimals1)-int(*s.Decimals0))
+}
+
+// scaleDecimals multiplies x by 10^exp in place and returns it.
+func scaleDecimals(x *big.Float, exp int) *big.Float {
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(exp, -exp))), nil))
+	if exp < 0 {
+		return x.Quo(x, scale)
+	}
+	return x.Mul(x, scale)
+}
+
+// reserves returns the input and output reserves for a swap in the given
+// direction.
+func (s *UniswapV2State) reserves(zeroForOne bool) (*big.Int, *big.Int) {
+	if zeroForOne {
+		return s.Reserve0, s.Reserve1
+	}
+	return s.Reserve1, s.Reserve0
+}
+
+// feeMultiplier returns the share of the input kept after fees, in basis
+// points (9970 for the standard 0.3% fee).
+func (s *UniswapV2State) feeMultiplier() *big.Int {
+	return big.NewInt(s.feeKept())
+}
+
+// feeKept is feeMultiplier as a plain integer.
+func (s *UniswapV2State) feeKept() int64 {
+	fee := s.FeeBps
+	if fee == 0 {
+		fee = uniswapV2DefaultFeeBps
+	}
+	return 10000 - int64(fee)
+}
+
+// quoteScratch holds the intermediates of the Into variants of the quoting
+// methods, pooled so their backing arrays are reused between calls.
+type quoteScratch struct {
+	i0, i1, i2, i3 big.Int
+	f0, f1         big.Float
+}
+
+var quoteScratchPool = sync.Pool{
+	New: func() interface{} { return new(quoteScratch) },
+}
+
+// GetAmountOut returns the output amount for swapping amountIn, mirroring
+// UniswapV2Library.getAmountOut with the pool's fee (997/1000 for 0.3%):
+// amountOut = amountIn*(10000-fee)*reserveOut / (reserveIn*10000 + amountIn*(10000-fee))
+// zeroForOne selects a token0 -> token1 swap. Zero is returned if either
+// reserve or the input is empty.
+func (s *UniswapV2State) GetAmountOut(amountIn *big.Int, zeroForOne bool) *big.Int {
+	reserveIn, reserveOut := s.reserves(zeroForOne)
+	if amountIn.Sign() <= 0 || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+		return new(big.Int)
+	}
+	amountInWithFee := new(big.Int).Mul(amountIn, s.feeMultiplier())
+	numerator := new(big.Int).Mul(amountInWithFee, reserveOut)
+	denominator := new(big.Int).Mul(reserveIn, big.NewInt(10000))
+	denominator.Add(denominator, amountInWithFee)
+	return numerator.Div(numerator, denominator)
+}
+
+// GetAmountOutInto is like GetAmountOut but stores the output amount in out
+// and returns it. Intermediates are pooled, so only the division itself
+// allocates on hot paths. out may alias amountIn.
+func (s *UniswapV2State) GetAmountOutInto(out, amountIn *big.Int, zeroForOne bool) *big.Int {
+	reserveIn, reserveOut := s.reserves(zeroForOne)
+	if amountIn.Sign() <= 0 || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+		return out.SetInt64(0)
+	}
+	scratch := quoteScratchPool.Get().(*quoteScratch)
+	defer quoteScratchPool.Put(scratch)
+
+	// Operands never alias their result, which would make big.Int allocate
+	amountInWithFee := scratch.i0.Mul(amountIn, scratch.i1.SetInt64(s.feeKept()))
+	denominator := scratch.i2.Mul(reserveIn, scratch.i1.SetInt64(10000))
+	denominator.Add(denominator, amountInWithFee)
+	numerator := scratch.i1.Mul(amountInWithFee, reserveOut)
+
+	// All operands are positive, so truncated division matches Div
+	out.QuoRem(numerator, denominator, &scratch.i3)
+	return out
+}
+
+// PriceImpact returns the fractional price move (executionPrice - spotPrice) /
+// spotPrice of swapping amountIn, where the execution price is the output per
+// unit of input given by GetAmountOut. The result is negative and includes
+// the swap fee. It is computed before GetAmountOut rounds the output down, so
+// tiny trades approach the fee (-0.003 at 30 bps) rather than -1. Zero is
+// returned if amountIn is not positive or either reserve is empty.
+func (s *UniswapV2State) PriceImpact(amountIn *big.Int, zeroForOne bool) *big.Float {
+	reserveIn, reserveOut := s.reserves(zeroForOne)
+	if amountIn.Sign() <= 0 || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+		return new(big.Float)
+	}
+	// executionPrice / spotPrice = amountOut*reserveIn / (amountIn*reserveOut)
+	//                            = reserveIn*fee / (reserveIn*10000 + amountIn*fee)
+	fee := s.feeMultiplier()
+	numerator := new(big.Int).Mul(reserveIn, fee)
+	denominator := new(big.Int).Mul(amountIn, fee)
+	denominator.Add(denominator, new(big.Int).Mul(reserveIn, big.NewInt(10000)))
+	ratio := new(big.Rat).SetFrac(numerator, denominator)
+	ratio.Sub(ratio, big.NewRat(1, 1))
+	return new(big.Float).SetRat(ratio)
+}
+
+// GetAmountIn returns the input amount required to receive amountOut, mirroring
+// UniswapV2Library.getAmountIn with the pool's fee:
+// amountIn = reserveIn*amountOut*10000 / ((reserveOut - amountOut)*(10000-fee)) + 1
+// Zero is returned if either reserve is empty or the pool cannot provide
+// amountOut.
+func (s *UniswapV2State) GetAmountIn(amountOut *big.Int, zeroForOne bool) *big.Int {
+	reserveIn, reserveOut := s.reserves(zeroForOne)
+	if amountOut.Sign() <= 0 || reserveIn.Sign() == 0 || amountOut.Cmp(reserveOut) >= 0 {
+		return new(big.Int)
+	}
+	numerator := new(big.Int).Mul(reserveIn, amountOut)
+	numerator.Mul(numerator, big.NewInt(10000))
+	denominator := new(big.Int).Sub(reserveOut, amountOut)
+	denominator.Mul(denominator, s.feeMultiplier())
+	numerator.Div(numerator, denominator)
+	return numerator.Add(numerator, big.NewInt(1))
+}
+
+// AtTimestamp returns a copy of the state with the price cumulatives advanced
+// to the given block timestamp using the current reserves, mirroring
+// UniswapV2OracleLibrary.currentCumulativePrices. The pair only accumulates
+// on swaps and syncs, so this is needed to compare cumulatives between blocks
+// in which the pair was not touched.
+func (s *UniswapV2State) AtTimestamp(timestamp uint64) *UniswapV2State {
+	cpy := *s
+	cpy.Price0Cumulative = new(big.Int).Set(s.Price0Cumulative)
+	cpy.Price1Cumulative = new(big.Int).Set(s.Price1Cumulative)
+	cpy.BlockTimestampLast = uint32(timestamp) // timestamp % 2**32
+
+	if cpy.BlockTimestampLast != s.BlockTimestampLast && s.Reserve0.Sign() != 0 && s.Reserve1.Sign() != 0 {
+		// Subtraction overflow is desired, as in the oracle library
+		elapsed := new(big.Int).SetUint64(uint64(cpy.BlockTimestampLast - s.BlockTimestampLast))
+
+		price0 := new(big.Int).Lsh(s.Reserve1, 112)
+		price0.Div(price0, s.Reserve0)
+		cpy.Price0Cumulative.Add(cpy.Price0Cumulative, price0.Mul(price0, elapsed))
+		math.U256(cpy.Price0Cumulative)
+
+		price1 := new(big.Int).Lsh(s.Reserve0, 112)
+		price1.Div(price1, s.Reserve1)
+		cpy.Price1Cumulative.Add(cpy.Price1Cumulative, price1.Mul(price1, elapsed))
+		math.U256(cpy.Price1Cumulative)
+	}
+	return &cpy
+}
+
+// TWAP returns the time-weighted average prices between prev and s:
+// price = (cumulativeNow - cumulativePrev) / (timeNow - timePrev) / 2^112
+// price0 is token0 in terms of token1 and price1 the inverse. Both the uint32
+// timestamp and the uint256 cumulatives are allowed to wrap around, as the
+// Uniswap oracle does. Zero prices are returned if no time has elapsed.
+//
+// The stored cumulatives are only as recent as BlockTimestampLast; use
+// AtTimestamp on both states to compare at specific block times. The result
+// is meaningless if either state has OptionalSlotsMissing set.
+func (s *UniswapV2State) TWAP(prev *UniswapV2State) (price0, price1 *big.Float) {
+	elapsed := s.BlockTimestampLast - prev.BlockTimestampLast // overflow is desired
+	if elapsed == 0 {
+		return big.NewFloat(0), big.NewFloat(0)
+	}
+	return uniswapV2Average(s.Price0Cumulative, prev.Price0Cumulative, elapsed),
+		uniswapV2Average(s.Price1Cumulative, prev.Price1Cumulative, elapsed)
+}
+
+// uniswapV2Average converts a UQ112x112 cumulative price delta into an
+// average price over the elapsed seconds.
+func uniswapV2Average(now, prev *big.Int, elapsed uint32) *big.Float {
+	delta := math.U256(new(big.Int).Sub(now, prev)) // overflow is desired
+	avg := new(big.Float).SetPrec(256).SetInt(delta)
+	avg.Quo(avg, new(big.Float).SetPrec(256).SetInt(uniswapV2Q112))
+	return avg.Quo(avg, new(big.Float).SetUint64(uint64(elapsed)))
+}
@@ -17,7 +17,10 @@
 package hotcache
 
 import (
+	"errors"
+	"math"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -33,8 +36,12 @@ func TestUniswapV2Decoder(t *testing.T) {
 	
 	// Test required slots
 	slots := decoder.RequiredSlots()
-	if len(slots) != 6 {
-		t.Errorf("Expected 6 required slots, got %d", len(slots))
+	if len(slots) != 3 {
+		t.Errorf("Expected 3 required slots, got %d", len(slots))
+	}
+	// The price accumulators and kLast are optional
+	if optional := decoder.OptionalSlots(); len(optional) != 3 {
+		t.Errorf("Expected 3 optional slots, got %d", len(optional))
 	}
 }
 
@@ -117,6 +124,133 @@ func TestUniswapV2Decode(t *testing.T) {
 	}
 }
 
+// TestUniswapV2DecodeLeadingZeros checks that a packed reserves slot whose
+// value does not fill all 32 bytes (zero timestamp, small reserves) still
+// decodes instead of silently yielding zero reserves.
+func TestUniswapV2DecodeLeadingZeros(t *testing.T) {
+	decoder := &UniswapV2Decoder{}
+
+	reserve0 := big.NewInt(7)
+	reserve1 := big.NewInt(3)
+	packed := new(big.Int).Or(reserve0, new(big.Int).Lsh(reserve1, 112))
+
+	slots := map[common.Hash]common.Hash{
+		uniswapV2SlotToken0:   common.Hash{},
+		uniswapV2SlotToken1:   common.Hash{},
+		uniswapV2SlotReserves: common.BigToHash(packed),
+	}
+	decoded, err := decoder.Decode(slots)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	state := decoded.(*UniswapV2State)
+	if state.Reserve0.Cmp(reserve0) != 0 {
+		t.Errorf("Expected reserve0 %s, got %s", reserve0, state.Reserve0)
+	}
+	if state.Reserve1.Cmp(reserve1) != 0 {
+		t.Errorf("Expected reserve1 %s, got %s", reserve1, state.Reserve1)
+	}
+	if state.BlockTimestampLast != 0 {
+		t.Errorf("Expected zero timestamp, got %d", state.BlockTimestampLast)
+	}
+}
+
+// TestUniswapV2DecodeDirtyTokenSlot checks that a token slot packing data
+// above the address is rejected instead of decoded as an address.
+func TestUniswapV2DecodeDirtyTokenSlot(t *testing.T) {
+	decoder := &UniswapV2Decoder{}
+
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	dirty := common.BytesToHash(token.Bytes())
+	dirty[0] = 0x01
+
+	for _, slot := range []common.Hash{uniswapV2SlotToken0, uniswapV2SlotToken1} {
+		slots := map[common.Hash]common.Hash{
+			uniswapV2SlotToken0:   common.BytesToHash(token.Bytes()),
+			uniswapV2SlotToken1:   common.BytesToHash(token.Bytes()),
+			uniswapV2SlotReserves: common.Hash{},
+		}
+		slots[slot] = dirty
+		if _, err := decoder.Decode(slots); err == nil {
+			t.Errorf("Expected decode error for dirty slot %s", slot.Hex())
+		}
+	}
+}
+
+func TestUniswapV2DecodeMissingSlot(t *testing.T) {
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	slots := map[common.Hash]common.Hash{
+		uniswapV2SlotToken0:   common.BytesToHash(token.Bytes()),
+		uniswapV2SlotReserves: common.Hash{},
+	}
+	_, err := (&UniswapV2Decoder{}).Decode(slots)
+	if !errors.Is(err, ErrMissingSlot) {
+		t.Fatalf("Expected ErrMissingSlot, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "token1") {
+		t.Errorf("Expected the error to name the token1 slot, got %v", err)
+	}
+}
+
+// TestUniswapV2DecodeOptionalSlotsAbsent checks that a pool decodes from its
+// required slots alone, with the optional accumulators and kLast as zero.
+func TestUniswapV2DecodeOptionalSlotsAbsent(t *testing.T) {
+	token0 := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	token1 := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	packed := new(big.Int).Or(new(big.Int).Lsh(big.NewInt(500), 112), big.NewInt(1000000))
+	slots := map[common.Hash]common.Hash{
+		uniswapV2SlotToken0:   common.BytesToHash(token0.Bytes()),
+		uniswapV2SlotToken1:   common.BytesToHash(token1.Bytes()),
+		uniswapV2SlotReserves: common.BigToHash(packed),
+	}
+	decoded, err := (&UniswapV2Decoder{}).Decode(slots)
+	if err != nil {
+		t.Fatalf("Failed to decode without optional slots: %v", err)
+	}
+	state := decoded.(*UniswapV2State)
+	if state.Reserve0.Int64() != 1000000 || state.Reserve1.Int64() != 500 {
+		t.Errorf("Unexpected reserves %v and %v", state.Reserve0, state.Reserve1)
+	}
+	if state.Price0Cumulative.Sign() != 0 || state.Price1Cumulative.Sign() != 0 || state.KLast.Sign() != 0 {
+		t.Errorf("Expected absent optional fields to decode as zero, got %v, %v and %v",
+			state.Price0Cumulative, state.Price1Cumulative, state.KLast)
+	}
+	if !state.OptionalSlotsMissing {
+		t.Error("Expected the absent optional slots to be recorded")
+	}
+}
+
+// TestUniswapV2DecodeTimestampTopByte checks that the timestamp, which fills
+// the top 32 bits of the reserves slot, is decoded from a slot whose top byte
+// is set without leaking into or out of the reserves.
+func TestUniswapV2DecodeTimestampTopByte(t *testing.T) {
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	for _, timestamp := range []uint64{0xff000000, 0xffffffff} {
+		packed := new(big.Int).Lsh(new(big.Int).SetUint64(timestamp), 224)
+		packed.Or(packed, new(big.Int).Lsh(big.NewInt(7), 112))
+		packed.Or(packed, big.NewInt(5))
+		slots := map[common.Hash]common.Hash{
+			uniswapV2SlotToken0:   common.BytesToHash(token.Bytes()),
+			uniswapV2SlotToken1:   common.BytesToHash(token.Bytes()),
+			uniswapV2SlotReserves: common.BigToHash(packed),
+		}
+		if top := slots[uniswapV2SlotReserves][0]; top != 0xff {
+			t.Fatalf("Expected top byte 0xff, got %#x", top)
+		}
+		decoded, err := (&UniswapV2Decoder{}).Decode(slots)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		state := decoded.(*UniswapV2State)
+		if uint64(state.BlockTimestampLast) != timestamp {
+			t.Errorf("Unexpected timestamp: have %#x, want %#x", state.BlockTimestampLast, timestamp)
+		}
+		if state.Reserve0.Int64() != 5 || state.Reserve1.Int64() != 7 {
+			t.Errorf("Unexpected reserves %v/%v", state.Reserve0, state.Reserve1)
+		}
+	}
+}
+
 func TestUniswapV2String(t *testing.T) {
 	state := &UniswapV2State{
 		Token0:   common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
@@ -171,3 +305,407 @@ func BenchmarkUniswapV2Decode(b *testing.B) {
 	}
 }
 
+func TestUniswapV2GetPriceInto(t *testing.T) {
+	state := &UniswapV2State{Reserve0: big.NewInt(3), Reserve1: big.NewInt(1000)}
+	out := new(big.Float)
+	if got := state.GetPriceInto(out); got != out || got.Cmp(state.GetPrice()) != 0 {
+		t.Errorf("Expected price %s into the given output, got %s", state.GetPrice(), got)
+	}
+	// Reusing the output, including for an empty pool
+	state.Reserve0 = big.NewInt(7)
+	if got := state.GetPriceInto(out); got.Cmp(state.GetPrice()) != 0 {
+		t.Errorf("Expected price %s after reuse, got %s", state.GetPrice(), got)
+	}
+	state.Reserve0 = new(big.Int)
+	if got := state.GetPriceInto(out); got.Sign() != 0 {
+		t.Errorf("Expected zero price for empty pool, got %s", got)
+	}
+}
+
+func benchmarkPool() *UniswapV2State {
+	reserve0, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	return &UniswapV2State{Reserve0: reserve0, Reserve1: big.NewInt(2000000000000)}
+}
+
+func BenchmarkUniswapV2GetAmountOut(b *testing.B) {
+	state, amountIn := benchmarkPool(), big.NewInt(1000000000000000000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		state.GetAmountOut(amountIn, true)
+	}
+}
+
+func BenchmarkUniswapV2GetAmountOutInto(b *testing.B) {
+	state, amountIn, out := benchmarkPool(), big.NewInt(1000000000000000000), new(big.Int)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		state.GetAmountOutInto(out, amountIn, true)
+	}
+}
+
+func BenchmarkUniswapV2GetPrice(b *testing.B) {
+	state := benchmarkPool()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		state.GetPrice()
+	}
+}
+
+func BenchmarkUniswapV2GetPriceInto(b *testing.B) {
+	state, out := benchmarkPool(), new(big.Float)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		state.GetPriceInto(out)
+	}
+}
+
+func TestUniswapV2ForkDecoderType(t *testing.T) {
+	pool := common.HexToAddress("0x397FF1542f962076d0BFE58eA045FfA2d347ACa0")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.RegisterDecoder(pool, NewUniswapV2ForkDecoder(ContractTypeSushiSwap))
+
+	reader := newMockStateReader()
+	reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(big.NewInt(42)))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(pool)
+	if err != nil {
+		t.Fatalf("Pool not cached: %v", err)
+	}
+	if state.Type != ContractTypeSushiSwap {
+		t.Errorf("Expected type %v, got %v", ContractTypeSushiSwap, state.Type)
+	}
+	if state.Type.String() != "SushiSwap" {
+		t.Errorf("Unexpected type name %q", state.Type.String())
+	}
+	v2, ok := state.Decoded.(*UniswapV2State)
+	if !ok {
+		t.Fatalf("Expected *UniswapV2State, got %T", state.Decoded)
+	}
+	if v2.Reserve0.Int64() != 42 {
+		t.Errorf("Expected reserve0 42, got %s", v2.Reserve0)
+	}
+
+	// The zero value keeps reporting plain Uniswap V2
+	if typ := (&UniswapV2Decoder{}).Type(); typ != ContractTypeUniswapV2 {
+		t.Errorf("Expected default type %v, got %v", ContractTypeUniswapV2, typ)
+	}
+}
+
+func TestUniswapV2GetAmountOut(t *testing.T) {
+	bigint := func(s string) *big.Int {
+		v, _ := new(big.Int).SetString(s, 10)
+		return v
+	}
+	// 1000 WETH / 2M USDC and 5000 / 3M (18 decimals) pools, expected values
+	// match UniswapV2Router02.getAmountsOut/getAmountsIn.
+	ethUSDC := &UniswapV2State{Reserve0: bigint("1000000000000000000000"), Reserve1: bigint("2000000000000")}
+	deep := &UniswapV2State{Reserve0: bigint("5000000000000000000000"), Reserve1: bigint("3000000000000000000000000")}
+
+	outTests := []struct {
+		state      *UniswapV2State
+		amountIn   string
+		zeroForOne bool
+		want       string
+	}{
+		{ethUSDC, "1000000000000000000", true, "1992013962"},
+		{ethUSDC, "2000000000", false, "996006981039903216"},
+		{deep, "100000000000000000000", true, "58650508853461968351079"},
+		{deep, "10000000000000000000000", false, "16561626860068372774"},
+	}
+	for i, tt := range outTests {
+		if got := tt.state.GetAmountOut(bigint(tt.amountIn), tt.zeroForOne); got.String() != tt.want {
+			t.Errorf("test %d: expected amount out %s, got %s", i, tt.want, got)
+		}
+		out := big.NewInt(-1)
+		if got := tt.state.GetAmountOutInto(out, bigint(tt.amountIn), tt.zeroForOne); got != out || got.String() != tt.want {
+			t.Errorf("test %d: expected amount out %s into the given output, got %s", i, tt.want, got)
+		}
+	}
+
+	inTests := []struct {
+		state      *UniswapV2State
+		amountOut  string
+		zeroForOne bool
+		want       string
+	}{
+		{ethUSDC, "1990000000", true, "998987974980943552"},
+		{ethUSDC, "1000000000000000000", false, "2008026081"},
+		{deep, "10000000000000000000000", true, "16772726205371968750"},
+	}
+	for i, tt := range inTests {
+		if got := tt.state.GetAmountIn(bigint(tt.amountOut), tt.zeroForOne); got.String() != tt.want {
+			t.Errorf("test %d: expected amount in %s, got %s", i, tt.want, got)
+		}
+	}
+
+	// Empty pools and outputs exceeding the reserves quote zero
+	empty := &UniswapV2State{Reserve0: new(big.Int), Reserve1: big.NewInt(1000)}
+	if got := empty.GetAmountOut(big.NewInt(100), true); got.Sign() != 0 {
+		t.Errorf("Expected zero amount out for empty pool, got %s", got)
+	}
+	if got := empty.GetAmountOutInto(big.NewInt(1), big.NewInt(100), true); got.Sign() != 0 {
+		t.Errorf("Expected zero amount out into the given output for empty pool, got %s", got)
+	}
+	if got := empty.GetAmountIn(big.NewInt(100), true); got.Sign() != 0 {
+		t.Errorf("Expected zero amount in for empty pool, got %s", got)
+	}
+	if got := ethUSDC.GetAmountIn(ethUSDC.Reserve1, true); got.Sign() != 0 {
+		t.Errorf("Expected zero amount in when draining the pool, got %s", got)
+	}
+}
+
+func TestUniswapV2FeeBps(t *testing.T) {
+	reserve0, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	reserve1 := big.NewInt(2000000000000)
+	amountIn := big.NewInt(1000000000000000000)
+
+	slots := map[common.Hash]common.Hash{
+		uniswapV2SlotToken0:   {},
+		uniswapV2SlotToken1:   {},
+		uniswapV2SlotReserves: common.BigToHash(new(big.Int).Or(new(big.Int).Lsh(reserve1, 112), reserve0)),
+	}
+	decode := func(decoder *UniswapV2Decoder) *UniswapV2State {
+		decoded, err := decoder.Decode(slots)
+		if err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		return decoded.(*UniswapV2State)
+	}
+	uniswap := decode(&UniswapV2Decoder{})
+	sushi := decode(NewUniswapV2ForkDecoder(ContractTypeSushiSwap))
+	pancake := decode(&UniswapV2Decoder{Protocol: ContractTypeSushiSwap, FeeBps: 25})
+
+	if uniswap.FeeBps != 30 || sushi.FeeBps != 30 {
+		t.Errorf("Expected 30 bps fees, got %d/%d", uniswap.FeeBps, sushi.FeeBps)
+	}
+	if pancake.FeeBps != 25 {
+		t.Errorf("Expected 25 bps fee, got %d", pancake.FeeBps)
+	}
+	if got := uniswap.GetAmountOut(amountIn, true); got.String() != "1992013962" {
+		t.Errorf("Expected 30 bps amount out 1992013962, got %s", got)
+	}
+	if got := pancake.GetAmountOut(amountIn, true); got.String() != "1993011970" {
+		t.Errorf("Expected 25 bps amount out 1993011970, got %s", got)
+	}
+	out30, out25 := uniswap.GetAmountOut(amountIn, true), pancake.GetAmountOut(amountIn, true)
+	if out25.Cmp(out30) <= 0 {
+		t.Errorf("Expected 25 bps pool to quote more than 30 bps pool: %s <= %s", out25, out30)
+	}
+	// Exact-output quotes round-trip within the same fee tier
+	if in := pancake.GetAmountIn(out25, true); in.Cmp(amountIn) > 0 {
+		t.Errorf("Expected amount in <= %s for 25 bps pool, got %s", amountIn, in)
+	}
+}
+
+func TestUniswapV2TWAPOverflow(t *testing.T) {
+	q112 := new(big.Int).Lsh(big.NewInt(1), 112)
+	mul := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), q112) }
+
+	// The timestamp wraps from 2^32-10 to 20 (30 seconds) and price0Cumulative
+	// wraps past 2^256 while accumulating an average price of 2.
+	prev := &UniswapV2State{
+		BlockTimestampLast: 1<<32 - 10,
+		Price0Cumulative:   new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), mul(5)),
+		Price1Cumulative:   mul(100),
+	}
+	now := &UniswapV2State{
+		BlockTimestampLast: 20,
+		Price0Cumulative:   mul(55),
+		Price1Cumulative:   mul(115),
+	}
+	price0, price1 := now.TWAP(prev)
+	if p, _ := price0.Float64(); p != 2 {
+		t.Errorf("Expected price0 TWAP 2, got %v", price0)
+	}
+	if p, _ := price1.Float64(); p != 0.5 {
+		t.Errorf("Expected price1 TWAP 0.5, got %v", price1)
+	}
+	if price0, _ := now.TWAP(now); price0.Sign() != 0 {
+		t.Errorf("Expected zero TWAP without elapsed time, got %v", price0)
+	}
+}
+
+func TestUniswapV2AtTimestamp(t *testing.T) {
+	state := &UniswapV2State{
+		Reserve0:           big.NewInt(1000),
+		Reserve1:           big.NewInt(2000),
+		BlockTimestampLast: 1<<32 - 5,
+		Price0Cumulative:   new(big.Int),
+		Price1Cumulative:   new(big.Int),
+	}
+	// A block 10 seconds later, past the uint32 wraparound
+	later := state.AtTimestamp(1<<32 + 5)
+	if later.BlockTimestampLast != 5 {
+		t.Errorf("Expected wrapped timestamp 5, got %d", later.BlockTimestampLast)
+	}
+	if state.Price0Cumulative.Sign() != 0 || state.BlockTimestampLast != 1<<32-5 {
+		t.Error("AtTimestamp modified the original state")
+	}
+	// Without intermediate swaps the TWAP equals the spot price
+	price0, price1 := later.TWAP(state)
+	if p, _ := price0.Float64(); p != 2 {
+		t.Errorf("Expected price0 TWAP 2, got %v", price0)
+	}
+	if p, _ := price1.Float64(); p != 0.5 {
+		t.Errorf("Expected price1 TWAP 0.5, got %v", price1)
+	}
+}
+
+func TestUniswapV2PriceNormalized(t *testing.T) {
+	var (
+		pair = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		weth = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	)
+	// 20M USDC against 10k WETH
+	reserve0 := big.NewInt(20_000_000e6)
+	reserve1, _ := new(big.Int).SetString("10000000000000000000000", 10)
+	packed := new(big.Int).Or(reserve0, new(big.Int).Lsh(reserve1, 112))
+
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pair}})
+	cache.RegisterDecoder(pair, &UniswapV2Decoder{})
+
+	reader := newMockStateReader()
+	reader.setState(pair, uniswapV2SlotToken0, common.BytesToHash(usdc.Bytes()))
+	reader.setState(pair, uniswapV2SlotToken1, common.BytesToHash(weth.Bytes()))
+	reader.setState(pair, uniswapV2SlotReserves, common.BigToHash(packed))
+
+	// Without registered decimals no normalized price is available
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, _ := cache.GetContractState(pair)
+	if price := state.Decoded.(*UniswapV2State).GetPriceNormalized(); price != nil {
+		t.Errorf("Expected no normalized price without decimals, got %v", price)
+	}
+
+	cache.SetTokenDecimals(usdc, 6)
+	cache.SetTokenDecimals(weth, 18)
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, _ = cache.GetContractState(pair)
+	v2 := state.Decoded.(*UniswapV2State)
+
+	// The raw ratio is off by 10^12
+	if raw, _ := v2.GetPrice().Float64(); raw < 4.99e8 || raw > 5.01e8 {
+		t.Errorf("Expected raw price ~5e8, got %v", raw)
+	}
+	if price, _ := v2.GetPriceNormalized().Float64(); price < 0.000499 || price > 0.000501 {
+		t.Errorf("Expected ~0.0005 WETH per USDC, got %v", price)
+	}
+	if price, _ := v2.GetInversePriceNormalized().Float64(); price < 1999.99 || price > 2000.01 {
+		t.Errorf("Expected ~2000 USDC per WETH, got %v", price)
+	}
+	cpy := v2.Copy()
+	*cpy.Decimals0 = 18
+	if *v2.Decimals0 != 6 {
+		t.Error("Copy shares decimals with the original")
+	}
+}
+
+func TestUniswapV2PriceImpact(t *testing.T) {
+	// 1000 WETH / 2M USDC
+	reserve0, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	pool := &UniswapV2State{Reserve0: reserve0, Reserve1: big.NewInt(2_000_000_000_000)}
+	ether := big.NewInt(1_000_000_000_000_000_000)
+
+	impact := func(amountIn *big.Int, zeroForOne bool) float64 {
+		f, _ := pool.PriceImpact(amountIn, zeroForOne).Float64()
+		return f
+	}
+	// A tiny trade only pays the 0.3% fee, even if its output rounds to zero
+	if tiny := impact(big.NewInt(1), true); math.Abs(tiny+0.003) > 1e-9 {
+		t.Errorf("Expected tiny trade impact of -0.003, got %v", tiny)
+	}
+	small := impact(ether, true)
+	large := impact(new(big.Int).Mul(ether, big.NewInt(100)), true)
+	if small >= 0 || large >= small {
+		t.Errorf("Expected the large trade to move the price more: small %v, large %v", small, large)
+	}
+	// 100 WETH into 1000 WETH reserves: 9970*1000/(1000*10000+100*9970) - 1
+	if want := 9970.0*1000/(1000*10000+100*9970) - 1; math.Abs(large-want) > 1e-12 {
+		t.Errorf("Expected large trade impact %v, got %v", want, large)
+	}
+	// Agrees with the rounded execution price of GetAmountOut
+	out := new(big.Float).SetInt(pool.GetAmountOut(ether, true))
+	exec, _ := out.Quo(out, new(big.Float).SetInt(ether)).Float64()
+	spot, _ := pool.GetPrice().Float64()
+	if want := (exec - spot) / spot; math.Abs(small-want) > 1e-6 {
+		t.Errorf("Expected small trade impact %v, got %v", want, small)
+	}
+	if reverse := impact(big.NewInt(2_000_000_000), false); reverse >= 0 {
+		t.Errorf("Expected negative impact selling token1, got %v", reverse)
+	}
+
+	empty := &UniswapV2State{Reserve0: new(big.Int), Reserve1: big.NewInt(1)}
+	if got := empty.PriceImpact(ether, true); got.Sign() != 0 {
+		t.Errorf("Expected zero impact on an empty pool, got %v", got)
+	}
+	if got := pool.PriceImpact(new(big.Int), true); got.Sign() != 0 {
+		t.Errorf("Expected zero impact for a zero trade, got %v", got)
+	}
+}
+
+func TestUniswapV2PriceRat(t *testing.T) {
+	// 1 : 3 at very different depths, the float prices differ in rounding
+	a := &UniswapV2State{Reserve0: big.NewInt(3), Reserve1: big.NewInt(1)}
+	b := &UniswapV2State{Reserve0: new(big.Int).Mul(big.NewInt(3), big.NewInt(1e18)), Reserve1: big.NewInt(1e18)}
+	if a.GetPriceRat().Cmp(b.GetPriceRat()) != 0 {
+		t.Errorf("Expected equal prices, have %v and %v", a.GetPriceRat(), b.GetPriceRat())
+	}
+	if want := big.NewRat(1, 3); a.GetPriceRat().Cmp(want) != 0 {
+		t.Errorf("Unexpected price: have %v, want %v", a.GetPriceRat(), want)
+	}
+	// A single wei of difference is still visible
+	c := &UniswapV2State{Reserve0: b.Reserve0, Reserve1: new(big.Int).Add(b.Reserve1, big.NewInt(1))}
+	if c.GetPriceRat().Cmp(b.GetPriceRat()) <= 0 {
+		t.Errorf("Expected a higher price, have %v and %v", c.GetPriceRat(), b.GetPriceRat())
+	}
+	empty := &UniswapV2State{Reserve0: new(big.Int), Reserve1: big.NewInt(1)}
+	if price := empty.GetPriceRat(); price.Sign() != 0 {
+		t.Errorf("Expected zero price for an empty pool, got %v", price)
+	}
+}
+
+func TestUniswapV2QuotedPrice(t *testing.T) {
+	var (
+		weth = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+		usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		pool = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	)
+	// 10 WETH against 20000 USDC (raw units)
+	state := &UniswapV2State{Token0: weth, Token1: usdc, Reserve0: big.NewInt(10), Reserve1: big.NewInt(20000)}
+	if price, err := state.QuotedPrice(usdc); err != nil || price.Cmp(big.NewFloat(2000)) != 0 {
+		t.Errorf("Expected WETH at 2000 USDC, got %v (err %v)", price, err)
+	}
+	if price, err := state.QuotedPrice(weth); err != nil || price.Cmp(state.GetInversePrice()) != 0 {
+		t.Errorf("Expected USDC at 0.0005 WETH, got %v (err %v)", price, err)
+	}
+	if _, err := state.QuotedPrice(pool); !errors.Is(err, ErrNotPoolToken) {
+		t.Errorf("Expected ErrNotPoolToken, got %v", err)
+	}
+
+	// The registered orientation applies to cached reads
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.current.Store(&Snapshot{Contracts: map[common.Address]*ContractState{
+		pool: {Address: pool, Type: ContractTypeUniswapV2, Decoded: state},
+	}})
+	if price, err := cache.GetQuotedPrice(pool); err != nil || price.Cmp(big.NewFloat(2000)) != 0 {
+		t.Errorf("Expected price in token1 by default, got %v (err %v)", price, err)
+	}
+	cache.SetQuoteToken(pool, weth)
+	if price, err := cache.GetQuotedPrice(pool); err != nil || price.Cmp(state.GetInversePrice()) != 0 {
+		t.Errorf("Expected price in WETH, got %v (err %v)", price, err)
+	}
+	cache.SetQuoteToken(pool, pool)
+	if _, err := cache.GetQuotedPrice(pool); !errors.Is(err, ErrNotPoolToken) {
+		t.Errorf("Expected ErrNotPoolToken for a foreign quote token, got %v", err)
+	}
+	cache.SetQuoteToken(pool, common.Address{})
+	if _, ok := cache.QuoteToken(pool); ok {
+		t.Error("Expected the quote token registration to be removed")
+	}
+}
@@ -0,0 +1,426 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Uniswap V3 storage layout:
+// slot 0: slot0 - packed, from the least significant bit:
+//         sqrtPriceX96 (uint160), tick (int24), observationIndex (uint16),
+//         observationCardinality (uint16), observationCardinalityNext (uint16),
+//         feeProtocol (uint8), unlocked (bool)
+// slot 1: feeGrowthGlobal0X128 (uint256)
+// slot 2: feeGrowthGlobal1X128 (uint256)
+// slot 3: protocolFees (uint128, uint128) - packed
+// slot 4: liquidity (uint128)
+// slot 5: ticks (mapping(int24 => Tick.Info))
+// slot 6: tickBitmap (mapping(int16 => uint256))
+//
+// The first slot of a Tick.Info packs liquidityGross (uint128) in the low and
+// liquidityNet (int128) in the high half. Mapping keys are sign-extended to 32
+// bytes, so the entry of tick t lives at keccak256(int256(t) . pad32(5)).
+// tickBitmap is keyed by the word position of the compressed tick
+// (tick / tickSpacing, rounded towards negative infinity) >> 8.
+//
+// token0, token1, fee and tickSpacing are immutables and live in the bytecode,
+// not in storage. The compiler inlines each immutable as a PUSH32 operand at
+// every place it is read, and those offsets depend on the compiler version and
+// settings, so they cannot be located reliably from the code alone. Instead
+// they are passed to the decoder, see NewUniswapV3Decoder. They can be looked
+// up once from the pool's token0(), token1() and fee() getters.
+
+var (
+	// Standard storage slots for Uniswap V3
+	uniswapV3SlotSlot0      = common.BigToHash(big.NewInt(0))
+	uniswapV3SlotFeeGrowth0 = common.BigToHash(big.NewInt(1))
+	uniswapV3SlotFeeGrowth1 = common.BigToHash(big.NewInt(2))
+	uniswapV3SlotLiquidity  = common.BigToHash(big.NewInt(4))
+	uniswapV3SlotTicks      = common.BigToHash(big.NewInt(5))
+	uniswapV3SlotTickBitmap = common.BigToHash(big.NewInt(6))
+	uniswapV3Q96            = new(big.Int).Lsh(big.NewInt(1), 96)
+	uniswapV3Mask160        = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+	uniswapV3Mask128        = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	uniswapV3PricePrecision = uint(256)
+)
+
+// Tick bounds of Uniswap V3 (TickMath.MIN_TICK and MAX_TICK)
+const (
+	uniswapV3MinTick = -887272
+	uniswapV3MaxTick = 887272
+)
+
+//go:generate go run github.com/fjl/gencodec -type UniswapV3State -field-override uniswapV3StateMarshaling -out gen_uniswap_v3_json.go
+
+// UniswapV3State represents the decoded state of a Uniswap V3 pool.
+type UniswapV3State struct {
+	// Immutables configured on the decoder, zero if not configured
+	Token0 common.Address `json:"token0"`
+	Token1 common.Address `json:"token1"`
+	Fee    uint32         `json:"fee"` // uint24, in hundredths of a basis point
+
+	SqrtPriceX96               *big.Int `json:"sqrtPriceX96"` // uint160
+	Tick                       int32    `json:"tick"`         // int24
+	ObservationIndex           uint16   `json:"observationIndex"`
+	ObservationCardinality     uint16   `json:"observationCardinality"`
+	ObservationCardinalityNext uint16   `json:"observationCardinalityNext"`
+	FeeProtocol                uint8    `json:"feeProtocol"`
+	Unlocked                   bool     `json:"unlocked"`
+	Liquidity                  *big.Int `json:"liquidity"` // uint128
+	FeeGrowthGlobal0X128       *big.Int `json:"feeGrowthGlobal0X128"`
+	FeeGrowthGlobal1X128       *big.Int `json:"feeGrowthGlobal1X128"`
+
+	// Ticks within [TickLower, TickUpper] around the current tick, only read
+	// if the decoder was configured with a TickRange. Ticks holds initialized
+	// ticks only, TickBitmap the bitmap words covering the range.
+	TickLower  int32                    `json:"tickLower,omitempty"`
+	TickUpper  int32                    `json:"tickUpper,omitempty"`
+	Ticks      map[int32]*UniswapV3Tick `json:"ticks,omitempty"`
+	TickBitmap map[int16]*big.Int       `json:"tickBitmap,omitempty"`
+}
+
+// uniswapV3StateMarshaling renders big integers as decimal strings and bitmap
+// words as hex.
+type uniswapV3StateMarshaling struct {
+	SqrtPriceX96         *math.Decimal256
+	Liquidity            *math.Decimal256
+	FeeGrowthGlobal0X128 *math.Decimal256
+	FeeGrowthGlobal1X128 *math.Decimal256
+	TickBitmap           map[int16]*math.HexOrDecimal256
+}
+
+//go:generate go run github.com/fjl/gencodec -type UniswapV3Tick -field-override uniswapV3TickMarshaling -out gen_uniswap_v3_tick_json.go
+
+// UniswapV3Tick holds the liquidity of an initialized Uniswap V3 tick.
+type UniswapV3Tick struct {
+	LiquidityGross *big.Int `json:"liquidityGross"` // uint128
+	LiquidityNet   *big.Int `json:"liquidityNet"`   // int128, added when crossing upwards
+}
+
+// uniswapV3TickMarshaling renders big integers as decimal strings.
+type uniswapV3TickMarshaling struct {
+	LiquidityGross *math.Decimal256
+	LiquidityNet   *math.Decimal256
+}
+
+// String returns a human-readable representation of the pool state.
+func (s *UniswapV3State) String() string {
+	return fmt.Sprintf("UniswapV3{sqrtPriceX96: %s, tick: %d, liquidity: %s}",
+		s.SqrtPriceX96.String(), s.Tick, s.Liquidity.String())
+}
+
+// Copy returns a deep copy of the pool state.
+func (s *UniswapV3State) Copy() *UniswapV3State {
+	cpy := *s
+	cpy.SqrtPriceX96 = copyBig(s.SqrtPriceX96)
+	cpy.Liquidity = copyBig(s.Liquidity)
+	cpy.FeeGrowthGlobal0X128 = copyBig(s.FeeGrowthGlobal0X128)
+	cpy.FeeGrowthGlobal1X128 = copyBig(s.FeeGrowthGlobal1X128)
+	if s.Ticks != nil {
+		cpy.Ticks = make(map[int32]*UniswapV3Tick, len(s.Ticks))
+		for tick, info := range s.Ticks {
+			cpy.Ticks[tick] = &UniswapV3Tick{
+				LiquidityGross: copyBig(info.LiquidityGross),
+				LiquidityNet:   copyBig(info.LiquidityNet),
+			}
+		}
+	}
+	if s.TickBitmap != nil {
+		cpy.TickBitmap = make(map[int16]*big.Int, len(s.TickBitmap))
+		for pos, word := range s.TickBitmap {
+			cpy.TickBitmap[pos] = copyBig(word)
+		}
+	}
+	return &cpy
+}
+
+// LiquidityAtTick returns the active liquidity the pool would have if the
+// price moved to the given tick, i.e. the current liquidity adjusted by the
+// liquidityNet of every initialized tick crossed on the way. Returns nil if
+// the tick lies outside the range read by the decoder.
+func (s *UniswapV3State) LiquidityAtTick(tick int32) *big.Int {
+	if tick == s.Tick {
+		return new(big.Int).Set(s.Liquidity)
+	}
+	if s.Ticks == nil || tick < s.TickLower || tick > s.TickUpper {
+		return nil
+	}
+	liquidity := new(big.Int).Set(s.Liquidity)
+	for t, info := range s.Ticks {
+		switch {
+		case tick > s.Tick && t > s.Tick && t <= tick:
+			// Crossed upwards
+			liquidity.Add(liquidity, info.LiquidityNet)
+		case tick < s.Tick && t > tick && t <= s.Tick:
+			// Crossed downwards
+			liquidity.Sub(liquidity, info.LiquidityNet)
+		}
+	}
+	return liquidity
+}
+
+// UniswapV3Decoder decodes Uniswap V3 pool state from raw storage slots.
+type UniswapV3Decoder struct {
+	// TickRange, if non-zero, additionally reads the ticks and tick bitmap
+	// words within TickRange ticks of the current tick, enabling
+	// UniswapV3State.LiquidityAtTick.
+	TickRange int32
+
+	// TickSpacing is the tick spacing of the pool, an immutable that is not
+	// stored in the pool's storage. Only initialized ticks, which are
+	// multiples of the spacing, are read. The zero value means 1.
+	TickSpacing int32
+
+	// Token0, Token1 and Fee are immutables of the pool, copied into every
+	// decoded state as they cannot be read from storage.
+	Token0 common.Address
+	Token1 common.Address
+	Fee    uint32
+}
+
+// uniswapV3TickSpacings maps the fee tiers enabled by the Uniswap V3 factory to
+// their tick spacings.
+var uniswapV3TickSpacings = map[uint32]int32{
+	100:   1,
+	500:   10,
+	3000:  60,
+	10000: 200,
+}
+
+// NewUniswapV3Decoder returns a decoder for a Uniswap V3 pool with the given
+// immutables. The tick spacing is set for the standard fee tiers and must be
+// set explicitly for other fees if TickRange is used.
+func NewUniswapV3Decoder(token0, token1 common.Address, fee uint32) *UniswapV3Decoder {
+	return &UniswapV3Decoder{
+		Token0:      token0,
+		Token1:      token1,
+		Fee:         fee,
+		TickSpacing: uniswapV3TickSpacings[fee],
+	}
+}
+
+// Type returns the contract type.
+func (d *UniswapV3Decoder) Type() ContractType {
+	return ContractTypeUniswapV3
+}
+
+// Stateless reports that the decoder can be shared between pools.
+func (d *UniswapV3Decoder) Stateless() bool {
+	return true
+}
+
+// RequiredSlots returns the storage slots needed for decoding.
+func (d *UniswapV3Decoder) RequiredSlots() []common.Hash {
+	return []common.Hash{
+		uniswapV3SlotSlot0,
+		uniswapV3SlotFeeGrowth0,
+		uniswapV3SlotFeeGrowth1,
+		uniswapV3SlotLiquidity,
+	}
+}
+
+// Decode decodes raw storage slots into UniswapV3State.
+func (d *UniswapV3Decoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	state := &UniswapV3State{
+		Token0:               d.Token0,
+		Token1:               d.Token1,
+		Fee:                  d.Fee,
+		SqrtPriceX96:         new(big.Int),
+		Liquidity:            new(big.Int),
+		FeeGrowthGlobal0X128: new(big.Int),
+		FeeGrowthGlobal1X128: new(big.Int),
+	}
+
+	// Decode slot0 (slot 0)
+	slot0Value, ok := slots[uniswapV3SlotSlot0]
+	if !ok {
+		return nil, fmt.Errorf("%w: slot0", ErrMissingSlot)
+	}
+	decodeUniswapV3Slot0(slot0Value, state)
+
+	// Decode liquidity (slot 4) - uint128 in the low half of the slot
+	liquidityValue, ok := slots[uniswapV3SlotLiquidity]
+	if !ok {
+		return nil, fmt.Errorf("%w: liquidity", ErrMissingSlot)
+	}
+	state.Liquidity.And(liquidityValue.Big(), uniswapV3Mask128)
+
+	// Decode feeGrowthGlobal0X128 (slot 1)
+	if feeGrowth0Value, ok := slots[uniswapV3SlotFeeGrowth0]; ok {
+		state.FeeGrowthGlobal0X128.SetBytes(feeGrowth0Value[:])
+	}
+
+	// Decode feeGrowthGlobal1X128 (slot 2)
+	if feeGrowth1Value, ok := slots[uniswapV3SlotFeeGrowth1]; ok {
+		state.FeeGrowthGlobal1X128.SetBytes(feeGrowth1Value[:])
+	}
+
+	if d.TickRange > 0 {
+		if err := d.decodeTicks(slots, state); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// RequiredSlotsFor returns the tick and tick bitmap slots within TickRange of
+// the current tick read from slot0, or nothing if no TickRange is configured.
+func (d *UniswapV3Decoder) RequiredSlotsFor(slots map[common.Hash]common.Hash) []common.Hash {
+	slot0, ok := slots[uniswapV3SlotSlot0]
+	if d.TickRange <= 0 || !ok {
+		return nil
+	}
+	var state UniswapV3State
+	state.SqrtPriceX96 = new(big.Int)
+	decodeUniswapV3Slot0(slot0, &state)
+
+	lower, upper := d.tickWindow(state.Tick)
+	var required []common.Hash
+	for tick := lower; tick <= upper; tick += d.tickSpacing() {
+		required = append(required, uniswapV3TickSlot(tick))
+	}
+	first, last := d.bitmapWords(lower, upper)
+	for pos := int32(first); pos <= int32(last); pos++ {
+		required = append(required, uniswapV3BitmapSlot(int16(pos)))
+	}
+	return required
+}
+
+// decodeTicks decodes the ticks and bitmap words around the current tick.
+func (d *UniswapV3Decoder) decodeTicks(slots map[common.Hash]common.Hash, state *UniswapV3State) error {
+	state.TickLower = max(state.Tick-d.TickRange, uniswapV3MinTick)
+	state.TickUpper = min(state.Tick+d.TickRange, uniswapV3MaxTick)
+	state.Ticks = make(map[int32]*UniswapV3Tick)
+	state.TickBitmap = make(map[int16]*big.Int)
+
+	lower, upper := d.tickWindow(state.Tick)
+	for tick := lower; tick <= upper; tick += d.tickSpacing() {
+		value, ok := slots[uniswapV3TickSlot(tick)]
+		if !ok {
+			return fmt.Errorf("%w: tick %d", ErrMissingSlot, tick)
+		}
+		full := value.Big()
+		gross := new(big.Int).And(full, uniswapV3Mask128)
+		if gross.Sign() == 0 {
+			continue // not initialized
+		}
+		net := full.Rsh(full, 128)
+		if net.Bit(127) == 1 {
+			net.Sub(net, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+		state.Ticks[tick] = &UniswapV3Tick{LiquidityGross: gross, LiquidityNet: net}
+	}
+	first, last := d.bitmapWords(lower, upper)
+	for pos := int32(first); pos <= int32(last); pos++ {
+		value, ok := slots[uniswapV3BitmapSlot(int16(pos))]
+		if !ok {
+			return fmt.Errorf("%w: tick bitmap word %d", ErrMissingSlot, pos)
+		}
+		state.TickBitmap[int16(pos)] = value.Big()
+	}
+	return nil
+}
+
+// tickSpacing returns the configured tick spacing, defaulting to 1.
+func (d *UniswapV3Decoder) tickSpacing() int32 {
+	return max(d.TickSpacing, 1)
+}
+
+// tickWindow returns the first and last multiple of the tick spacing within
+// TickRange of the given tick, clamped to the valid tick range.
+func (d *UniswapV3Decoder) tickWindow(tick int32) (lower, upper int32) {
+	spacing := d.tickSpacing()
+	lower = max(tick-d.TickRange, uniswapV3MinTick)
+	upper = min(tick+d.TickRange, uniswapV3MaxTick)
+	return -floorDiv(-lower, spacing) * spacing, floorDiv(upper, spacing) * spacing
+}
+
+// bitmapWords returns the positions of the first and last tick bitmap words
+// covering the given ticks, which must be multiples of the tick spacing.
+func (d *UniswapV3Decoder) bitmapWords(lower, upper int32) (first, last int16) {
+	spacing := d.tickSpacing()
+	return int16((lower / spacing) >> 8), int16((upper / spacing) >> 8)
+}
+
+// floorDiv divides a by the positive b, rounding towards negative infinity
+// like TickBitmap's tick compression.
+func floorDiv(a, b int32) int32 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// uniswapV3TickSlot returns the storage slot of the first word of the
+// Tick.Info of the given tick.
+func uniswapV3TickSlot(tick int32) common.Hash {
+	return MappingSlot(math.U256Bytes(big.NewInt(int64(tick))), uniswapV3SlotTicks)
+}
+
+// uniswapV3BitmapSlot returns the storage slot of a tick bitmap word.
+func uniswapV3BitmapSlot(pos int16) common.Hash {
+	return MappingSlot(math.U256Bytes(big.NewInt(int64(pos))), uniswapV3SlotTickBitmap)
+}
+
+// decodeUniswapV3Slot0 unpacks the slot0 struct into the given state.
+func decodeUniswapV3Slot0(value common.Hash, state *UniswapV3State) {
+	full := value.Big()
+	state.SqrtPriceX96.And(full, uniswapV3Mask160)
+
+	// The remaining fields live in the top 96 bits and are byte-aligned.
+	// Byte offsets (big-endian, byte 31 is least significant):
+	//   bytes 9..11  tick (int24)
+	//   bytes 7..8   observationIndex
+	//   bytes 5..6   observationCardinality
+	//   bytes 3..4   observationCardinalityNext
+	//   byte  2      feeProtocol
+	//   byte  1      unlocked
+	rawTick := uint32(value[9])<<16 | uint32(value[10])<<8 | uint32(value[11])
+	state.Tick = int32(rawTick<<8) >> 8 // sign-extend the 24-bit value
+	state.ObservationIndex = uint16(value[7])<<8 | uint16(value[8])
+	state.ObservationCardinality = uint16(value[5])<<8 | uint16(value[6])
+	state.ObservationCardinalityNext = uint16(value[3])<<8 | uint16(value[4])
+	state.FeeProtocol = value[2]
+	state.Unlocked = value[1] != 0
+}
+
+// GetPrice returns the current price of token0 in terms of token1.
+// Price = (sqrtPriceX96 / 2^96)^2
+func (s *UniswapV3State) GetPrice() *big.Float {
+	if s.SqrtPriceX96.Sign() == 0 {
+		return big.NewFloat(0)
+	}
+	sqrtPrice := new(big.Float).SetPrec(uniswapV3PricePrecision).SetInt(s.SqrtPriceX96)
+	sqrtPrice.Quo(sqrtPrice, new(big.Float).SetPrec(uniswapV3PricePrecision).SetInt(uniswapV3Q96))
+	return sqrtPrice.Mul(sqrtPrice, sqrtPrice)
+}
+
+// GetInversePrice returns the price of token1 in terms of token0.
+// InversePrice = (2^96 / sqrtPriceX96)^2
+func (s *UniswapV3State) GetInversePrice() *big.Float {
+	if s.SqrtPriceX96.Sign() == 0 {
+		return big.NewFloat(0)
+	}
+	price := s.GetPrice()
+	return new(big.Float).SetPrec(uniswapV3PricePrecision).Quo(big.NewFloat(1), price)
+}
@@ -0,0 +1,297 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Packed slot0 values for a USDC/WETH pool (positive tick, price ~2000 USDC/ETH)
+// and a WETH/USDT pool (negative tick, same price with token order flipped).
+var (
+	uniswapV3Slot0Positive = common.HexToHash("0x00010002d302d3007b030e770000000000005758ae05bbf89b1e32f83635685c")
+	uniswapV3Slot0Negative = common.HexToHash("0x00014405a005a00023fcf18800000000000000000002ee4cc6d5cdceff58c1f1")
+)
+
+func TestUniswapV3Decoder(t *testing.T) {
+	decoder := &UniswapV3Decoder{}
+
+	if decoder.Type() != ContractTypeUniswapV3 {
+		t.Errorf("Expected contract type %v, got %v", ContractTypeUniswapV3, decoder.Type())
+	}
+
+	slots := decoder.RequiredSlots()
+	if len(slots) != 4 {
+		t.Errorf("Expected 4 required slots, got %d", len(slots))
+	}
+}
+
+func TestUniswapV3Decode(t *testing.T) {
+	tests := []struct {
+		name        string
+		slot0       common.Hash
+		sqrtPrice   string
+		tick        int32
+		obsIndex    uint16
+		cardinality uint16
+		feeProtocol uint8
+	}{
+		{"positive tick", uniswapV3Slot0Positive, "1771595571142957102961017161607260", 200311, 123, 723, 0},
+		{"negative tick", uniswapV3Slot0Negative, "3543191142285914316259825", -200312, 35, 1440, 0x44},
+	}
+	decoder := &UniswapV3Decoder{}
+	liquidity := big.NewInt(12345678901234567)
+
+	for _, tt := range tests {
+		slots := map[common.Hash]common.Hash{
+			uniswapV3SlotSlot0:      tt.slot0,
+			uniswapV3SlotFeeGrowth0: common.BigToHash(big.NewInt(1111)),
+			uniswapV3SlotFeeGrowth1: common.BigToHash(big.NewInt(2222)),
+			uniswapV3SlotLiquidity:  common.BigToHash(liquidity),
+		}
+		decoded, err := decoder.Decode(slots)
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %v", tt.name, err)
+		}
+		state, ok := decoded.(*UniswapV3State)
+		if !ok {
+			t.Fatalf("%s: decoded value is not UniswapV3State", tt.name)
+		}
+		if state.SqrtPriceX96.String() != tt.sqrtPrice {
+			t.Errorf("%s: expected sqrtPriceX96 %s, got %s", tt.name, tt.sqrtPrice, state.SqrtPriceX96)
+		}
+		if state.Tick != tt.tick {
+			t.Errorf("%s: expected tick %d, got %d", tt.name, tt.tick, state.Tick)
+		}
+		if state.ObservationIndex != tt.obsIndex {
+			t.Errorf("%s: expected observation index %d, got %d", tt.name, tt.obsIndex, state.ObservationIndex)
+		}
+		if state.ObservationCardinality != tt.cardinality || state.ObservationCardinalityNext != tt.cardinality {
+			t.Errorf("%s: expected cardinality %d, got %d/%d", tt.name, tt.cardinality,
+				state.ObservationCardinality, state.ObservationCardinalityNext)
+		}
+		if state.FeeProtocol != tt.feeProtocol {
+			t.Errorf("%s: expected fee protocol %d, got %d", tt.name, tt.feeProtocol, state.FeeProtocol)
+		}
+		if !state.Unlocked {
+			t.Errorf("%s: expected pool to be unlocked", tt.name)
+		}
+		if state.Liquidity.Cmp(liquidity) != 0 {
+			t.Errorf("%s: expected liquidity %s, got %s", tt.name, liquidity, state.Liquidity)
+		}
+		if state.FeeGrowthGlobal0X128.Int64() != 1111 || state.FeeGrowthGlobal1X128.Int64() != 2222 {
+			t.Errorf("%s: unexpected fee growth %s/%s", tt.name, state.FeeGrowthGlobal0X128, state.FeeGrowthGlobal1X128)
+		}
+	}
+}
+
+func TestUniswapV3DecoderImmutables(t *testing.T) {
+	var (
+		usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		weth = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	)
+	decoder := NewUniswapV3Decoder(usdc, weth, 500)
+	if decoder.TickSpacing != 10 {
+		t.Errorf("Expected tick spacing 10 for the 0.05%% tier, got %d", decoder.TickSpacing)
+	}
+	if spacing := NewUniswapV3Decoder(usdc, weth, 1234).TickSpacing; spacing != 0 {
+		t.Errorf("Expected no tick spacing for a non-standard fee, got %d", spacing)
+	}
+	decoded, err := decoder.Decode(map[common.Hash]common.Hash{
+		uniswapV3SlotSlot0:     uniswapV3Slot0Positive,
+		uniswapV3SlotLiquidity: common.BigToHash(big.NewInt(1)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	state := decoded.(*UniswapV3State)
+	if state.Token0 != usdc || state.Token1 != weth || state.Fee != 500 {
+		t.Errorf("Unexpected immutables %s/%s fee %d", state.Token0.Hex(), state.Token1.Hex(), state.Fee)
+	}
+
+	// The immutables survive a JSON round trip
+	enc, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var dec UniswapV3State
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if dec.Token0 != usdc || dec.Token1 != weth || dec.Fee != 500 {
+		t.Errorf("Immutables lost in JSON round trip: %s", enc)
+	}
+}
+
+func TestUniswapV3DecodeMissingSlots(t *testing.T) {
+	decoder := &UniswapV3Decoder{}
+
+	if _, err := decoder.Decode(map[common.Hash]common.Hash{
+		uniswapV3SlotLiquidity: common.BigToHash(big.NewInt(1)),
+	}); !errors.Is(err, ErrMissingSlot) {
+		t.Errorf("Expected ErrMissingSlot for missing slot0, got %v", err)
+	}
+	if _, err := decoder.Decode(map[common.Hash]common.Hash{
+		uniswapV3SlotSlot0: uniswapV3Slot0Positive,
+	}); !errors.Is(err, ErrMissingSlot) {
+		t.Errorf("Expected ErrMissingSlot for missing liquidity, got %v", err)
+	}
+}
+
+func TestUniswapV3GetPrice(t *testing.T) {
+	decoder := &UniswapV3Decoder{}
+	decoded, err := decoder.Decode(map[common.Hash]common.Hash{
+		uniswapV3SlotSlot0:     uniswapV3Slot0Positive,
+		uniswapV3SlotLiquidity: common.BigToHash(big.NewInt(1)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	state := decoded.(*UniswapV3State)
+
+	// Raw price is 5e8 (WETH wei per USDC unit), i.e. 2000 USDC/ETH after decimals
+	price, _ := state.GetPrice().Float64()
+	if price < 4.999e8 || price > 5.001e8 {
+		t.Errorf("Expected price ~5e8, got %v", price)
+	}
+	inverse, _ := state.GetInversePrice().Float64()
+	if inverse < 1.999e-9 || inverse > 2.001e-9 {
+		t.Errorf("Expected inverse price ~2e-9, got %v", inverse)
+	}
+
+	zero := &UniswapV3State{SqrtPriceX96: new(big.Int), Liquidity: new(big.Int)}
+	if zero.GetPrice().Sign() != 0 || zero.GetInversePrice().Sign() != 0 {
+		t.Error("Expected zero price for uninitialized pool")
+	}
+}
+
+func TestUniswapV3TickSlots(t *testing.T) {
+	// Mapping keys are sign-extended to 32 bytes
+	slot5 := common.BigToHash(big.NewInt(5))
+	positive := append(common.LeftPadBytes([]byte{0x3c}, 32), slot5[:]...)
+	if have, want := uniswapV3TickSlot(60), crypto.Keccak256Hash(positive); have != want {
+		t.Errorf("tick 60: have slot %s, want %s", have.Hex(), want.Hex())
+	}
+	negative := append(bytes.Repeat([]byte{0xff}, 31), 0xc4)
+	negative = append(negative, slot5[:]...)
+	if have, want := uniswapV3TickSlot(-60), crypto.Keccak256Hash(negative); have != want {
+		t.Errorf("tick -60: have slot %s, want %s", have.Hex(), want.Hex())
+	}
+	slot6 := common.BigToHash(big.NewInt(6))
+	word := append(bytes.Repeat([]byte{0xff}, 32), slot6[:]...)
+	if have, want := uniswapV3BitmapSlot(-1), crypto.Keccak256Hash(word); have != want {
+		t.Errorf("bitmap word -1: have slot %s, want %s", have.Hex(), want.Hex())
+	}
+}
+
+// uniswapV3PackedTick packs the first Tick.Info word.
+func uniswapV3PackedTick(gross, net int64) common.Hash {
+	packed := new(big.Int).Lsh(math.U256(big.NewInt(net)), 128)
+	packed = math.U256(packed)
+	return common.BigToHash(packed.Or(packed, big.NewInt(gross)))
+}
+
+func TestUniswapV3LiquidityAtTick(t *testing.T) {
+	decoder := &UniswapV3Decoder{TickRange: 120, TickSpacing: 60}
+
+	// Current tick -30, one position on [-60, 60) and one starting at 60
+	var slot0 common.Hash
+	slot0[9], slot0[10], slot0[11] = 0xff, 0xff, 0xe2
+	slot0[31] = 1
+	slots := map[common.Hash]common.Hash{
+		uniswapV3SlotSlot0:     slot0,
+		uniswapV3SlotLiquidity: common.BigToHash(big.NewInt(1000)),
+	}
+	required := decoder.RequiredSlotsFor(slots)
+	// Ticks -120, -60, 0, 60 and bitmap words -1, 0
+	if len(required) != 6 {
+		t.Fatalf("Expected 6 dependent slots, got %d", len(required))
+	}
+	for _, slot := range required {
+		slots[slot] = common.Hash{}
+	}
+	slots[uniswapV3TickSlot(-60)] = uniswapV3PackedTick(400, 400)
+	slots[uniswapV3TickSlot(60)] = uniswapV3PackedTick(500, -300)
+	slots[uniswapV3BitmapSlot(-1)] = common.BigToHash(new(big.Int).Lsh(big.NewInt(1), 255)) // compressed tick -1
+
+	decoded, err := decoder.Decode(slots)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	state := decoded.(*UniswapV3State)
+	if state.Tick != -30 || len(state.Ticks) != 2 || len(state.TickBitmap) != 2 {
+		t.Fatalf("Unexpected tick window: tick %d, ticks %v, bitmap %v", state.Tick, state.Ticks, state.TickBitmap)
+	}
+	if net := state.Ticks[60].LiquidityNet; net.Int64() != -300 {
+		t.Errorf("Expected negative liquidityNet -300, got %s", net)
+	}
+	if word := state.TickBitmap[-1]; word.Bit(255) != 1 {
+		t.Errorf("Unexpected bitmap word %x", word)
+	}
+	tests := []struct {
+		tick int32
+		want int64
+	}{
+		{-30, 1000},
+		{0, 1000},
+		{60, 700}, // crossing 60 upwards adds its net
+		{90, 700},
+		{-60, 1000}, // at -60 its positions are still active
+		{-61, 600},  // crossing -60 downwards removes its net
+		{-150, 600},
+	}
+	for _, tt := range tests {
+		if liquidity := state.LiquidityAtTick(tt.tick); liquidity == nil || liquidity.Int64() != tt.want {
+			t.Errorf("tick %d: expected liquidity %d, got %v", tt.tick, tt.want, liquidity)
+		}
+	}
+	if liquidity := state.LiquidityAtTick(91); liquidity != nil {
+		t.Errorf("Expected nil liquidity outside the window, got %s", liquidity)
+	}
+
+	// The tick window survives a JSON round trip
+	blob, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	var restored UniswapV3State
+	if err := json.Unmarshal(blob, &restored); err != nil {
+		t.Fatalf("Failed to decode %s: %v", blob, err)
+	}
+	if restored.LiquidityAtTick(-61).Int64() != 600 || restored.TickBitmap[-1].Cmp(state.TickBitmap[-1]) != 0 {
+		t.Errorf("Unexpected restored state %s", blob)
+	}
+
+	// A missing tick slot fails decoding
+	delete(slots, uniswapV3TickSlot(0))
+	if _, err := decoder.Decode(slots); err == nil {
+		t.Error("Expected error for missing tick slot")
+	}
+	// Without a range no ticks are read
+	plain := &UniswapV3Decoder{}
+	if required := plain.RequiredSlotsFor(slots); len(required) != 0 {
+		t.Errorf("Expected no dependent slots without a tick range, got %d", len(required))
+	}
+}
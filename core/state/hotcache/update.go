@@ -17,12 +17,21 @@
 package hotcache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"math/big"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/log"
+	"github.com/holiman/uint256"
 )
 
 // StateReader provides read access to the canonical state.
@@ -31,119 +40,548 @@ type StateReader interface {
 	GetState(addr common.Address, slot common.Hash) common.Hash
 }
 
+// CodeHashReader is a StateReader that also exposes contract code hashes,
+// enabling detection of contract upgrades; see Cache.SetExpectedCodeHash.
+type CodeHashReader interface {
+	StateReader
+	GetCodeHash(addr common.Address) common.Hash
+}
+
+// ExtendedStateReader is a CodeHashReader that also exposes account balances
+// and code, for decoders implementing StateDecoder.
+type ExtendedStateReader interface {
+	CodeHashReader
+	GetBalance(addr common.Address) *uint256.Int
+	GetCode(addr common.Address) []byte
+}
+
+// StateProvider returns a reader of the state with the given root. It lets
+// HandleReorg read every replayed block from that block's own state.
+type StateProvider func(root common.Hash) (StateReader, error)
+
+// BatchStateReader is a StateReader that reads several storage slots of a
+// contract in one call, for backends where batched reads are cheaper than
+// reading slot by slot.
+type BatchStateReader interface {
+	StateReader
+	
+	// GetStates returns the values of the given slots of addr, in order.
+	GetStates(addr common.Address, slots []common.Hash) []common.Hash
+}
+
 // Update updates the cache with state from a newly imported block.
 // This should be called after a block is written to the canonical chain.
 func (c *Cache) Update(block *types.Header, stateDB StateReader) error {
-	if !c.config.Enabled {
+	return c.UpdateIncremental(block, stateDB, nil)
+}
+
+// UpdateIncremental updates the cache with state from a newly imported block,
+// re-reading only the watched contracts whose storage was touched in the block.
+// Contracts absent from changedAddrs carry their state forward from the current
+// snapshot, so changedAddrs must be relative to the block the current snapshot
+// was built from. A nil changedAddrs performs a full update.
+func (c *Cache) UpdateIncremental(block *types.Header, stateDB StateReader, changedAddrs map[common.Address]bool) error {
+	return c.update(context.Background(), block, stateDB, changedAddrs)
+}
+
+// UpdateContext is Update bounded by a context. Once the context is cancelled
+// or its deadline passes, no further contracts are read and ctx.Err() is
+// returned. The contracts read so far are published if PublishPartialUpdates
+// is set, the current snapshot is kept otherwise. A single slow state read is
+// not interrupted.
+func (c *Cache) UpdateContext(ctx context.Context, block *types.Header, stateDB StateReader) error {
+	return c.update(ctx, block, stateDB, nil)
+}
+
+// update implements UpdateIncremental and UpdateContext.
+func (c *Cache) update(ctx context.Context, block *types.Header, stateDB StateReader, changedAddrs map[common.Address]bool) error {
+	if !c.IsEnabled() {
 		return nil
 	}
 	
 	c.stats.Updates.Add(1)
+	if c.metrics != nil {
+		c.metrics.updates.Inc(1)
+	}
+	start := time.Now()
+	defer func() { c.recordUpdateTime(time.Since(start)) }()
+	
+	newSnapshot, stats, err := c.buildSnapshot(ctx, block, stateDB, changedAddrs)
+	c.lastUpdate.Store(&stats)
+	if err != nil {
+		if !c.config.PublishPartialUpdates {
+			c.snapshotMu.Lock()
+			c.recycleSnapshot(newSnapshot)
+			c.snapshotMu.Unlock()
+			c.logger.Warn("Hot cache update aborted", "block", block.Number.Uint64(), "err", err)
+			return err
+		}
+		c.publishSnapshot(newSnapshot)
+		c.logger.Warn("Hot cache update aborted, published partial snapshot",
+			"block", block.Number.Uint64(), "contracts", len(newSnapshot.Contracts), "err", err)
+		return err
+	}
+	contracts := len(newSnapshot.Contracts)
+	if !c.config.PublishOnlyOnChange || !c.retainUnchanged(block, newSnapshot) {
+		c.publishSnapshot(newSnapshot)
+	}
+	
+	// Check the just-published state against the canonical state in shadow
+	// mode. Mismatches are logged and counted but never fail the import.
+	if c.shouldValidate(block.Number.Uint64()) {
+		if err := c.Validate(stateDB); err != nil {
+			c.logger.Error("Hot cache validation failed", "block", block.Number.Uint64(), "err", err)
+		}
+	}
+	
+	c.logger.Debug("Hot cache updated",
+		"block", block.Number.Uint64(),
+		"hash", block.Hash().Hex()[:10],
+		"contracts", contracts)
 	
-	// Create new snapshot
+	return nil
+}
+
+// Warmup performs a one-time full read of every watched contract against the
+// given head state and publishes it as the initial snapshot, so reads are
+// served before the first block is imported. The snapshot is retained as the
+// reorg baseline like any other; unlike Update it is neither counted nor
+// validated.
+func (c *Cache) Warmup(head *types.Header, stateDB StateReader) error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	snapshot, _, _ := c.buildSnapshot(context.Background(), head, stateDB, nil)
+	c.publishSnapshot(snapshot)
+	
+	c.logger.Info("Hot cache warmed up",
+		"block", snapshot.BlockNumber,
+		"hash", snapshot.BlockHash.Hex()[:10],
+		"contracts", len(snapshot.Contracts))
+	return nil
+}
+
+// buildSnapshot reads the watched contracts at the given block. Contracts
+// absent from a non-nil changedAddrs are carried forward from the current
+// snapshot. If the context is done before all contracts were read, the
+// partial snapshot is returned along with the context's error. The work done
+// is counted in the returned stats either way.
+func (c *Cache) buildSnapshot(ctx context.Context, block *types.Header, stateDB StateReader, changedAddrs map[common.Address]bool) (*Snapshot, UpdateStats, error) {
+	stats := UpdateStats{BlockNumber: block.Number.Uint64()}
 	newSnapshot := &Snapshot{
 		BlockNumber: block.Number.Uint64(),
 		BlockHash:   block.Hash(),
 		BlockTime:   block.Time,
-		Contracts:   make(map[common.Address]*ContractState),
+		StateRoot:   block.Root,
+		Contracts:   getContractMap(),
 	}
 	
-	// Update state for each watched contract
-	for addr := range c.watchlist {
+	// Update state for each active watched contract, iterating a copy of
+	// the watchlist so it can be modified concurrently
+	previous := c.GetSnapshot()
+	active := c.activeContracts(c.watchedAddresses())
+	for i, addr := range active {
+		if err := ctx.Err(); err != nil {
+			if c.config.MergePartialUpdates {
+				c.carryForward(newSnapshot, previous, active[i:])
+			}
+			return newSnapshot, stats, err
+		}
+		if changedAddrs != nil && !changedAddrs[addr] {
+			if unchanged, ok := previous.Contracts[addr]; ok {
+				newSnapshot.Contracts[addr] = unchanged
+				stats.Skipped++
+				continue
+			}
+		}
+		start := time.Now()
 		contractState, err := c.updateContract(addr, stateDB)
+		if c.metrics != nil {
+			c.metrics.updateContractTime.Update(time.Since(start).Nanoseconds())
+		}
 		if err != nil {
-			log.Warn("Failed to update contract state",
+			c.logger.Warn("Failed to update contract state",
 				"address", addr,
-				"block", block.Number.Uint64(),
+				"block", newSnapshot.BlockNumber,
 				"err", err)
+			if c.config.MergePartialUpdates {
+				c.carryForward(newSnapshot, previous, []common.Address{addr})
+			}
+			stats.Failed++
 			continue
 		}
+		stats.Updated++
+		if contractState.DecodeError != nil {
+			stats.DecodeFailed++
+			c.trackDecodeFailure(contractState, previous.Contracts[addr], newSnapshot.BlockNumber)
+		}
+		contractState.LastUpdated = newSnapshot.BlockNumber
 		newSnapshot.Contracts[addr] = contractState
+		if significantChange(previous.Contracts[addr], contractState, c.ChangeThreshold(addr)) {
+			if newSnapshot.significant == nil {
+				newSnapshot.significant = make(map[common.Address]bool)
+			}
+			newSnapshot.significant[addr] = true
+		}
 	}
-	
-	// Store snapshot for reorg protection
+	return newSnapshot, stats, nil
+}
+
+// carryForward copies the previous states of the given contracts into a new
+// snapshot, for MergePartialUpdates. Contracts absent from the previous
+// snapshot stay absent.
+func (c *Cache) carryForward(snapshot, previous *Snapshot, addrs []common.Address) {
+	for _, addr := range addrs {
+		if state, ok := previous.Contracts[addr]; ok {
+			snapshot.Contracts[addr] = state
+		}
+	}
+}
+
+// trackDecodeFailure counts a failed decode of a contract on top of the
+// consecutive failures of its previous state, marking it dead once
+// MaxConsecutiveDecodeErrors is reached. The failures of dead contracts are
+// only logged at debug level.
+func (c *Cache) trackDecodeFailure(state, prev *ContractState, number uint64) {
+	limit := c.config.MaxConsecutiveDecodeErrors
+	if limit == 0 {
+		c.logger.Warn("Failed to decode contract state", "address", state.Address, "block", number, "err", state.DecodeError)
+		return
+	}
+	state.decodeFailures = 1
+	if prev != nil {
+		state.decodeFailures = min(prev.decodeFailures+1, limit)
+	}
+	state.Dead = state.decodeFailures >= limit
+	switch {
+	case prev != nil && prev.Dead:
+		c.logger.Debug("Failed to decode dead contract", "address", state.Address, "block", number, "err", state.DecodeError)
+	case state.Dead:
+		c.logger.Warn("Marked contract dead after repeated decode failures",
+			"address", state.Address,
+			"block", number,
+			"failures", state.decodeFailures,
+			"err", state.DecodeError)
+	default:
+		c.logger.Warn("Failed to decode contract state", "address", state.Address, "block", number, "err", state.DecodeError)
+	}
+}
+
+// publishSnapshot retains a snapshot for reorg protection and makes it the
+// current one.
+func (c *Cache) publishSnapshot(snapshot *Snapshot) {
 	c.snapshotMu.Lock()
-	c.snapshots[block.Hash()] = newSnapshot
-	c.cleanupOldSnapshots(block.Number.Uint64())
-	c.snapshotMu.Unlock()
+	// A cache disabled by failed validations must not serve state again
+	if !c.IsEnabled() {
+		c.snapshotMu.Unlock()
+		return
+	}
+	// Without reorg protection only the current snapshot is kept. The one it
+	// replaces is left to the garbage collector rather than recycled, as
+	// lock-free readers may still be reading it.
+	var evicted *Snapshot
+	if c.snapshots != nil {
+		evicted = c.snapshots.add(snapshot)
+	}
+	retained := c.snapshots.len()
 	
 	// Atomic update of current snapshot (lock-free for readers)
-	c.current.Store(newSnapshot)
+	c.current.Store(snapshot)
+	c.headNumber.Store(snapshot.BlockNumber)
+	if evicted != nil {
+		c.logger.Trace("Evicted snapshot", "block", evicted.BlockNumber, "hash", evicted.BlockHash)
+		c.recycleSnapshot(evicted)
+	}
+	c.snapshotMu.Unlock()
 	
-	log.Debug("Hot cache updated",
-		"block", block.Number.Uint64(),
-		"hash", block.Hash().Hex()[:10],
-		"contracts", len(newSnapshot.Contracts))
+	if c.metrics != nil {
+		c.metrics.snapshots.Update(int64(retained))
+		c.metrics.block.Update(int64(snapshot.BlockNumber))
+	}
+	c.poolMetrics.update(snapshot)
+	c.notifySubscribers(snapshot)
+}
+
+// retainUnchanged retains the current snapshot for the given block instead of
+// publishing the newly built one if no watched contract changed, discarding
+// the new snapshot. It reports whether the current snapshot was retained.
+func (c *Cache) retainUnchanged(block *types.Header, snapshot *Snapshot) bool {
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
 	
-	return nil
+	current := c.current.Load()
+	if !c.IsEnabled() || current.BlockHash == (common.Hash{}) || !sameContracts(current, snapshot) {
+		return false
+	}
+	if c.snapshots != nil {
+		if evicted := c.snapshots.addAt(block.Hash(), current); evicted != nil {
+			c.logger.Trace("Evicted snapshot", "block", evicted.BlockNumber, "hash", evicted.BlockHash)
+			c.recycleSnapshot(evicted)
+		}
+	}
+	c.recycleSnapshot(snapshot)
+	c.headNumber.Store(block.Number.Uint64())
+	
+	if c.metrics != nil {
+		c.metrics.snapshots.Update(int64(c.snapshots.len()))
+		c.metrics.block.Update(block.Number.Int64())
+	}
+	return true
 }
 
-// updateContract reads and decodes state for a single contract.
-func (c *Cache) updateContract(addr common.Address, stateDB StateReader) (*ContractState, error) {
+// sameContracts reports whether two snapshots hold the same state for the
+// same contracts, disregarding block metadata and LastUpdated.
+func sameContracts(a, b *Snapshot) bool {
+	if len(a.Contracts) != len(b.Contracts) {
+		return false
+	}
+	for addr, state := range b.Contracts {
+		prev, ok := a.Contracts[addr]
+		if !ok {
+			return false
+		}
+		if prev == state {
+			continue
+		}
+		if prev.Type != state.Type || prev.Suspect != state.Suspect ||
+			prev.Dead != state.Dead || prev.decodeFailures != state.decodeFailures ||
+			(prev.DecodeError == nil) != (state.DecodeError == nil) ||
+			!maps.Equal(prev.rawSlots, state.rawSlots) || !equalBig(prev.NativeBalance, state.NativeBalance) {
+			return false
+		}
+		// Decoded states derive from the raw slots but may also depend on
+		// registrations such as token decimals. Deep equality can report
+		// equal values as different, which merely publishes a snapshot.
+		if !reflect.DeepEqual(prev.Decoded, state.Decoded) {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldValidate reports whether Update should validate the snapshot built
+// for the given block.
+func (c *Cache) shouldValidate(number uint64) bool {
+	if !c.config.ShadowMode {
+		return false
+	}
+	return c.config.ValidateEveryN <= 1 || number%c.config.ValidateEveryN == 0
+}
+
+// updateContract reads and decodes state for a single contract. A panic while
+// reading or decoding, e.g. in a third-party decoder, is returned as an error
+// so a single broken contract cannot abort block import.
+func (c *Cache) updateContract(addr common.Address, stateDB StateReader) (state *ContractState, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			state, err = nil, fmt.Errorf("panic while updating contract: %v", r)
+		}
+	}()
 	contractState := &ContractState{
 		Address:  addr,
 		Type:     ContractTypeUnknown,
-		RawSlots: make(map[common.Hash]common.Hash),
+		rawSlots: getSlotMap(),
 	}
 	
 	// Get decoder if available
+	decoder, hasDecoder := c.lookupDecoder(addr, stateDB)
 	c.decoderMu.RLock()
-	decoder, hasDecoder := c.decoders[addr]
+	customSlots := c.customSlots[addr]
+	expectedCode, checkCode := c.codeHashes[addr]
 	c.decoderMu.RUnlock()
 	
+	storageAddr := addr
+	if external, ok := decoder.(ExternalStorageDecoder); ok {
+		storageAddr = external.StorageAddress()
+	}
+	
+	// Read custom slots regardless of whether the contract can be decoded.
+	// They belong to the contract itself, even if its decoder reads its own
+	// slots from another contract.
+	readSlots(stateDB, addr, customSlots, contractState.rawSlots)
+	
 	if hasDecoder {
 		contractState.Type = decoder.Type()
 		
 		// Read required slots
-		slots := decoder.RequiredSlots()
-		for _, slot := range slots {
-			value := stateDB.GetState(addr, slot)
-			contractState.RawSlots[slot] = value
+		readDecoderSlots(decoder, storageAddr, stateDB, contractState.rawSlots, !c.config.SkipOptionalSlots)
+		
+		// Skip decoding if the contract was upgraded since the decoder was
+		// registered, the layout may no longer match
+		if checkCode {
+			if reader, ok := stateDB.(CodeHashReader); ok {
+				if code := reader.GetCodeHash(addr); code != expectedCode {
+					contractState.Suspect = true
+					contractState.DecodeError = fmt.Errorf("%w: expected %s, have %s", ErrCodeHashMismatch, expectedCode.Hex(), code.Hex())
+					return contractState, nil
+				}
+			}
 		}
 		
 		// Decode to structured format
-		decoded, err := decoder.Decode(contractState.RawSlots)
+		decoded, balance, err := runDecoder(addr, decoder, stateDB, contractState.rawSlots)
+		contractState.NativeBalance = balance
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode %s: %w", decoder.Type(), err)
+			// Publish the raw slots regardless so the contract stays readable
+			contractState.DecodeError = fmt.Errorf("failed to decode %s: %w", decoder.Type(), err)
+			return contractState, nil
+		}
+		if state, ok := decoded.(tokenDecimalsState); ok {
+			state.setTokenDecimals(c.lookupTokenDecimals)
 		}
 		contractState.Decoded = decoded
 		
-		log.Trace("Contract state decoded",
+		c.logger.Trace("Contract state decoded",
 			"address", addr,
 			"type", decoder.Type(),
-			"slots", len(contractState.RawSlots))
+			"slots", len(contractState.rawSlots))
 	}
 	
 	return contractState, nil
 }
 
+// readDecoderSlots reads every slot the decoder needs into rawSlots, taking
+// its own slots from storageAddr. The decoder's optional slots are only read
+// if optional is set.
+func readDecoderSlots(decoder ContractDecoder, storageAddr common.Address, stateDB StateReader, rawSlots map[common.Hash]common.Hash, optional bool) {
+	readSlots(stateDB, storageAddr, decoder.RequiredSlots(), rawSlots)
+	if decoder, ok := decoder.(OptionalSlotDecoder); ok && optional {
+		readSlots(stateDB, storageAddr, decoder.OptionalSlots(), rawSlots)
+	}
+	if dependent, ok := decoder.(DependentSlotDecoder); ok {
+		readSlots(stateDB, storageAddr, dependent.RequiredSlotsFor(rawSlots), rawSlots)
+	}
+	if foreign, ok := decoder.(ForeignSlotDecoder); ok {
+		for slot, owner := range foreign.ForeignSlots() {
+			rawSlots[slot] = stateDB.GetState(owner, slot)
+		}
+	}
+}
+
+// readSlots reads the given storage slots of addr into rawSlots, in a single
+// call if the reader implements BatchStateReader.
+func readSlots(stateDB StateReader, addr common.Address, slots []common.Hash, rawSlots map[common.Hash]common.Hash) {
+	if len(slots) == 0 {
+		return
+	}
+	if batch, ok := stateDB.(BatchStateReader); ok {
+		for i, value := range batch.GetStates(addr, slots) {
+			rawSlots[slots[i]] = value
+		}
+		return
+	}
+	for _, slot := range slots {
+		rawSlots[slot] = stateDB.GetState(addr, slot)
+	}
+}
+
+// runDecoder decodes the raw slots of addr, handing state and balance aware
+// decoders what they need from stateDB. The native balance of addr is
+// returned if the decoder asked for it and the reader could provide it.
+func runDecoder(addr common.Address, decoder ContractDecoder, stateDB StateReader, rawSlots map[common.Hash]common.Hash) (decoded interface{}, balance *big.Int, err error) {
+	stateDecoder, wantsState := decoder.(StateDecoder)
+	balanceDecoder, wantsBalance := decoder.(NativeBalanceDecoder)
+	extended, hasState := stateDB.(ExtendedStateReader)
+	if wantsBalance && hasState {
+		balance = extended.GetBalance(addr).ToBig()
+	}
+	switch {
+	case wantsState && hasState:
+		decoded, err = stateDecoder.DecodeState(addr, rawSlots, extended)
+	case wantsBalance:
+		decoded, err = balanceDecoder.DecodeBalance(rawSlots, balance)
+	default:
+		decoded, err = decoder.Decode(rawSlots)
+	}
+	return decoded, balance, err
+}
+
+// TryDecode reads the slots decoder needs for addr from stateDB and decodes
+// them once, returning the decoded state. Neither the watchlist nor any
+// snapshot is touched, so operators can check that a decoder produces sane
+// output for a contract before watching it. Registered token decimals are
+// applied as for cached contracts.
+func (c *Cache) TryDecode(addr common.Address, decoder ContractDecoder, stateDB StateReader) (decoded interface{}, err error) {
+	if decoder == nil {
+		return nil, errors.New("no decoder given")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			decoded, err = nil, fmt.Errorf("panic while decoding contract: %v", r)
+		}
+	}()
+	storageAddr := addr
+	if external, ok := decoder.(ExternalStorageDecoder); ok {
+		storageAddr = external.StorageAddress()
+	}
+	rawSlots := make(map[common.Hash]common.Hash)
+	readDecoderSlots(decoder, storageAddr, stateDB, rawSlots, !c.config.SkipOptionalSlots)
+
+	decoded, _, err = runDecoder(addr, decoder, stateDB, rawSlots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", decoder.Type(), err)
+	}
+	if state, ok := decoded.(tokenDecimalsState); ok {
+		state.setTokenDecimals(c.lookupTokenDecimals)
+	}
+	return decoded, nil
+}
+
+// SlotMismatch describes a cached storage slot that differs from the canonical
+// state.
+type SlotMismatch struct {
+	Address   common.Address
+	Slot      common.Hash
+	Cached    common.Hash
+	Canonical common.Hash
+}
+
+// ValidationError aggregates every slot mismatch found during a validation run.
+// It unwraps to ErrInconsistentState.
+type ValidationError struct {
+	Mismatches []SlotMismatch
+}
+
+// Error implements error, listing all mismatched slots.
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v: %d mismatched slots", ErrInconsistentState, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		fmt.Fprintf(&b, "; contract=%s slot=%s cached=%s canonical=%s",
+			m.Address.Hex(), m.Slot.Hex(), m.Cached.Hex(), m.Canonical.Hex())
+	}
+	return b.String()
+}
+
+// Unwrap allows errors.Is(err, ErrInconsistentState).
+func (e *ValidationError) Unwrap() error {
+	return ErrInconsistentState
+}
+
 // Validate checks if the cached state matches the canonical state.
 // This should be called periodically in shadow mode to verify correctness.
+// All contracts and slots are checked, or a random sample of the slots if
+// ValidationSampleRate is set; any mismatches are reported together as a
+// *ValidationError, ordered by address and slot.
 func (c *Cache) Validate(stateDB StateReader) error {
 	if !c.config.ShadowMode {
 		return nil
 	}
-	
-	snapshot := c.GetSnapshot()
-	
+	return c.validateSnapshot(c.GetSnapshot(), stateDB)
+}
+
+// validateSnapshot implements Validate for the given snapshot.
+func (c *Cache) validateSnapshot(snapshot *Snapshot, stateDB StateReader) error {
+	var mismatches []SlotMismatch
 	for addr, cachedState := range snapshot.Contracts {
-		// Verify each raw slot
-		for slot, cachedValue := range cachedState.RawSlots {
-			canonicalValue := stateDB.GetState(addr, slot)
-			
-			if cachedValue != canonicalValue {
-				c.stats.ValidationErrors.Add(1)
-				return fmt.Errorf("%w: contract=%s slot=%s cached=%s canonical=%s",
-					ErrInconsistentState,
-					addr.Hex(),
-					slot.Hex(),
-					cachedValue.Hex(),
-					canonicalValue.Hex())
-			}
-		}
+		mismatches = c.validateSlots(addr, cachedState, stateDB, c.config.ValidationSampleRate, mismatches)
+	}
+	if len(mismatches) > 0 {
+		c.checkValidationErrors()
+		return newValidationError(mismatches)
 	}
 	
-	log.Debug("Cache validation passed", "block", snapshot.BlockNumber)
+	c.logger.Debug("Cache validation passed", "block", snapshot.BlockNumber)
 	return nil
 }
 
@@ -158,112 +596,263 @@ func (c *Cache) ValidateContract(addr common.Address, stateDB StateReader) error
 		return err
 	}
 	
-	for slot, cachedValue := range cachedState.RawSlots {
-		canonicalValue := stateDB.GetState(addr, slot)
+	if mismatches := c.validateSlots(addr, cachedState, stateDB, 0, nil); len(mismatches) > 0 {
+		c.checkValidationErrors()
+		return newValidationError(mismatches)
+	}
+	return nil
+}
+
+// checkValidationErrors disables the cache once the number of mismatched slots
+// exceeds MaxValidationErrors, publishing an empty snapshot so no diverged
+// state is served anymore.
+func (c *Cache) checkValidationErrors() {
+	limit := c.config.MaxValidationErrors
+	if limit == 0 || c.stats.ValidationErrors.Load() <= limit {
+		return
+	}
+	empty := &Snapshot{Contracts: make(map[common.Address]*ContractState)}
+
+	c.snapshotMu.Lock()
+	tripped := c.enabled.CompareAndSwap(true, false)
+	if tripped {
+		c.tripped.Store(true)
+		c.current.Store(empty)
+	}
+	c.snapshotMu.Unlock()
+
+	if tripped {
+		c.logger.Error("Hot cache disabled after repeated validation failures, falling back to canonical reads",
+			"errors", c.stats.ValidationErrors.Load(), "limit", limit)
+		c.notifySubscribers(empty)
+	}
+}
+
+// validateSlots compares the raw slots of a contract against the canonical
+// state, appending mismatches to the given slice and counting each one. A
+// sample rate within (0, 1) checks each slot with that probability, any other
+// rate checks every slot.
+func (c *Cache) validateSlots(addr common.Address, cachedState *ContractState, stateDB StateReader, sampleRate float64, mismatches []SlotMismatch) []SlotMismatch {
+	sampled := sampleRate > 0 && sampleRate < 1
+	storageAddr, owners := c.storageAddress(addr, cachedState.rawSlots, stateDB)
+	for slot, cachedValue := range cachedState.rawSlots {
+		if sampled && rand.Float64() >= sampleRate {
+			continue
+		}
+		owner, ok := owners[slot]
+		if !ok {
+			owner = storageAddr
+		}
+		canonicalValue := stateDB.GetState(owner, slot)
 		
 		if cachedValue != canonicalValue {
 			c.stats.ValidationErrors.Add(1)
-			return fmt.Errorf("%w: contract=%s slot=%s cached=%s canonical=%s",
-				ErrInconsistentState,
-				addr.Hex(),
-				slot.Hex(),
-				cachedValue.Hex(),
-				canonicalValue.Hex())
+			if c.metrics != nil {
+				c.metrics.validationErrors.Inc(1)
+			}
+			if c.config.OnValidationError != nil {
+				c.config.OnValidationError(addr, slot, cachedValue, canonicalValue)
+			}
+			mismatches = append(mismatches, SlotMismatch{
+				Address:   addr,
+				Slot:      slot,
+				Cached:    cachedValue,
+				Canonical: canonicalValue,
+			})
 		}
 	}
-	
-	return nil
+	return mismatches
 }
 
-// cleanupOldSnapshots removes snapshots beyond the retention limit.
-// Must be called with snapshotMu held.
-func (c *Cache) cleanupOldSnapshots(currentBlock uint64) {
-	if uint64(len(c.snapshots)) <= uint64(c.config.MaxSnapshots) {
-		return
-	}
-	
-	// Find snapshots to remove (older than currentBlock - MaxSnapshots)
-	cutoff := uint64(0)
-	if currentBlock > uint64(c.config.MaxSnapshots) {
-		cutoff = currentBlock - uint64(c.config.MaxSnapshots)
+// storageAddress returns the contract whose storage backs the cached state of
+// addr, which differs from addr for ExternalStorageDecoders, along with the
+// cached slots held by other contracts: slots of ForeignSlotDecoders, and
+// custom slots of addr itself that its ExternalStorageDecoder does not read.
+// Overlapping slots are attributed as updateContract reads them.
+func (c *Cache) storageAddress(addr common.Address, rawSlots map[common.Hash]common.Hash, stateDB StateReader) (common.Address, map[common.Hash]common.Address) {
+	decoder, _ := c.lookupDecoder(addr, stateDB)
+
+	storageAddr := addr
+	if external, ok := decoder.(ExternalStorageDecoder); ok {
+		storageAddr = external.StorageAddress()
 	}
-	
-	for hash, snapshot := range c.snapshots {
-		if snapshot.BlockNumber < cutoff {
-			delete(c.snapshots, hash)
-			log.Trace("Removed old snapshot", "block", snapshot.BlockNumber)
+	owners := make(map[common.Hash]common.Address)
+	if storageAddr != addr {
+		c.decoderMu.RLock()
+		for _, slot := range c.customSlots[addr] {
+			owners[slot] = addr
+		}
+		c.decoderMu.RUnlock()
+
+		// Slots also read by the decoder are taken from its storage
+		for _, slot := range decoder.RequiredSlots() {
+			delete(owners, slot)
+		}
+		if optional, ok := decoder.(OptionalSlotDecoder); ok && !c.config.SkipOptionalSlots {
+			for _, slot := range optional.OptionalSlots() {
+				delete(owners, slot)
+			}
 		}
+		if dependent, ok := decoder.(DependentSlotDecoder); ok {
+			for _, slot := range dependent.RequiredSlotsFor(rawSlots) {
+				delete(owners, slot)
+			}
+		}
+	}
+	if decoder, ok := decoder.(ForeignSlotDecoder); ok {
+		maps.Copy(owners, decoder.ForeignSlots())
 	}
+	return storageAddr, owners
+}
+
+// newValidationError sorts the mismatches for stable reporting.
+func newValidationError(mismatches []SlotMismatch) *ValidationError {
+	slices.SortFunc(mismatches, func(a, b SlotMismatch) int {
+		if n := a.Address.Cmp(b.Address); n != 0 {
+			return n
+		}
+		return a.Slot.Cmp(b.Slot)
+	})
+	return &ValidationError{Mismatches: mismatches}
 }
 
 // HandleReorg handles a chain reorganization by rolling back to a common ancestor
-// and replaying the new chain.
-func (c *Cache) HandleReorg(oldChain, newChain []*types.Header, stateDB StateReader) error {
-	if !c.config.Enabled {
+// and replaying the new chain. As passed by the blockchain, newChain holds the
+// blocks to apply in any order, newest first by convention, and excludes the
+// common ancestor, which is the parent of its oldest block. oldChain holds the
+// dropped blocks and is empty if the new chain merely extends the current
+// head, in which case no rollback takes place. If the ancestor's snapshot is
+// no longer retained, the whole new chain is replayed instead. Each replayed
+// block is read from the state states returns for its root. ErrEmptyReorg is
+// returned if newChain is empty, and ErrReorgProtectionDisabled if no
+// snapshots are retained.
+func (c *Cache) HandleReorg(oldChain, newChain []*types.Header, states StateProvider) error {
+	if !c.IsEnabled() {
 		return nil
 	}
+	if c.snapshots == nil {
+		c.logger.Error("Hot cache cannot handle reorg, reorg protection is disabled",
+			"oldBlocks", len(oldChain), "newBlocks", len(newChain))
+		return ErrReorgProtectionDisabled
+	}
+	if len(newChain) == 0 {
+		return ErrEmptyReorg
+	}
 	
-	c.stats.ReorgCount.Add(1)
-	
-	log.Warn("Hot cache handling reorg",
-		"oldBlocks", len(oldChain),
-		"newBlocks", len(newChain))
-	
-	// Find common ancestor
-	var commonHash common.Hash
-	for i := len(oldChain) - 1; i >= 0; i-- {
-		for j := len(newChain) - 1; j >= 0; j-- {
-			if oldChain[i].Hash() == newChain[j].Hash() {
-				commonHash = oldChain[i].Hash()
-				break
-			}
-		}
-		if commonHash != (common.Hash{}) {
-			break
+	if len(oldChain) > 0 {
+		c.stats.ReorgCount.Add(1)
+		if c.metrics != nil {
+			c.metrics.reorgs.Inc(1)
 		}
+		c.logger.Warn("Hot cache handling reorg",
+			"oldBlocks", len(oldChain),
+			"newBlocks", len(newChain))
+	} else {
+		c.logger.Debug("Hot cache extending chain", "newBlocks", len(newChain))
 	}
 	
-	// Roll back to common ancestor
-	c.snapshotMu.RLock()
-	commonSnapshot, ok := c.snapshots[commonHash]
-	c.snapshotMu.RUnlock()
-	
-	if !ok {
-		log.Error("Common ancestor snapshot not found, clearing cache",
-			"commonHash", commonHash.Hex())
-		// Clear cache and rebuild from current state
-		return c.Update(newChain[len(newChain)-1], stateDB)
+	// Replay oldest first, the common ancestor is the parent of the oldest block
+	headers := slices.Clone(newChain)
+	slices.SortStableFunc(headers, func(a, b *types.Header) int { return a.Number.Cmp(b.Number) })
+	var (
+		commonHash   = headers[0].ParentHash
+		commonNumber uint64
+	)
+	if number := headers[0].Number.Uint64(); number > 0 {
+		commonNumber = number - 1
 	}
 	
-	// Restore common ancestor as current
-	c.current.Store(commonSnapshot)
+	// Roll back to common ancestor
+	commonSnapshot := c.GetSnapshotByHash(commonHash)
+	if commonSnapshot == nil {
+		return c.replayWithoutAncestor(commonHash, headers, states)
+	}
 	
-	log.Info("Rolled back to common ancestor",
-		"block", commonSnapshot.BlockNumber,
-		"hash", commonHash.Hex()[:10])
+	// Restore common ancestor as current, unless it already is
+	if commonSnapshot == c.GetSnapshot() {
+		c.logger.Debug("Common ancestor is the current head, skipping rollback",
+			"block", commonNumber,
+			"hash", commonHash.Hex()[:10])
+	} else {
+		c.current.Store(commonSnapshot)
+		c.logger.Info("Rolled back to common ancestor",
+			"block", commonNumber,
+			"hash", commonHash.Hex()[:10])
+	}
+	c.headNumber.Store(commonNumber)
 	
 	// Replay new chain
-	for _, header := range newChain {
-		if header.Number.Uint64() <= commonSnapshot.BlockNumber {
-			continue
+	for _, header := range headers {
+		stateDB, err := states(header.Root)
+		if err != nil {
+			return fmt.Errorf("failed to open state of block %d: %w", header.Number.Uint64(), err)
 		}
 		if err := c.Update(header, stateDB); err != nil {
 			return fmt.Errorf("failed to replay block %d: %w", header.Number.Uint64(), err)
 		}
 	}
 	
-	log.Info("Replayed new chain",
-		"blocks", len(newChain),
-		"newHead", newChain[len(newChain)-1].Number.Uint64())
+	c.logger.Info("Replayed new chain",
+		"blocks", len(headers),
+		"newHead", headers[len(headers)-1].Number.Uint64())
 	
 	return nil
 }
 
+// replayWithoutAncestor rebuilds the snapshots of the whole new chain, given
+// oldest block first, if the snapshot of the common ancestor is no longer
+// retained. This restores reorg protection right away instead of only caching
+// the new head. Blocks that would be displaced from the ring by the end of the
+// replay are skipped. If the replay fails, e.g. because the state of an older
+// block is no longer available, the cache is rebuilt from the new head alone.
+func (c *Cache) replayWithoutAncestor(commonHash common.Hash, headers []*types.Header, states StateProvider) error {
+	head := headers[len(headers)-1]
+	if skipped := len(headers) - c.config.MaxSnapshots; skipped > 0 {
+		c.logger.Warn("New chain exceeds snapshot retention, replaying only the most recent blocks",
+			"blocks", len(headers), "skipped", skipped, "retained", c.config.MaxSnapshots)
+		headers = headers[skipped:]
+	}
+	c.logger.Warn("Common ancestor snapshot not found, replaying new chain",
+		"commonHash", commonHash.Hex(),
+		"from", headers[0].Number.Uint64(),
+		"to", head.Number.Uint64())
+
+	for _, header := range headers {
+		stateDB, err := states(header.Root)
+		if err == nil {
+			err = c.Update(header, stateDB)
+		}
+		if err != nil {
+			c.logger.Error("Failed to replay new chain, rebuilding from the new head",
+				"block", header.Number.Uint64(), "err", err)
+			return c.replayHead(head, states)
+		}
+	}
+	c.logger.Info("Replayed new chain without common ancestor",
+		"blocks", len(headers),
+		"newHead", head.Number.Uint64())
+	return nil
+}
+
+// replayHead rebuilds the cache from the state of the new head alone.
+func (c *Cache) replayHead(head *types.Header, states StateProvider) error {
+	stateDB, err := states(head.Root)
+	if err != nil {
+		return fmt.Errorf("failed to open state of block %d: %w", head.Number.Uint64(), err)
+	}
+	return c.Update(head, stateDB)
+}
+
 // StateDBReader adapts state.StateDB to the StateReader interface.
 type StateDBReader struct {
 	db *state.StateDB
 }
 
+var (
+	_ ExtendedStateReader = (*StateDBReader)(nil)
+	_ BatchStateReader    = (*StateDBReader)(nil)
+)
+
 // NewStateDBReader creates a StateReader from a StateDB.
 func NewStateDBReader(db *state.StateDB) StateReader {
 	return &StateDBReader{db: db}
@@ -274,3 +863,28 @@ func (r *StateDBReader) GetState(addr common.Address, slot common.Hash) common.H
 	return r.db.GetState(addr, slot)
 }
 
+// GetStates implements BatchStateReader. The StateDB is still read slot by
+// slot, saving only the per-slot interface calls.
+func (r *StateDBReader) GetStates(addr common.Address, slots []common.Hash) []common.Hash {
+	values := make([]common.Hash, len(slots))
+	for i, slot := range slots {
+		values[i] = r.db.GetState(addr, slot)
+	}
+	return values
+}
+
+// GetCodeHash implements CodeHashReader.
+func (r *StateDBReader) GetCodeHash(addr common.Address) common.Hash {
+	return r.db.GetCodeHash(addr)
+}
+
+// GetBalance implements ExtendedStateReader.
+func (r *StateDBReader) GetBalance(addr common.Address) *uint256.Int {
+	return r.db.GetBalance(addr)
+}
+
+// GetCode implements ExtendedStateReader.
+func (r *StateDBReader) GetCode(addr common.Address) []byte {
+	return r.db.GetCode(addr)
+}
+
@@ -0,0 +1,1711 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/holiman/uint256"
+)
+
+// mockStateReader is an in-memory CodeHashReader for tests.
+type mockStateReader struct {
+	storage    map[common.Address]map[common.Hash]common.Hash
+	codeHashes map[common.Address]common.Hash
+}
+
+func newMockStateReader() *mockStateReader {
+	return &mockStateReader{
+		storage:    make(map[common.Address]map[common.Hash]common.Hash),
+		codeHashes: make(map[common.Address]common.Hash),
+	}
+}
+
+func (m *mockStateReader) GetState(addr common.Address, slot common.Hash) common.Hash {
+	return m.storage[addr][slot]
+}
+
+func (m *mockStateReader) GetCodeHash(addr common.Address) common.Hash {
+	return m.codeHashes[addr]
+}
+
+func (m *mockStateReader) setState(addr common.Address, slot, value common.Hash) {
+	if m.storage[addr] == nil {
+		m.storage[addr] = make(map[common.Hash]common.Hash)
+	}
+	m.storage[addr][slot] = value
+}
+
+// extendedStateReader is a mockStateReader that also serves balances and code.
+type extendedStateReader struct {
+	*mockStateReader
+	balances map[common.Address]*uint256.Int
+	code     map[common.Address][]byte
+}
+
+func (m *extendedStateReader) GetBalance(addr common.Address) *uint256.Int {
+	if balance, ok := m.balances[addr]; ok {
+		return balance
+	}
+	return new(uint256.Int)
+}
+
+func (m *extendedStateReader) GetCode(addr common.Address) []byte {
+	return m.code[addr]
+}
+
+// balanceDecoder is a test StateDecoder decoding a contract's native balance.
+type balanceDecoder struct{}
+
+func (d *balanceDecoder) Type() ContractType           { return ContractTypeUnknown }
+func (d *balanceDecoder) RequiredSlots() []common.Hash { return nil }
+
+func (d *balanceDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	return nil, errors.New("balance unavailable")
+}
+
+func (d *balanceDecoder) DecodeState(addr common.Address, slots map[common.Hash]common.Hash, state ExtendedStateReader) (interface{}, error) {
+	return state.GetBalance(addr).ToBig(), nil
+}
+
+// rawSlotDecoder is a test decoder that reads a fixed set of slots and
+// produces no decoded state.
+type rawSlotDecoder struct {
+	slots []common.Hash
+}
+
+func (d *rawSlotDecoder) Type() ContractType           { return ContractTypeUnknown }
+func (d *rawSlotDecoder) RequiredSlots() []common.Hash { return d.slots }
+
+func (d *rawSlotDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	return nil, nil
+}
+
+// testHeader returns a header with the given number and a unique hash.
+func testHeader(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number)}
+}
+
+func TestDynamicWatchlist(t *testing.T) {
+	addr1 := common.HexToAddress("0x1")
+	addr2 := common.HexToAddress("0x2")
+
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr1}})
+	cache.RegisterDecoder(addr1, &UniswapV2Decoder{})
+	reader := newMockStateReader()
+
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := cache.GetContractState(addr2); err == nil {
+		t.Fatal("Expected unwatched contract to be absent")
+	}
+
+	// Add a contract at runtime, it should be cached on the next update
+	cache.AddToWatchlist(addr2)
+	if !cache.IsWatched(addr2) {
+		t.Fatal("Expected added contract to be watched")
+	}
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := cache.GetContractState(addr2); err != nil {
+		t.Fatalf("Expected added contract to be cached: %v", err)
+	}
+
+	// Remove a contract, it should be purged together with its decoder
+	cache.RemoveFromWatchlist(addr1)
+	if cache.IsWatched(addr1) {
+		t.Fatal("Expected removed contract to be unwatched")
+	}
+	if err := cache.Update(testHeader(3), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := cache.GetContractState(addr1); err == nil {
+		t.Fatal("Expected removed contract to be purged from the snapshot")
+	}
+	cache.decoderMu.RLock()
+	_, ok := cache.decoders[addr1]
+	cache.decoderMu.RUnlock()
+	if ok {
+		t.Fatal("Expected decoder of removed contract to be dropped")
+	}
+}
+
+func TestReplaceWatchlist(t *testing.T) {
+	var (
+		old   = []common.Address{common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")}
+		fresh = []common.Address{common.HexToAddress("0x4"), common.HexToAddress("0x5"), common.HexToAddress("0x6")}
+	)
+	cache := New(Config{Enabled: true, Watchlist: old})
+	for _, addr := range old {
+		cache.RegisterDecoder(addr, &UniswapV2Decoder{})
+	}
+	cache.WatchSlots(old[0], []common.Hash{common.HexToHash("0xff")})
+	reader := newMockStateReader()
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	decoders := map[common.Address]ContractDecoder{
+		fresh[0]: &UniswapV2Decoder{},
+		fresh[1]: &UniswapV2Decoder{},
+		old[0]:   &UniswapV2Decoder{}, // not in the new list, ignored
+	}
+	cache.ReplaceWatchlist(fresh, decoders)
+	if watchlist := cache.Watchlist(); !slices.Equal(watchlist, fresh) {
+		t.Fatalf("Unexpected watchlist: have %v, want %v", watchlist, fresh)
+	}
+	// State of the old contracts is served until the next update
+	if _, err := cache.GetContractState(old[0]); err != nil {
+		t.Fatalf("Expected old contract to stay cached until the next update: %v", err)
+	}
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	for _, addr := range old {
+		if _, err := cache.GetContractState(addr); err == nil {
+			t.Errorf("Expected replaced contract %s to be purged", addr.Hex())
+		}
+		if cache.HasDecoder(addr) {
+			t.Errorf("Expected decoder of replaced contract %s to be dropped", addr.Hex())
+		}
+	}
+	cache.decoderMu.RLock()
+	_, ok := cache.customSlots[old[0]]
+	cache.decoderMu.RUnlock()
+	if ok {
+		t.Error("Expected custom slots of replaced contract to be dropped")
+	}
+	for i, addr := range fresh {
+		state, err := cache.GetContractState(addr)
+		if err != nil {
+			t.Fatalf("Expected new contract %s to be cached: %v", addr.Hex(), err)
+		}
+		// The last contract has no decoder and is cached undecoded
+		if decoded := state.Type == ContractTypeUniswapV2; decoded != (i < 2) {
+			t.Errorf("Unexpected type of %s: %v", addr.Hex(), state.Type)
+		}
+	}
+}
+
+func TestSnapshotStateRoot(t *testing.T) {
+	pool := common.HexToAddress("0x1")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+
+	header := testHeader(1)
+	header.Root = common.HexToHash("0x5eed")
+	if err := cache.Update(header, newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if root := cache.GetSnapshot().StateRoot; root != header.Root {
+		t.Errorf("Unexpected state root: have %s, want %s", root.Hex(), header.Root.Hex())
+	}
+	blob, err := json.Marshal(cache.GetSnapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	if want := `"stateRoot":"` + header.Root.Hex() + `"`; !strings.Contains(string(blob), want) {
+		t.Errorf("Expected %s in JSON output: %s", want, blob)
+	}
+}
+
+// TestConcurrentWatchlistUpdate mutates the watchlist while blocks are being
+// imported. Run with -race to detect unsynchronized access to the watchlist.
+func TestConcurrentWatchlistUpdate(t *testing.T) {
+	stable := common.HexToAddress("0x1")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{stable}})
+	cache.RegisterDecoder(stable, &UniswapV2Decoder{})
+	reader := newMockStateReader()
+
+	const blocks = 200
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for n := uint64(1); n <= blocks; n++ {
+			if err := cache.Update(testHeader(n), reader); err != nil {
+				t.Errorf("Update failed: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < blocks; i++ {
+			addr := common.BigToAddress(big.NewInt(int64(2 + i%8)))
+			cache.AddToWatchlist(addr)
+			cache.RegisterDecoder(addr, &UniswapV2Decoder{})
+			cache.IsWatched(addr)
+			cache.Watchlist()
+			cache.RemoveFromWatchlist(addr)
+		}
+	}()
+	wg.Wait()
+
+	if _, err := cache.GetContractState(stable); err != nil {
+		t.Errorf("Expected the stable contract to stay cached: %v", err)
+	}
+	if watchlist := cache.Watchlist(); len(watchlist) != 1 || watchlist[0] != stable {
+		t.Errorf("Expected only the stable contract to be watched, got %v", watchlist)
+	}
+}
+
+// testChain builds a linked chain of headers from..to on top of parent. The
+// extra data distinguishes otherwise identical headers on competing branches.
+func testChain(parent *types.Header, from, to uint64, extra string) []*types.Header {
+	var chain []*types.Header
+	for n := from; n <= to; n++ {
+		header := &types.Header{
+			Number: new(big.Int).SetUint64(n),
+			Extra:  []byte(extra),
+		}
+		if parent != nil {
+			header.ParentHash = parent.Hash()
+		}
+		chain = append(chain, header)
+		parent = header
+	}
+	return chain
+}
+
+// staticStates returns a StateProvider serving reader for every state root.
+func staticStates(reader StateReader) StateProvider {
+	return func(common.Hash) (StateReader, error) { return reader, nil }
+}
+
+// newestFirst returns the headers in reverse order, the order in which the
+// blockchain passes reorged chains.
+func newestFirst(headers []*types.Header) []*types.Header {
+	reversed := slices.Clone(headers)
+	slices.Reverse(reversed)
+	return reversed
+}
+
+func TestHandleReorgReplaysFromAncestor(t *testing.T) {
+	cache := New(Config{Enabled: true})
+	reader := newMockStateReader()
+
+	shared := testChain(nil, 90, 100, "")
+	oldBranch := testChain(shared[len(shared)-1], 101, 105, "old")
+	newBranch := testChain(shared[len(shared)-1], 101, 106, "new")
+	for _, header := range append(append([]*types.Header{}, shared...), oldBranch...) {
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	before := cache.GetStatistics().Updates
+
+	// Both chains are handed over newest first without the ancestor at 100
+	if err := cache.HandleReorg(newestFirst(oldBranch), newestFirst(newBranch), staticStates(reader)); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	if replayed := cache.GetStatistics().Updates - before; replayed != uint64(len(newBranch)) {
+		t.Errorf("Expected %d replayed blocks, got %d", len(newBranch), replayed)
+	}
+	if head := cache.GetSnapshot(); head.BlockHash != newBranch[len(newBranch)-1].Hash() {
+		t.Errorf("Expected snapshot at new head, got block %d", head.BlockNumber)
+	}
+	if reorgs := cache.GetStatistics().ReorgCount; reorgs != 1 {
+		t.Errorf("Expected 1 reorg, got %d", reorgs)
+	}
+	// The rollback started at the ancestor, older blocks were not replayed
+	for _, header := range newBranch {
+		if snapshot := cache.GetSnapshotByHash(header.Hash()); snapshot == nil || snapshot.BlockNumber != header.Number.Uint64() {
+			t.Errorf("Expected replayed snapshot for block %d", header.Number.Uint64())
+		}
+	}
+}
+
+func TestHandleReorgEmptyNewChain(t *testing.T) {
+	cache := New(Config{Enabled: true})
+	reader := newMockStateReader()
+
+	oldChain := testChain(nil, 1, 3, "")
+	for _, header := range oldChain {
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	if err := cache.HandleReorg(oldChain, nil, staticStates(reader)); !errors.Is(err, ErrEmptyReorg) {
+		t.Fatalf("Expected ErrEmptyReorg, got %v", err)
+	}
+	if head := cache.GetSnapshot(); head.BlockHash != oldChain[2].Hash() {
+		t.Errorf("Expected snapshot to stay at the old head, got block %d", head.BlockNumber)
+	}
+}
+
+func TestHandleReorgAncestorIsHead(t *testing.T) {
+	var messages []string
+	cache := New(Config{Enabled: true, Logger: log.NewLogger(recordingHandler{messages: &messages})})
+	reader := newMockStateReader()
+
+	oldChain := testChain(nil, 1, 3, "")
+	for _, header := range oldChain {
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	head := cache.GetSnapshot()
+	before := cache.GetStatistics().Updates
+
+	// The new chain extends the current head, nothing needs to be rolled
+	// back. As after the merge, the blockchain hands over no old blocks and
+	// the new blocks newest first, without the current head.
+	extension := testChain(oldChain[2], 4, 5, "new")
+	if err := cache.HandleReorg(nil, newestFirst(extension), staticStates(reader)); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	if replayed := cache.GetStatistics().Updates - before; replayed != uint64(len(extension)) {
+		t.Errorf("Expected %d replayed blocks, got %d", len(extension), replayed)
+	}
+	if snapshot := cache.GetSnapshot(); snapshot.BlockHash != extension[1].Hash() {
+		t.Errorf("Expected snapshot at new head, got block %d", snapshot.BlockNumber)
+	}
+	if cache.GetSnapshotByHash(oldChain[2].Hash()) != head {
+		t.Error("Expected the ancestor snapshot to stay retained")
+	}
+	if reorgs := cache.GetStatistics().ReorgCount; reorgs != 0 {
+		t.Errorf("Expected the extension not to count as a reorg, got %d", reorgs)
+	}
+	for _, unwanted := range []string{"Hot cache handling reorg", "Common ancestor snapshot not found, replaying new chain"} {
+		if slices.Contains(messages, unwanted) {
+			t.Errorf("Unexpected %q logged for a chain extension", unwanted)
+		}
+	}
+}
+
+func TestHandleReorgMissingAncestor(t *testing.T) {
+	cache := New(Config{Enabled: true, MaxSnapshots: 4})
+	reader := newMockStateReader()
+
+	// The ancestor at block 2 is displaced by the old branch
+	shared := testChain(nil, 1, 2, "")
+	oldBranch := testChain(shared[1], 3, 8, "old")
+	oldChain := append(append([]*types.Header{}, shared...), oldBranch...)
+	for _, header := range oldChain {
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	if cache.GetSnapshotByHash(shared[1].Hash()) != nil {
+		t.Fatal("Expected the ancestor snapshot to be displaced")
+	}
+
+	// Hand the new chain over newest first, as the blockchain does
+	newBranch := testChain(shared[1], 3, 5, "new")
+	if err := cache.HandleReorg(newestFirst(oldBranch), newestFirst(newBranch), staticStates(reader)); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	if head := cache.GetSnapshot(); head.BlockHash != newBranch[2].Hash() {
+		t.Fatalf("Expected snapshot at new head, got block %d", head.BlockNumber)
+	}
+	for _, header := range newBranch {
+		if snapshot := cache.GetSnapshotByHash(header.Hash()); snapshot == nil || snapshot.BlockNumber != header.Number.Uint64() {
+			t.Errorf("Expected rebuilt snapshot for block %d", header.Number.Uint64())
+		}
+	}
+
+	// A new chain longer than the retention only replays the retained part
+	longBranch := testChain(shared[1], 3, 9, "long")
+	before := cache.GetStatistics().Updates
+	if err := cache.HandleReorg(newestFirst(newBranch), newestFirst(longBranch), staticStates(reader)); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	if replayed := cache.GetStatistics().Updates - before; replayed != 4 {
+		t.Errorf("Expected 4 replayed blocks, got %d", replayed)
+	}
+	if head := cache.GetSnapshot(); head.BlockHash != longBranch[len(longBranch)-1].Hash() {
+		t.Errorf("Expected snapshot at new head, got block %d", head.BlockNumber)
+	}
+}
+
+// TestHandleReorgReadsBlockState checks that every replayed block is read
+// from its own state rather than from the state of the new head.
+func TestHandleReorgReadsBlockState(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	slot := common.HexToHash("0x01")
+
+	// Every block has its own state root, holding its number in the slot
+	readers := make(map[common.Hash]StateReader)
+	chain := func(parent *types.Header, from, to uint64, extra string) []*types.Header {
+		var headers []*types.Header
+		for n := from; n <= to; n++ {
+			header := &types.Header{
+				Number: new(big.Int).SetUint64(n),
+				Extra:  []byte(extra),
+				Root:   crypto.Keccak256Hash([]byte(extra), new(big.Int).SetUint64(n).Bytes()),
+			}
+			if parent != nil {
+				header.ParentHash = parent.Hash()
+			}
+			reader := newMockStateReader()
+			reader.setState(pool, slot, common.BigToHash(header.Number))
+			readers[header.Root] = reader
+			headers = append(headers, header)
+			parent = header
+		}
+		return headers
+	}
+	states := func(root common.Hash) (StateReader, error) {
+		if reader, ok := readers[root]; ok {
+			return reader, nil
+		}
+		return nil, errors.New("missing state")
+	}
+	cache := New(Config{Enabled: true, MaxSnapshots: 4})
+	cache.WatchSlots(pool, []common.Hash{slot})
+
+	shared := chain(nil, 1, 2, "")
+	oldChain := append(shared, chain(shared[1], 3, 8, "old")...)
+	for _, header := range oldChain {
+		reader, _ := states(header.Root)
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	check := func(headers []*types.Header) {
+		t.Helper()
+		for _, header := range headers {
+			snapshot := cache.GetSnapshotByHash(header.Hash())
+			if snapshot == nil {
+				t.Errorf("Expected snapshot for block %d", header.Number.Uint64())
+				continue
+			}
+			if value := snapshot.Contracts[pool].rawSlots[slot]; value != common.BigToHash(header.Number) {
+				t.Errorf("Block %d: expected its own state, got slot value %s", header.Number.Uint64(), value.Hex())
+			}
+		}
+	}
+	// The ancestor at block 2 is displaced, so the whole new chain is replayed
+	newBranch := chain(shared[1], 3, 5, "new")
+	if err := cache.HandleReorg(newestFirst(oldChain[2:]), newestFirst(newBranch), states); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	check(newBranch)
+
+	// Replaying from a retained ancestor reads each block's state as well
+	otherBranch := chain(newBranch[0], 4, 6, "other")
+	if err := cache.HandleReorg(newestFirst(newBranch[1:]), newestFirst(otherBranch), states); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	check(otherBranch)
+
+	// Without the state of an older block only the new head is rebuilt
+	prunedBranch := chain(shared[1], 3, 5, "pruned")
+	delete(readers, prunedBranch[0].Root)
+	if err := cache.HandleReorg(newestFirst(otherBranch), newestFirst(prunedBranch), states); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	check(prunedBranch[2:])
+	if cache.GetSnapshotByHash(prunedBranch[0].Hash()) != nil {
+		t.Error("Expected no snapshot for the block without state")
+	}
+}
+
+func TestPublishOnlyOnChange(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}, PublishOnlyOnChange: true})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	updates, unsubscribe := cache.SubscribeUpdates()
+	defer unsubscribe()
+
+	reader := newMockStateReader()
+	reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	chain := testChain(nil, 1, 3, "")
+	if err := cache.Update(chain[0], reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	first := <-updates
+
+	// Unchanged reserves keep the published snapshot
+	if err := cache.Update(chain[1], reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if snapshot := cache.GetSnapshot(); snapshot != first || snapshot.BlockNumber != 1 {
+		t.Fatalf("Expected snapshot of block 1 to stay published, got block %d", snapshot.BlockNumber)
+	}
+	select {
+	case snapshot := <-updates:
+		t.Fatalf("Unexpected notification for block %d", snapshot.BlockNumber)
+	default:
+	}
+	if cache.GetSnapshotByHash(chain[1].Hash()) != first {
+		t.Error("Expected snapshot retained for the unchanged block")
+	}
+	if cache.IsStale(2, 0) {
+		t.Error("Expected cache updated to block 2 to be fresh")
+	}
+	if _, ok := cache.GetStorageAt(chain[1].Hash(), pool, uniswapV2SlotReserves); !ok {
+		t.Error("Expected cached read at the unchanged block")
+	}
+
+	// Changed reserves publish a new snapshot
+	reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(big.NewInt(2000)))
+	if err := cache.Update(chain[2], reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if snapshot := <-updates; snapshot == first || snapshot.BlockNumber != 3 {
+		t.Fatalf("Expected new snapshot for block 3, got block %d", snapshot.BlockNumber)
+	}
+
+	// A reorg onto the unchanged block rolls back to its retained snapshot
+	newBranch := testChain(chain[1], 3, 4, "new")
+	before := cache.GetStatistics().Updates
+	if err := cache.HandleReorg(newestFirst(chain[2:]), newestFirst(newBranch), staticStates(reader)); err != nil {
+		t.Fatalf("HandleReorg failed: %v", err)
+	}
+	if replayed := cache.GetStatistics().Updates - before; replayed != uint64(len(newBranch)) {
+		t.Errorf("Expected %d replayed blocks, got %d", len(newBranch), replayed)
+	}
+}
+
+// slowStateReader delays every state read.
+type slowStateReader struct {
+	*mockStateReader
+	delay time.Duration
+}
+
+func (r *slowStateReader) GetState(addr common.Address, slot common.Hash) common.Hash {
+	time.Sleep(r.delay)
+	return r.mockStateReader.GetState(addr, slot)
+}
+
+func TestUpdateContextDeadline(t *testing.T) {
+	slot := common.HexToHash("0x01")
+	watchlist := make([]common.Address, 20)
+	for i := range watchlist {
+		watchlist[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	for _, partial := range []bool{false, true} {
+		cache := New(Config{Enabled: true, Watchlist: watchlist, PublishPartialUpdates: partial})
+		for _, addr := range watchlist {
+			cache.WatchSlots(addr, []common.Hash{slot})
+		}
+		// Reading the whole watchlist takes a second
+		reader := &slowStateReader{mockStateReader: newMockStateReader(), delay: 50 * time.Millisecond}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		start := time.Now()
+		err := cache.UpdateContext(ctx, testHeader(1), reader)
+		elapsed := time.Since(start)
+		cancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("partial %v: expected DeadlineExceeded, got %v", partial, err)
+		}
+		if elapsed > 500*time.Millisecond {
+			t.Errorf("partial %v: update took %v past its deadline", partial, elapsed)
+		}
+		snapshot := cache.GetSnapshot()
+		if partial {
+			if snapshot.BlockNumber != 1 || len(snapshot.Contracts) == 0 || len(snapshot.Contracts) == len(watchlist) {
+				t.Errorf("Expected partial snapshot of block 1, got block %d with %d contracts", snapshot.BlockNumber, len(snapshot.Contracts))
+			}
+		} else if snapshot.BlockHash != (common.Hash{}) {
+			t.Errorf("Expected no snapshot published, got block %d", snapshot.BlockNumber)
+		}
+	}
+}
+
+// panickingDecoder is a test decoder that panics while decoding.
+type panickingDecoder struct{ rawSlotDecoder }
+
+func (d *panickingDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	panic("corrupt state")
+}
+
+func TestMergePartialUpdates(t *testing.T) {
+	var (
+		good = common.HexToAddress("0x1")
+		bad  = common.HexToAddress("0x2")
+	)
+	for _, merge := range []bool{false, true} {
+		cache := New(Config{Enabled: true, Watchlist: []common.Address{good, bad}, PublishPartialUpdates: merge, MergePartialUpdates: merge})
+		cache.RegisterDecoder(good, &UniswapV2Decoder{})
+		cache.RegisterDecoder(bad, &UniswapV2Decoder{})
+		reader := newMockStateReader()
+		reader.setState(good, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+		if err := cache.Update(testHeader(1), reader); err != nil {
+			t.Fatalf("merge %v: update failed: %v", merge, err)
+		}
+		before, _ := cache.GetContractState(bad)
+
+		// The broken contract fails while the other one advances
+		cache.RegisterDecoder(bad, &panickingDecoder{})
+		reader.setState(good, uniswapV2SlotReserves, common.BigToHash(big.NewInt(2000)))
+		if err := cache.Update(testHeader(2), reader); err != nil {
+			t.Fatalf("merge %v: update failed: %v", merge, err)
+		}
+		if state, err := cache.GetContractState(good); err != nil || state.LastUpdated != 2 ||
+			state.Decoded.(*UniswapV2State).Reserve0.Int64() != 2000 {
+			t.Errorf("merge %v: expected the healthy contract to advance, got %v (err %v)", merge, state, err)
+		}
+		state, err := cache.GetContractState(bad)
+		if !merge {
+			if err == nil {
+				t.Errorf("Expected the failing contract to be dropped, got %v", state)
+			}
+			continue
+		}
+		if err != nil || state != before || state.LastUpdated != 1 {
+			t.Errorf("Expected the failing contract's previous state, got %v (err %v)", state, err)
+		}
+
+		// A cancelled update publishes every contract with its previous state
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := cache.UpdateContext(ctx, testHeader(3), reader); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected Canceled, got %v", err)
+		}
+		if snapshot := cache.GetSnapshot(); snapshot.BlockNumber != 3 || len(snapshot.Contracts) != 2 {
+			t.Errorf("Expected merged snapshot of block 3, got block %d with %d contracts", snapshot.BlockNumber, len(snapshot.Contracts))
+		}
+	}
+}
+
+func TestSnapshotRetentionHardCap(t *testing.T) {
+	const maxSnapshots = 64
+	cache := New(Config{Enabled: true, MaxSnapshots: maxSnapshots})
+	reader := newMockStateReader()
+
+	// Feed 200 snapshots as competing sidechain blocks at a handful of heights,
+	// so the block-number cutoff alone never triggers.
+	for i := 0; i < 200; i++ {
+		header := &types.Header{
+			Number: big.NewInt(int64(100 + i%4)),
+			Extra:  []byte{byte(i)},
+		}
+		if err := cache.Update(header, reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		cache.snapshotMu.RLock()
+		n := cache.snapshots.len()
+		ok := cache.snapshots.get(header.Hash()) != nil
+		cache.snapshotMu.RUnlock()
+		if n > maxSnapshots {
+			t.Fatalf("Snapshot count %d exceeds cap %d after %d updates", n, maxSnapshots, i+1)
+		}
+		if !ok {
+			t.Fatalf("Head snapshot evicted after %d updates", i+1)
+		}
+	}
+}
+
+func TestUpdateIncremental(t *testing.T) {
+	var (
+		changed   = common.HexToAddress("0x1")
+		unchanged = common.HexToAddress("0x2")
+		slot      = common.HexToHash("0x5")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{changed, unchanged}})
+	cache.RegisterDecoder(changed, &rawSlotDecoder{slots: []common.Hash{slot}})
+	cache.RegisterDecoder(unchanged, &rawSlotDecoder{slots: []common.Hash{slot}})
+
+	reader := newMockStateReader()
+	reader.setState(changed, slot, common.HexToHash("0xa"))
+	reader.setState(unchanged, slot, common.HexToHash("0xa"))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	before := cache.GetSnapshot()
+
+	reader.setState(changed, slot, common.HexToHash("0xb"))
+	reader.setState(unchanged, slot, common.HexToHash("0xb"))
+	if err := cache.UpdateIncremental(testHeader(2), reader, map[common.Address]bool{changed: true}); err != nil {
+		t.Fatalf("UpdateIncremental failed: %v", err)
+	}
+	after := cache.GetSnapshot()
+
+	if after.Contracts[unchanged] != before.Contracts[unchanged] {
+		t.Error("Expected unchanged contract state to be carried forward")
+	}
+	if after.Contracts[unchanged].LastUpdated != 1 {
+		t.Errorf("Expected carried state from block 1, got %d", after.Contracts[unchanged].LastUpdated)
+	}
+	if got := after.Contracts[changed].rawSlots[slot]; got != common.HexToHash("0xb") {
+		t.Errorf("Expected changed contract to be re-read, got %s", got.Hex())
+	}
+	if after.Contracts[changed].LastUpdated != 2 {
+		t.Errorf("Expected changed state from block 2, got %d", after.Contracts[changed].LastUpdated)
+	}
+}
+
+
+func TestLastUpdateStats(t *testing.T) {
+	var (
+		updated  = common.HexToAddress("0x1")
+		skipped  = common.HexToAddress("0x2")
+		corrupt  = common.HexToAddress("0x3")
+		panicked = common.HexToAddress("0x4")
+		slot     = common.HexToHash("0x5")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{updated, skipped, corrupt, panicked}})
+	cache.RegisterDecoder(updated, &rawSlotDecoder{slots: []common.Hash{slot}})
+	cache.RegisterDecoder(skipped, &rawSlotDecoder{slots: []common.Hash{slot}})
+	cache.RegisterDecoder(corrupt, &failingDecoder{rawSlotDecoder{slots: []common.Hash{slot}}})
+
+	if stats := cache.LastUpdateStats(); stats != (UpdateStats{}) {
+		t.Errorf("Expected no stats before the first update, got %+v", stats)
+	}
+	reader := newMockStateReader()
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if stats := cache.LastUpdateStats(); stats.Updated != 4 || stats.Skipped != 0 {
+		t.Errorf("Expected a full update of 4 contracts, got %+v", stats)
+	}
+
+	// Incremental update touching all but one contract, one of which fails
+	// to decode and one of which fails outright
+	cache.RegisterDecoder(panicked, &panickingDecoder{rawSlotDecoder{slots: []common.Hash{slot}}})
+	changed := map[common.Address]bool{updated: true, corrupt: true, panicked: true}
+	if err := cache.UpdateIncremental(testHeader(2), reader, changed); err != nil {
+		t.Fatalf("UpdateIncremental failed: %v", err)
+	}
+	want := UpdateStats{BlockNumber: 2, Updated: 2, Skipped: 1, DecodeFailed: 1, Failed: 1}
+	if stats := cache.LastUpdateStats(); stats != want {
+		t.Errorf("Unexpected update stats: have %+v, want %+v", stats, want)
+	}
+}
+func benchmarkUpdate(b *testing.B, incremental bool) {
+	const contracts = 500
+	var (
+		watchlist = make([]common.Address, contracts)
+		changed   = make(map[common.Address]bool)
+		reader    = newMockStateReader()
+	)
+	for i := range watchlist {
+		watchlist[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+		if i%50 == 0 {
+			changed[watchlist[i]] = true
+		}
+	}
+	cache := New(Config{Enabled: true, Watchlist: watchlist})
+	decoder := &UniswapV2Decoder{}
+	for _, addr := range watchlist {
+		cache.RegisterDecoder(addr, decoder)
+	}
+	if err := cache.Update(testHeader(0), reader); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		header := testHeader(uint64(i + 1))
+		if incremental {
+			cache.UpdateIncremental(header, reader, changed)
+		} else {
+			cache.Update(header, reader)
+		}
+	}
+}
+
+func BenchmarkUpdateFull500(b *testing.B)        { benchmarkUpdate(b, false) }
+func BenchmarkUpdateIncremental500(b *testing.B) { benchmarkUpdate(b, true) }
+
+func TestReuseSnapshotsKeepsSharedState(t *testing.T) {
+	var (
+		hot  = common.HexToAddress("0x1")
+		cold = common.HexToAddress("0x2")
+		slot = common.HexToHash("0x5")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{hot, cold}, MaxSnapshots: 2, ReuseSnapshots: true})
+	cache.RegisterDecoder(hot, &rawSlotDecoder{slots: []common.Hash{slot}})
+	cache.RegisterDecoder(cold, &rawSlotDecoder{slots: []common.Hash{slot}})
+
+	reader := newMockStateReader()
+	reader.setState(cold, slot, common.HexToHash("0xc0"))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	// Only the hot contract changes, the cold state is carried forward through
+	// snapshots that are evicted and recycled along the way.
+	for i := uint64(2); i < 20; i++ {
+		reader.setState(hot, slot, common.BigToHash(new(big.Int).SetUint64(i)))
+		if err := cache.UpdateIncremental(testHeader(i), reader, map[common.Address]bool{hot: true}); err != nil {
+			t.Fatalf("UpdateIncremental failed: %v", err)
+		}
+	}
+	snapshot := cache.GetSnapshot()
+	if got := snapshot.Contracts[cold].rawSlots[slot]; got != common.HexToHash("0xc0") {
+		t.Errorf("Shared cold state was recycled, got %s", got.Hex())
+	}
+	if got := snapshot.Contracts[hot].rawSlots[slot]; got != common.BigToHash(big.NewInt(19)) {
+		t.Errorf("Unexpected hot state %s", got.Hex())
+	}
+}
+
+func benchmarkUpdateAllocs(b *testing.B, reuse bool) {
+	const contracts = 200
+	watchlist := make([]common.Address, contracts)
+	for i := range watchlist {
+		watchlist[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	cache := New(Config{Enabled: true, Watchlist: watchlist, MaxSnapshots: 8, ReuseSnapshots: reuse})
+	decoder := &UniswapV2Decoder{}
+	for _, addr := range watchlist {
+		cache.RegisterDecoder(addr, decoder)
+	}
+	reader := newMockStateReader()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Update(testHeader(uint64(i)), reader)
+	}
+}
+
+func BenchmarkUpdateAllocs200(b *testing.B)      { benchmarkUpdateAllocs(b, false) }
+func BenchmarkUpdateAllocs200Reuse(b *testing.B) { benchmarkUpdateAllocs(b, true) }
+
+func benchmarkUpdateReader(b *testing.B, reader StateReader) {
+	const contracts = 200
+	watchlist := make([]common.Address, contracts)
+	for i := range watchlist {
+		watchlist[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	cache := New(Config{Enabled: true, Watchlist: watchlist, MaxSnapshots: 8})
+	decoder := &UniswapV2Decoder{}
+	for _, addr := range watchlist {
+		cache.RegisterDecoder(addr, decoder)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Update(testHeader(uint64(i)), reader)
+	}
+}
+
+func BenchmarkUpdateSlotReads200(b *testing.B) { benchmarkUpdateReader(b, newMockStateReader()) }
+func BenchmarkUpdateBatchReads200(b *testing.B) {
+	benchmarkUpdateReader(b, &batchStateReader{mockStateReader: newMockStateReader()})
+}
+
+func TestValidateReportsAllMismatches(t *testing.T) {
+	addr1 := common.HexToAddress("0x1")
+	addr2 := common.HexToAddress("0x2")
+	slot1 := common.BigToHash(big.NewInt(1))
+	slot2 := common.BigToHash(big.NewInt(2))
+
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{addr1, addr2}})
+	cache.RegisterDecoder(addr1, &rawSlotDecoder{slots: []common.Hash{slot1, slot2}})
+	cache.RegisterDecoder(addr2, &rawSlotDecoder{slots: []common.Hash{slot1, slot2}})
+
+	reader := newMockStateReader()
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := cache.Validate(reader); err != nil {
+		t.Fatalf("Expected consistent cache, got %v", err)
+	}
+
+	// Diverge one slot in each contract
+	reader.setState(addr1, slot2, common.HexToHash("0xaa"))
+	reader.setState(addr2, slot1, common.HexToHash("0xbb"))
+
+	err := cache.Validate(reader)
+	if !errors.Is(err, ErrInconsistentState) {
+		t.Fatalf("Expected ErrInconsistentState, got %v", err)
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %T", err)
+	}
+	want := []SlotMismatch{
+		{Address: addr1, Slot: slot2, Cached: common.Hash{}, Canonical: common.HexToHash("0xaa")},
+		{Address: addr2, Slot: slot1, Cached: common.Hash{}, Canonical: common.HexToHash("0xbb")},
+	}
+	if !slices.Equal(verr.Mismatches, want) {
+		t.Errorf("Unexpected mismatches:\nhave %v\nwant %v", verr.Mismatches, want)
+	}
+	if errs := cache.GetStatistics().ValidationErrors; errs != 2 {
+		t.Errorf("Expected 2 validation errors counted, got %d", errs)
+	}
+
+	// Single-contract validation only reports its own slots
+	err = cache.ValidateContract(addr2, reader)
+	if !errors.As(err, &verr) || len(verr.Mismatches) != 1 || verr.Mismatches[0].Address != addr2 {
+		t.Errorf("Unexpected contract validation result: %v", err)
+	}
+}
+
+func TestOnValidationError(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	slot := common.BigToHash(big.NewInt(1))
+
+	var fired []SlotMismatch
+	cache := New(Config{
+		Enabled:    true,
+		ShadowMode: true,
+		Watchlist:  []common.Address{addr},
+		OnValidationError: func(addr common.Address, slot common.Hash, cached, canonical common.Hash) {
+			fired = append(fired, SlotMismatch{Address: addr, Slot: slot, Cached: cached, Canonical: canonical})
+		},
+	})
+	cache.RegisterDecoder(addr, &rawSlotDecoder{slots: []common.Hash{slot}})
+
+	reader := newMockStateReader()
+	reader.setState(addr, slot, common.HexToHash("0x01"))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(fired) != 0 {
+		t.Fatalf("Callback fired without a mismatch: %v", fired)
+	}
+
+	// Plant a mismatch
+	reader.setState(addr, slot, common.HexToHash("0x02"))
+	want := SlotMismatch{Address: addr, Slot: slot, Cached: common.HexToHash("0x01"), Canonical: common.HexToHash("0x02")}
+	if err := cache.Validate(reader); err == nil {
+		t.Fatal("Expected validation error")
+	}
+	if len(fired) != 1 || fired[0] != want {
+		t.Errorf("Unexpected callback invocations from Validate: %v", fired)
+	}
+	if err := cache.ValidateContract(addr, reader); err == nil {
+		t.Fatal("Expected contract validation error")
+	}
+	if len(fired) != 2 || fired[1] != want {
+		t.Errorf("Unexpected callback invocations from ValidateContract: %v", fired)
+	}
+}
+
+// TestValidationSampleRate checks that sampled validation checks only part of
+// the slots per run, yet still catches a persistent mismatch over many runs.
+func TestValidationSampleRate(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	var slots []common.Hash
+	for i := int64(0); i < 10; i++ {
+		slots = append(slots, common.BigToHash(big.NewInt(i)))
+	}
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{addr}, ValidationSampleRate: 0.1})
+	cache.RegisterDecoder(addr, &rawSlotDecoder{slots: slots})
+
+	reader := newMockStateReader()
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	reader.setState(addr, slots[3], common.HexToHash("0xaa"))
+
+	const runs = 200
+	var caught int
+	for i := 0; i < runs; i++ {
+		err := cache.Validate(reader)
+		if err == nil {
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || len(verr.Mismatches) != 1 || verr.Mismatches[0].Slot != slots[3] {
+			t.Fatalf("Unexpected validation error: %v", err)
+		}
+		caught++
+	}
+	// Missing the mismatch in every run has probability 0.9^200, catching it
+	// in every run 0.1^200
+	if caught == 0 || caught == runs {
+		t.Errorf("Expected the mismatch to be caught in some runs, caught in %d of %d", caught, runs)
+	}
+	if errs := cache.GetStatistics().ValidationErrors; errs != uint64(caught) {
+		t.Errorf("Expected %d validation errors counted, got %d", caught, errs)
+	}
+}
+
+func TestMaxValidationErrorsDisablesCache(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	slots := []common.Hash{common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(2))}
+
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{addr}, MaxValidationErrors: 2})
+	cache.RegisterDecoder(addr, &rawSlotDecoder{slots: slots})
+	sub, unsubscribe := cache.SubscribeUpdates()
+	defer unsubscribe()
+
+	reader := newMockStateReader()
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	<-sub
+
+	// Two mismatches reach but do not exceed the limit
+	reader.setState(addr, slots[0], common.HexToHash("0xaa"))
+	reader.setState(addr, slots[1], common.HexToHash("0xbb"))
+	if err := cache.Validate(reader); err == nil {
+		t.Fatal("Expected validation error")
+	}
+	if !cache.Healthy() || !cache.IsEnabled() {
+		t.Fatal("Cache disabled before exceeding the limit")
+	}
+	// The third trips the breaker
+	if err := cache.ValidateContract(addr, reader); err == nil {
+		t.Fatal("Expected validation error")
+	}
+	if cache.Healthy() || cache.IsEnabled() {
+		t.Fatal("Expected cache to be unhealthy and disabled")
+	}
+	if snapshot := cache.GetSnapshot(); len(snapshot.Contracts) != 0 {
+		t.Errorf("Expected empty snapshot, got %d contracts", len(snapshot.Contracts))
+	}
+	if snapshot := <-sub; len(snapshot.Contracts) != 0 {
+		t.Errorf("Expected subscribers to receive the empty snapshot, got %d contracts", len(snapshot.Contracts))
+	}
+	// Further updates are ignored
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := cache.GetContractState(addr); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected disabled cache to serve nothing, got %v", err)
+	}
+}
+
+// driftingStateReader returns a different value on every read, so the state
+// cached during Update never matches a subsequent validation read.
+type driftingStateReader struct {
+	reads uint64
+}
+
+func (r *driftingStateReader) GetState(addr common.Address, slot common.Hash) common.Hash {
+	r.reads++
+	return common.BigToHash(new(big.Int).SetUint64(r.reads))
+}
+
+func TestUpdateValidatesInShadowMode(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	slot := common.BigToHash(big.NewInt(1))
+
+	tests := []struct {
+		name   string
+		shadow bool
+		everyN uint64
+		want   uint64 // validation errors after blocks 1..4
+	}{
+		{"shadow off", false, 0, 0},
+		{"every block", true, 0, 4},
+		{"every second block", true, 2, 2},
+	}
+	for _, tt := range tests {
+		cache := New(Config{Enabled: true, ShadowMode: tt.shadow, ValidateEveryN: tt.everyN, Watchlist: []common.Address{addr}})
+		cache.RegisterDecoder(addr, &rawSlotDecoder{slots: []common.Hash{slot}})
+
+		reader := new(driftingStateReader)
+		for n := uint64(1); n <= 4; n++ {
+			if err := cache.Update(testHeader(n), reader); err != nil {
+				t.Fatalf("%s: update failed: %v", tt.name, err)
+			}
+		}
+		if got := cache.GetStatistics().ValidationErrors; got != tt.want {
+			t.Errorf("%s: expected %d validation errors, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+// failingDecoder reads its slots but always fails to decode them.
+type failingDecoder struct {
+	rawSlotDecoder
+}
+
+func (d *failingDecoder) Type() ContractType { return ContractTypeUniswapV2 }
+
+func (d *failingDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	return nil, errors.New("corrupt layout")
+}
+
+func TestDeadContracts(t *testing.T) {
+	var (
+		pool  = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}, MaxConsecutiveDecodeErrors: 3})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+
+	// A token slot with dirty high bytes fails decoding
+	reader := newMockStateReader()
+	clean := common.BytesToHash(token.Bytes())
+	dirty := clean
+	dirty[0] = 0x01
+
+	number := uint64(0)
+	update := func(token0 common.Hash) *ContractState {
+		t.Helper()
+		number++
+		reader.setState(pool, uniswapV2SlotToken0, token0)
+		if err := cache.Update(testHeader(number), reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		state, err := cache.GetContractState(pool)
+		if err != nil {
+			t.Fatalf("Expected the contract to stay cached: %v", err)
+		}
+		return state
+	}
+	// Transient failures below the limit, reset by a success
+	for i := 0; i < 2; i++ {
+		if state := update(dirty); state.Dead || state.DecodeError == nil {
+			t.Fatalf("Failure %d: expected a live contract with a decode error", i+1)
+		}
+	}
+	if state := update(clean); state.Dead || state.decodeFailures != 0 || state.Decoded == nil {
+		t.Fatal("Expected a successful decode to reset the failures")
+	}
+	// Three consecutive failures mark the contract dead, served raw
+	for i := 0; i < 3; i++ {
+		state := update(dirty)
+		if dead := i == 2; state.Dead != dead {
+			t.Fatalf("Failure %d: expected dead %v, got %v", i+1, dead, state.Dead)
+		}
+	}
+	if dead := cache.DeadContracts(); len(dead) != 1 || dead[0] != pool {
+		t.Errorf("Expected the pool to be dead, got %v", dead)
+	}
+	if value, err := cache.GetRawSlot(pool, uniswapV2SlotToken0); err != nil || value != dirty {
+		t.Errorf("Expected the dead contract's raw slots to be served, got %s (err %v)", value.Hex(), err)
+	}
+	// Further failures keep it dead, a success revives it
+	if state := update(dirty); !state.Dead || state.decodeFailures != 3 {
+		t.Errorf("Expected the contract to stay dead with capped failures, got %d", state.decodeFailures)
+	}
+	if state := update(clean); state.Dead || state.Decoded == nil {
+		t.Error("Expected a successful decode to revive the contract")
+	}
+	if dead := cache.DeadContracts(); len(dead) != 0 {
+		t.Errorf("Expected no dead contracts, got %v", dead)
+	}
+}
+
+func TestUpdatePublishesRawSlotsOnDecodeFailure(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	slot := common.BigToHash(big.NewInt(1))
+
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{addr}})
+	cache.RegisterDecoder(addr, &failingDecoder{rawSlotDecoder{slots: []common.Hash{slot}}})
+
+	reader := newMockStateReader()
+	reader.setState(addr, slot, common.HexToHash("0x2a"))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(addr)
+	if err != nil {
+		t.Fatalf("Expected contract to be cached despite decode failure: %v", err)
+	}
+	if state.Decoded != nil {
+		t.Errorf("Expected no decoded state, got %v", state.Decoded)
+	}
+	if state.DecodeError == nil || !strings.Contains(state.DecodeError.Error(), "corrupt layout") {
+		t.Errorf("Expected decode error to be recorded, got %v", state.DecodeError)
+	}
+	if state.Type != ContractTypeUniswapV2 || state.LastUpdated != 1 {
+		t.Errorf("Unexpected metadata: type %v, last updated %d", state.Type, state.LastUpdated)
+	}
+	value, err := cache.GetRawSlot(addr, slot)
+	if err != nil || value != common.HexToHash("0x2a") {
+		t.Errorf("Expected raw slot 0x2a, got %v (err %v)", value, err)
+	}
+
+	// The decode error survives a JSON round-trip
+	enc, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var dec ContractState
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if dec.DecodeError == nil || dec.DecodeError.Error() != state.DecodeError.Error() {
+		t.Errorf("Expected decode error %q after round-trip, got %v", state.DecodeError, dec.DecodeError)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	slot := common.BigToHash(big.NewInt(1))
+
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{addr}})
+	cache.RegisterDecoder(addr, &rawSlotDecoder{slots: []common.Hash{slot}})
+	if _, err := cache.GetContractState(addr); !errors.Is(err, ErrNotPopulated) {
+		t.Fatalf("Expected ErrNotPopulated before warmup, got %v", err)
+	}
+
+	reader := newMockStateReader()
+	reader.setState(addr, slot, common.HexToHash("0x2a"))
+	head := testHeader(100)
+	if err := cache.Warmup(head, reader); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+	state, err := cache.GetContractState(addr)
+	if err != nil {
+		t.Fatalf("Expected contract state after warmup: %v", err)
+	}
+	if state.rawSlots[slot] != common.HexToHash("0x2a") || state.LastUpdated != 100 {
+		t.Errorf("Unexpected warmed up state: %+v", state)
+	}
+	if cache.GetSnapshotByHash(head.Hash()) == nil {
+		t.Error("Warmup snapshot not retained as reorg baseline")
+	}
+	if stats := cache.GetStatistics(); stats.Updates != 0 || stats.ValidationErrors != 0 {
+		t.Errorf("Warmup should not count as an update: %+v", stats)
+	}
+}
+
+func TestWatchSlotsWithoutDecoder(t *testing.T) {
+	var (
+		addr   = common.HexToAddress("0x1")
+		paused = common.BigToHash(big.NewInt(3))
+		owner  = common.BigToHash(big.NewInt(5))
+		other  = common.BigToHash(big.NewInt(6))
+	)
+	cache := New(Config{Enabled: true})
+	cache.WatchSlots(addr, []common.Hash{paused, owner})
+	cache.WatchSlots(addr, []common.Hash{paused}) // duplicates are ignored
+
+	reader := newMockStateReader()
+	reader.setState(addr, paused, common.BigToHash(big.NewInt(1)))
+	reader.setState(addr, owner, common.HexToHash("0xabcdef"))
+	reader.setState(addr, other, common.HexToHash("0xff"))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	state, err := cache.GetContractState(addr)
+	if err != nil {
+		t.Fatalf("Contract with custom slots not cached: %v", err)
+	}
+	if state.Decoded != nil || state.Type != ContractTypeUnknown {
+		t.Errorf("Expected undecoded contract, got type %v decoded %v", state.Type, state.Decoded)
+	}
+	if len(state.rawSlots) != 2 {
+		t.Errorf("Expected 2 raw slots, got %d", len(state.rawSlots))
+	}
+	if value, err := cache.GetRawSlot(addr, paused); err != nil || value != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("Unexpected paused slot %v (err %v)", value, err)
+	}
+	if value, err := cache.GetRawSlot(addr, owner); err != nil || value != common.HexToHash("0xabcdef") {
+		t.Errorf("Unexpected owner slot %v (err %v)", value, err)
+	}
+	if _, err := cache.GetRawSlot(addr, other); err == nil {
+		t.Error("Expected error for unwatched slot")
+	}
+}
+
+func TestWatchMappingSlot(t *testing.T) {
+	var (
+		token   = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		owner   = common.LeftPadBytes(common.HexToAddress("0x1111").Bytes(), 32)
+		spender = common.LeftPadBytes(common.HexToAddress("0x2222").Bytes(), 32)
+
+		balances   = common.BigToHash(big.NewInt(9))  // mapping(address => uint256)
+		allowances = common.BigToHash(big.NewInt(10)) // mapping(address => mapping(address => uint256))
+	)
+	cache := New(Config{Enabled: true})
+	balanceSlot, err := cache.WatchMappingSlot(token, balances, owner)
+	if err != nil {
+		t.Fatalf("Failed to watch balance: %v", err)
+	}
+	allowanceSlot, err := cache.WatchMappingSlot(token, allowances, owner, spender)
+	if err != nil {
+		t.Fatalf("Failed to watch allowance: %v", err)
+	}
+
+	// keccak256(key . slot), applied once per mapping level
+	if want := crypto.Keccak256Hash(owner, balances[:]); balanceSlot != want {
+		t.Errorf("Unexpected balance slot: have %s, want %s", balanceSlot.Hex(), want.Hex())
+	}
+	inner := crypto.Keccak256(owner, allowances[:])
+	if want := crypto.Keccak256Hash(spender, inner); allowanceSlot != want {
+		t.Errorf("Unexpected allowance slot: have %s, want %s", allowanceSlot.Hex(), want.Hex())
+	}
+
+	reader := newMockStateReader()
+	reader.setState(token, balanceSlot, common.BigToHash(big.NewInt(1000)))
+	reader.setState(token, allowanceSlot, common.BigToHash(big.NewInt(25)))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if value, err := cache.GetMappingSlot(token, balances, owner); err != nil || value != common.BigToHash(big.NewInt(1000)) {
+		t.Errorf("Unexpected balance %v (err %v)", value, err)
+	}
+	if value, err := cache.GetMappingSlot(token, allowances, owner, spender); err != nil || value != common.BigToHash(big.NewInt(25)) {
+		t.Errorf("Unexpected allowance %v (err %v)", value, err)
+	}
+	// The reverse allowance was never watched
+	if _, err := cache.GetMappingSlot(token, allowances, spender, owner); err == nil {
+		t.Error("Expected error for unwatched mapping entry")
+	}
+}
+
+func TestCodeHashChangeMarksSuspect(t *testing.T) {
+	var (
+		proxy = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		v1    = common.HexToHash("0x01")
+		v2    = common.HexToHash("0x02")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{proxy}})
+	cache.RegisterDecoder(proxy, &UniswapV2Decoder{})
+	cache.SetExpectedCodeHash(proxy, v1)
+
+	reader := newMockStateReader()
+	reader.codeHashes[proxy] = v1
+	reader.setState(proxy, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, _ := cache.GetContractState(proxy)
+	if state.Suspect || state.Decoded == nil {
+		t.Fatalf("Expected decoded state before upgrade, suspect %v", state.Suspect)
+	}
+
+	// The proxy is upgraded to a new implementation
+	reader.codeHashes[proxy] = v2
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, _ = cache.GetContractState(proxy)
+	if !state.Suspect || state.Decoded != nil {
+		t.Errorf("Expected suspect undecoded state after upgrade, suspect %v decoded %v", state.Suspect, state.Decoded)
+	}
+	if !errors.Is(state.DecodeError, ErrCodeHashMismatch) {
+		t.Errorf("Expected ErrCodeHashMismatch, got %v", state.DecodeError)
+	}
+	if state.rawSlots[uniswapV2SlotReserves] != common.BigToHash(big.NewInt(1000)) {
+		t.Error("Expected raw slots to be kept for suspect contract")
+	}
+
+	// Acknowledging the upgrade resumes decoding
+	cache.SetExpectedCodeHash(proxy, v2)
+	if err := cache.Update(testHeader(3), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if state, _ = cache.GetContractState(proxy); state.Suspect || state.Decoded == nil {
+		t.Errorf("Expected decoding to resume, suspect %v", state.Suspect)
+	}
+}
+
+func TestRegisterDecoderForImpl(t *testing.T) {
+	var (
+		proxy = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		v1    = common.HexToHash("0x01")
+		v2    = common.HexToHash("0x02")
+		v3    = common.HexToHash("0x03")
+		slot  = common.BigToHash(big.NewInt(100))
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{proxy}})
+	cache.RegisterDecoderForImpl(proxy, v1, &UniswapV2Decoder{})
+	cache.RegisterDecoderForImpl(proxy, v2, &rawSlotDecoder{slots: []common.Hash{slot}})
+	if !cache.HasDecoder(proxy) {
+		t.Fatal("Expected implementation decoders to count as registered")
+	}
+
+	reader := newMockStateReader()
+	reader.setState(proxy, uniswapV2SlotReserves, common.BigToHash(big.NewInt(1000)))
+	reader.setState(proxy, slot, common.HexToHash("0xaa"))
+
+	update := func(number uint64, code common.Hash) *ContractState {
+		t.Helper()
+		reader.codeHashes[proxy] = code
+		if err := cache.Update(testHeader(number), reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		state, err := cache.GetContractState(proxy)
+		if err != nil {
+			t.Fatalf("Contract not cached: %v", err)
+		}
+		return state
+	}
+	if state := update(1, v1); state.Type != ContractTypeUniswapV2 || state.Decoded == nil {
+		t.Errorf("Expected V2 decoding for v1, got type %v", state.Type)
+	}
+	// Switching the implementation switches the decoder
+	state := update(2, v2)
+	if _, ok := state.rawSlots[uniswapV2SlotReserves]; ok || state.rawSlots[slot] != common.HexToHash("0xaa") {
+		t.Errorf("Expected only the v2 layout slots, got %v", state.rawSlots)
+	}
+	// An unknown implementation is cached without decoding
+	if state := update(3, v3); state.Type != ContractTypeUnknown || state.Decoded != nil || len(state.rawSlots) != 0 {
+		t.Errorf("Expected raw-only state for unknown implementation, got type %v slots %v", state.Type, state.rawSlots)
+	}
+	if state := update(4, v1); state.Type != ContractTypeUniswapV2 {
+		t.Errorf("Expected V2 decoding after switching back, got type %v", state.Type)
+	}
+
+	cache.UnregisterDecoder(proxy)
+	if cache.HasDecoder(proxy) {
+		t.Error("Expected implementation decoders to be unregistered")
+	}
+}
+
+func TestStateDecoder(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}})
+	cache.RegisterDecoder(pool, &balanceDecoder{})
+
+	reader := &extendedStateReader{
+		mockStateReader: newMockStateReader(),
+		balances:        map[common.Address]*uint256.Int{pool: uint256.NewInt(1e18)},
+	}
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, _ := cache.GetContractState(pool)
+	if balance, ok := state.Decoded.(*big.Int); !ok || balance.Cmp(big.NewInt(1e18)) != 0 {
+		t.Errorf("Expected decoded balance of 1 ether, got %v (err %v)", state.Decoded, state.DecodeError)
+	}
+
+	// Plain readers fall back to Decode
+	if err := cache.Update(testHeader(2), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if state, _ := cache.GetContractState(pool); state.DecodeError == nil {
+		t.Error("Expected Decode to be used without an extended reader")
+	}
+}
+
+func TestStateDBReader(t *testing.T) {
+	var (
+		addr = common.HexToAddress("0x1")
+		code = []byte{0x60, 0x00}
+	)
+	db, err := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+	if err != nil {
+		t.Fatalf("Failed to create state: %v", err)
+	}
+	db.SetBalance(addr, uint256.NewInt(42), tracing.BalanceChangeUnspecified)
+	db.SetCode(addr, code, tracing.CodeChangeUnspecified)
+	db.SetState(addr, common.HexToHash("0x01"), common.HexToHash("0x02"))
+
+	reader, ok := NewStateDBReader(db).(ExtendedStateReader)
+	if !ok {
+		t.Fatal("Expected StateDBReader to implement ExtendedStateReader")
+	}
+	if balance := reader.GetBalance(addr); balance.Uint64() != 42 {
+		t.Errorf("Unexpected balance %v", balance)
+	}
+	if have := reader.GetCode(addr); !slices.Equal(have, code) {
+		t.Errorf("Unexpected code %x", have)
+	}
+	if hash := reader.GetCodeHash(addr); hash != crypto.Keccak256Hash(code) {
+		t.Errorf("Unexpected code hash %s", hash.Hex())
+	}
+	if value := reader.GetState(addr, common.HexToHash("0x01")); value != common.HexToHash("0x02") {
+		t.Errorf("Unexpected storage value %s", value.Hex())
+	}
+	values := NewStateDBReader(db).(BatchStateReader).GetStates(addr, []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x03")})
+	if !slices.Equal(values, []common.Hash{common.HexToHash("0x02"), {}}) {
+		t.Errorf("Unexpected batched storage values %v", values)
+	}
+}
+
+// batchStateReader serves slots from a mockStateReader through GetStates,
+// counting the reads taking either path.
+type batchStateReader struct {
+	*mockStateReader
+	batches, single int
+}
+
+func (r *batchStateReader) GetState(addr common.Address, slot common.Hash) common.Hash {
+	r.single++
+	return r.mockStateReader.GetState(addr, slot)
+}
+
+func (r *batchStateReader) GetStates(addr common.Address, slots []common.Hash) []common.Hash {
+	r.batches++
+	values := make([]common.Hash, len(slots))
+	for i, slot := range slots {
+		values[i] = r.mockStateReader.GetState(addr, slot)
+	}
+	return values
+}
+
+func TestBatchStateReader(t *testing.T) {
+	var (
+		v2 = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		v3 = common.HexToAddress("0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640")
+	)
+	reader := newMockStateReader()
+	packed := new(big.Int).Or(new(big.Int).Lsh(big.NewInt(500), 112), big.NewInt(1000000))
+	reader.setState(v2, uniswapV2SlotReserves, common.BigToHash(packed))
+	reader.setState(v2, common.HexToHash("0xff"), common.HexToHash("0x01"))
+	reader.setState(v3, uniswapV3SlotSlot0, uniswapV3Slot0Positive)
+	reader.setState(v3, uniswapV3SlotLiquidity, common.BigToHash(big.NewInt(12345)))
+
+	update := func(stateDB StateReader) *Snapshot {
+		cache := New(Config{Enabled: true, Watchlist: []common.Address{v2, v3}})
+		cache.RegisterDecoder(v2, &UniswapV2Decoder{})
+		cache.RegisterDecoder(v3, &UniswapV3Decoder{TickRange: 2}) // adds dependent tick slots
+		cache.WatchSlots(v2, []common.Hash{common.HexToHash("0xff")})
+		if err := cache.Update(testHeader(1), stateDB); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		return cache.GetSnapshot()
+	}
+	batch := &batchStateReader{mockStateReader: reader}
+	want, have := update(reader), update(batch)
+	if !have.Equal(want) {
+		t.Error("Batched reads produced a different snapshot than per-slot reads")
+	}
+	if batch.batches == 0 || batch.single != 0 {
+		t.Errorf("Expected batched reads only, have %d batches and %d single reads", batch.batches, batch.single)
+	}
+}
+
+// BenchmarkUpdateRetention measures the per-block snapshot bookkeeping overhead
+// with a full retention window and a small watchlist.
+func BenchmarkUpdateRetention(b *testing.B) {
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{common.HexToAddress("0x1")}, MaxSnapshots: 256})
+	reader := newMockStateReader()
+	for i := 0; i < 256; i++ {
+		cache.Update(testHeader(uint64(i)), reader)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Update(testHeader(uint64(256+i)), reader)
+	}
+}
+
+// recordingHandler is a slog handler recording the messages logged through it.
+type recordingHandler struct {
+	messages *[]string
+}
+
+func (h recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h recordingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h recordingHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	*h.messages = append(*h.messages, r.Message)
+	return nil
+}
+
+func TestConfigLogger(t *testing.T) {
+	var (
+		pool     = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		messages []string
+	)
+	cache := New(Config{
+		Enabled:   true,
+		Watchlist: []common.Address{pool},
+		Logger:    log.NewLogger(recordingHandler{messages: &messages}),
+	})
+	if err := cache.Update(testHeader(1), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	for _, want := range []string{"Hot state cache initialized", "Hot cache updated"} {
+		if !slices.Contains(messages, want) {
+			t.Errorf("Expected %q to be logged, got %q", want, messages)
+		}
+	}
+	// The root logger is used by default
+	if New(Config{}).logger != log.Root() {
+		t.Error("Expected the root logger when none is configured")
+	}
+}
+
+func TestTryDecode(t *testing.T) {
+	var (
+		pool   = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+		token0 = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+		token1 = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	)
+	reader := newMockStateReader()
+	reader.setState(pool, uniswapV2SlotToken0, common.BytesToHash(token0.Bytes()))
+	reader.setState(pool, uniswapV2SlotToken1, common.BytesToHash(token1.Bytes()))
+	packed := new(big.Int).Or(new(big.Int).Lsh(big.NewInt(500), 112), big.NewInt(1000000))
+	reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(packed))
+
+	cache := New(Config{Enabled: true})
+	decoded, err := cache.TryDecode(pool, &UniswapV2Decoder{}, reader)
+	if err != nil {
+		t.Fatalf("TryDecode failed: %v", err)
+	}
+	// Nothing was watched or published
+	if cache.IsWatched(pool) || cache.GetSnapshot().BlockNumber != 0 {
+		t.Fatal("TryDecode touched the watchlist or snapshot")
+	}
+
+	// The dry run matches the state cached by a normal update
+	cache.AddToWatchlist(pool)
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(pool)
+	if err != nil {
+		t.Fatalf("Pool not cached: %v", err)
+	}
+	if !decodedEqual(decoded, state.Decoded) {
+		t.Errorf("Dry run decoded %+v, update decoded %+v", decoded, state.Decoded)
+	}
+
+	// Decode errors are returned rather than cached
+	if _, err := cache.TryDecode(pool, &failingDecoder{}, reader); err == nil {
+		t.Error("Expected decode error")
+	}
+}
+
+func TestSkipOptionalSlots(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	reader := newMockStateReader()
+	packed := new(big.Int).Or(new(big.Int).Lsh(big.NewInt(500), 112), big.NewInt(1000000))
+	reader.setState(pool, uniswapV2SlotReserves, common.BigToHash(packed))
+	reader.setState(pool, uniswapV2SlotKLast, common.BigToHash(big.NewInt(999)))
+
+	decoder := &UniswapV2Decoder{}
+	for _, skip := range []bool{false, true} {
+		cache := New(Config{Enabled: true, Watchlist: []common.Address{pool}, SkipOptionalSlots: skip})
+		cache.RegisterDecoder(pool, decoder)
+		if err := cache.Update(testHeader(1), reader); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		state, _ := cache.GetContractState(pool)
+		if state.DecodeError != nil {
+			t.Fatalf("skip=%v: unexpected decode error: %v", skip, state.DecodeError)
+		}
+		want := append(decoder.RequiredSlots(), decoder.OptionalSlots()...)
+		kLast := int64(999)
+		if skip {
+			want, kLast = decoder.RequiredSlots(), 0
+		}
+		if state.SlotCount() != len(want) {
+			t.Errorf("skip=%v: expected %d slots, got %d", skip, len(want), state.SlotCount())
+		}
+		if slots, _ := cache.RequiredSlots(pool, reader); !slices.Equal(slots, want) {
+			t.Errorf("skip=%v: unexpected slots %v", skip, slots)
+		}
+		decoded := state.Decoded.(*UniswapV2State)
+		if decoded.KLast.Int64() != kLast {
+			t.Errorf("skip=%v: expected kLast %d, got %v", skip, kLast, decoded.KLast)
+		}
+		if decoded.OptionalSlotsMissing != skip {
+			t.Errorf("skip=%v: unexpected OptionalSlotsMissing %v", skip, decoded.OptionalSlotsMissing)
+		}
+	}
+}
@@ -18,6 +18,8 @@ package hotcache
 
 import (
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestKnownContracts(t *testing.T) {
@@ -91,6 +93,20 @@ func TestRegisterDefaultDecoders(t *testing.T) {
 	
 	// Verify decoders were registered (internal state, can't directly test)
 	// Just ensure no panic
+
+	// Every V3 pool is decoded with its own immutables
+	for name, addr := range UniswapV3PoolsMainnet {
+		decoder, ok := cache.decoders[addr].(*UniswapV3Decoder)
+		if !ok {
+			t.Fatalf("%s: expected a Uniswap V3 decoder, got %T", name, cache.decoders[addr])
+		}
+		if decoder.Token0 == (common.Address{}) || decoder.Token1 == (common.Address{}) || decoder.Fee == 0 || decoder.TickSpacing == 0 {
+			t.Errorf("%s: missing immutables in %+v", name, decoder)
+		}
+		if decoder.Token0.Cmp(decoder.Token1) >= 0 {
+			t.Errorf("%s: expected token0 %s below token1 %s", name, decoder.Token0.Hex(), decoder.Token1.Hex())
+		}
+	}
 }
 
 // TestTokenAddresses verifies token addresses are set correctly
@@ -0,0 +1,89 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StartValidator validates the current snapshot in the background every
+// interval, relieving the block processor from calling Validate. The stateAt
+// callback returns a reader for the state at the block a snapshot was built
+// from, so every run compares against matching canonical state; runs for
+// which it fails are skipped. Mismatches are counted and reported like those
+// found by Validate. A running validator is replaced. Nothing is started
+// unless ShadowMode is set. An error is returned if interval is not positive,
+// leaving a running validator untouched.
+func (c *Cache) StartValidator(interval time.Duration, stateAt func(blockHash common.Hash) (StateReader, error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid validator interval %v", interval)
+	}
+	if !c.config.ShadowMode {
+		return nil
+	}
+	c.StopValidator()
+
+	c.validatorMu.Lock()
+	defer c.validatorMu.Unlock()
+	c.validatorStop = make(chan struct{})
+	c.validatorDone = make(chan struct{})
+	go c.runValidator(interval, stateAt, c.validatorStop, c.validatorDone)
+	return nil
+}
+
+// StopValidator stops the background validator and waits for it to exit. It
+// is a no-op if no validator is running.
+func (c *Cache) StopValidator() {
+	c.validatorMu.Lock()
+	defer c.validatorMu.Unlock()
+	if c.validatorStop == nil {
+		return
+	}
+	close(c.validatorStop)
+	<-c.validatorDone
+	c.validatorStop, c.validatorDone = nil, nil
+}
+
+// runValidator is the background validation loop.
+func (c *Cache) runValidator(interval time.Duration, stateAt func(common.Hash) (StateReader, error), stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			snapshot := c.GetSnapshot()
+			if !c.IsEnabled() || snapshot.BlockHash == (common.Hash{}) {
+				continue
+			}
+			stateDB, err := stateAt(snapshot.BlockHash)
+			if err != nil {
+				c.logger.Debug("Skipping hot cache validation", "block", snapshot.BlockNumber, "err", err)
+				continue
+			}
+			if err := c.validateSnapshot(snapshot, stateDB); err != nil {
+				c.logger.Error("Hot cache validation failed", "block", snapshot.BlockNumber, "err", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestStartValidator(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	slot := common.BigToHash(big.NewInt(1))
+
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{addr}})
+	cache.RegisterDecoder(addr, &rawSlotDecoder{slots: []common.Hash{slot}})
+
+	reader := newMockStateReader()
+	reader.setState(addr, slot, common.HexToHash("0x2a"))
+	header := testHeader(1)
+	if err := cache.Update(header, reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	ticked := make(chan common.Hash, 1)
+	stateAt := func(blockHash common.Hash) (StateReader, error) {
+		select {
+		case ticked <- blockHash:
+		default:
+		}
+		return reader, nil
+	}
+	if err := cache.StartValidator(time.Millisecond, stateAt); err != nil {
+		t.Fatalf("StartValidator failed: %v", err)
+	}
+	select {
+	case hash := <-ticked:
+		if hash != header.Hash() {
+			t.Errorf("Expected state requested for block %s, got %s", header.Hash().Hex(), hash.Hex())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Validator did not run")
+	}
+	cache.StopValidator()
+
+	if errs := cache.GetStatistics().ValidationErrors; errs != 0 {
+		t.Errorf("Expected no validation errors against a good state, got %d", errs)
+	}
+	// The validator has exited once StopValidator returns
+	for len(ticked) > 0 {
+		<-ticked
+	}
+	time.Sleep(10 * time.Millisecond)
+	if len(ticked) != 0 {
+		t.Error("Validator still running after StopValidator")
+	}
+	cache.StopValidator()
+}
+
+func TestStartValidatorInvalidInterval(t *testing.T) {
+	cache := New(Config{Enabled: true, ShadowMode: true, Watchlist: []common.Address{common.HexToAddress("0x1")}})
+	stateAt := func(common.Hash) (StateReader, error) { return newMockStateReader(), nil }
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if err := cache.StartValidator(interval, stateAt); err == nil {
+			t.Errorf("Expected error for interval %v", interval)
+		}
+	}
+	cache.validatorMu.Lock()
+	running := cache.validatorStop != nil
+	cache.validatorMu.Unlock()
+	if running {
+		t.Error("Expected no validator to be started")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// WETH9 storage layout:
+// slot 0: name (string)
+// slot 1: symbol (string)
+// slot 2: decimals (uint8)
+// slot 3: balanceOf (mapping)
+// slot 4: allowance (mapping)
+//
+// WETH9 does not store its total supply, totalSupply() returns the contract's
+// ETH balance. The decoder therefore needs the native balance rather than any
+// storage slot.
+
+//go:generate go run github.com/fjl/gencodec -type WETHState -field-override wethStateMarshaling -out gen_weth_json.go
+
+// WETHState represents the decoded state of a WETH9 contract.
+type WETHState struct {
+	ETHBacking *big.Int `json:"ethBacking"` // ETH held, equal to the total supply
+}
+
+// wethStateMarshaling renders big integers as decimal strings.
+type wethStateMarshaling struct {
+	ETHBacking *math.Decimal256
+}
+
+// String returns a human-readable representation of the WETH state.
+func (s *WETHState) String() string {
+	return fmt.Sprintf("WETH{backing: %s}", s.ETHBacking)
+}
+
+// Copy returns a deep copy of the WETH state.
+func (s *WETHState) Copy() *WETHState {
+	return &WETHState{ETHBacking: copyBig(s.ETHBacking)}
+}
+
+// WETHDecoder decodes a WETH9 contract from its native balance. It requires a
+// StateReader implementing ExtendedStateReader, decoding fails otherwise.
+type WETHDecoder struct{}
+
+// Type returns the contract type.
+func (d *WETHDecoder) Type() ContractType {
+	return ContractTypeWETH
+}
+
+// Stateless reports that the decoder can be shared between contracts.
+func (d *WETHDecoder) Stateless() bool {
+	return true
+}
+
+// RequiredSlots returns no slots, the state is derived from the balance.
+func (d *WETHDecoder) RequiredSlots() []common.Hash {
+	return nil
+}
+
+// Decode fails, WETH cannot be decoded from storage alone.
+func (d *WETHDecoder) Decode(slots map[common.Hash]common.Hash) (interface{}, error) {
+	return d.DecodeBalance(slots, nil)
+}
+
+// DecodeBalance decodes the contract's native balance into WETHState.
+func (d *WETHDecoder) DecodeBalance(slots map[common.Hash]common.Hash, balance *big.Int) (interface{}, error) {
+	if balance == nil {
+		return nil, errors.New("native balance unavailable")
+	}
+	return &WETHState{ETHBacking: new(big.Int).Set(balance)}, nil
+}
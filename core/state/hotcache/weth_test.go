@@ -0,0 +1,93 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hotcache
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+func TestWETHDecoderNativeBalance(t *testing.T) {
+	var (
+		weth = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+		pool = common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	)
+	cache := New(Config{Enabled: true, Watchlist: []common.Address{weth, pool}})
+	cache.RegisterDecoder(weth, &WETHDecoder{})
+	cache.RegisterDecoder(pool, &UniswapV2Decoder{})
+
+	backing, _ := new(big.Int).SetString("3000000000000000000000000", 10)
+	reader := &extendedStateReader{
+		mockStateReader: newMockStateReader(),
+		balances: map[common.Address]*uint256.Int{
+			weth: uint256.MustFromBig(backing),
+			pool: uint256.NewInt(1),
+		},
+	}
+	if err := cache.Update(testHeader(1), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	state, err := cache.GetContractState(weth)
+	if err != nil {
+		t.Fatalf("WETH not cached: %v", err)
+	}
+	if state.Type != ContractTypeWETH || state.NativeBalance == nil || state.NativeBalance.Cmp(backing) != 0 {
+		t.Errorf("Unexpected native balance %v of %v contract", state.NativeBalance, state.Type)
+	}
+	decoded, ok := state.Decoded.(*WETHState)
+	if !ok || decoded.ETHBacking.Cmp(backing) != 0 {
+		t.Fatalf("Expected ETH backing %v, got %v (err %v)", backing, state.Decoded, state.DecodeError)
+	}
+	// The balance is only read for decoders requesting it
+	if state, _ := cache.GetContractState(pool); state.NativeBalance != nil {
+		t.Errorf("Unexpected native balance %v of pool", state.NativeBalance)
+	}
+
+	// The balance survives the JSON round trip
+	blob, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Failed to marshal contract state: %v", err)
+	}
+	var restored ContractState
+	if err := json.Unmarshal(blob, &restored); err != nil {
+		t.Fatalf("Failed to unmarshal contract state: %v", err)
+	}
+	if !restored.Equal(state) {
+		t.Errorf("Restored state differs: have %+v, want %+v", &restored, state)
+	}
+
+	// A balance change alone publishes a new state
+	reader.balances[weth] = uint256.NewInt(5)
+	if err := cache.Update(testHeader(2), reader); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if state, _ := cache.GetContractState(weth); state.NativeBalance.Uint64() != 5 {
+		t.Errorf("Expected updated native balance, got %v", state.NativeBalance)
+	}
+
+	// Plain readers cannot serve the balance
+	if err := cache.Update(testHeader(3), newMockStateReader()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if state, _ := cache.GetContractState(weth); state.DecodeError == nil || state.NativeBalance != nil {
+		t.Errorf("Expected decode failure without balance, got %v (balance %v)", state.DecodeError, state.NativeBalance)
+	}
+}
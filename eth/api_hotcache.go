@@ -0,0 +1,107 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state/hotcache"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// HotCacheAPI provides an API to query the hot state cache. Decoded contract
+// state is rendered with big integers as decimal strings.
+type HotCacheAPI struct {
+	chain *core.BlockChain
+}
+
+// NewHotCacheAPI creates a new HotCacheAPI instance.
+func NewHotCacheAPI(chain *core.BlockChain) *HotCacheAPI {
+	return &HotCacheAPI{chain: chain}
+}
+
+// GetContractState returns the cached state of a watched contract.
+func (api *HotCacheAPI) GetContractState(addr common.Address) (*hotcache.ContractState, error) {
+	return api.chain.GetHotCachedContractState(addr)
+}
+
+// GetSnapshot returns the current snapshot of all cached contracts.
+func (api *HotCacheAPI) GetSnapshot() (*hotcache.Snapshot, error) {
+	return api.chain.GetHotCacheSnapshot()
+}
+
+// NewSnapshot creates a subscription that is notified with a summary of every
+// published snapshot, listing the contracts changed since the previous
+// notification. Snapshots published while the client lags behind may be
+// skipped; their changes are included in the next notification.
+func (api *HotCacheAPI) NewSnapshot(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	// Take the baseline first so no snapshot published meanwhile is missed
+	prev, err := api.chain.GetHotCacheSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	updates, unsubscribe, err := api.chain.SubscribeHotCacheUpdates()
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case snapshot, ok := <-updates:
+				if !ok {
+					return
+				}
+				notifier.Notify(rpcSub.ID, snapshot.Summary(prev))
+				prev = snapshot
+			case <-rpcSub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// Statistics returns the hot cache performance counters.
+func (api *HotCacheAPI) Statistics() (hotcache.StatisticsSnapshot, error) {
+	return api.chain.GetHotCacheStatistics()
+}
+
+// Health returns the hot cache's safety signals, treating the cache as stale
+// if it lags the head by more than maxLag blocks.
+func (api *HotCacheAPI) Health(maxLag hexutil.Uint64) (hotcache.HealthReport, error) {
+	return api.chain.HotCacheHealth(uint64(maxLag))
+}
+
+// MemoryStats returns an estimate of the memory held by the cache.
+func (api *HotCacheAPI) MemoryStats() (hotcache.MemoryStats, error) {
+	return api.chain.GetHotCacheMemoryStats()
+}
+
+// Watchlist returns the addresses of all watched contracts.
+func (api *HotCacheAPI) Watchlist() ([]common.Address, error) {
+	return api.chain.HotCacheWatchlist()
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/hotcache"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newHotCacheTestChain creates a chain with the hot cache enabled, watching a
+// single Uniswap V2 pair whose reserves slot is seeded in the genesis.
+func newHotCacheTestChain(t *testing.T, pool common.Address, reserve0, reserve1 *big.Int) *core.BlockChain {
+	packed := new(big.Int).Or(reserve0, new(big.Int).Lsh(reserve1, 112))
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			pool: {
+				Balance: big.NewInt(1),
+				Code:    []byte{0x00},
+				Storage: map[common.Hash]common.Hash{
+					common.BigToHash(big.NewInt(8)): common.BigToHash(packed),
+				},
+			},
+		},
+	}
+	engine := ethash.NewFaker()
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 2, nil)
+
+	options := &core.BlockChainConfig{
+		TrieCleanLimit:     256,
+		TrieDirtyLimit:     256,
+		TrieTimeLimit:      5 * time.Minute,
+		EnableHotCache:     true,
+		HotCacheShadowMode: true,
+		HotCacheWatchlist:  []common.Address{pool},
+	}
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), gspec, engine, options)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if err := chain.RegisterHotCacheDecoder(pool, &hotcache.UniswapV2Decoder{}); err != nil {
+		t.Fatalf("failed to register decoder: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	return chain
+}
+
+func TestHotCacheAPI(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	chain := newHotCacheTestChain(t, pool, big.NewInt(1000000), big.NewInt(500))
+	defer chain.Stop()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("hotcache", NewHotCacheAPI(chain)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var state struct {
+		Address common.Address `json:"address"`
+		Type    string         `json:"type"`
+		Decoded struct {
+			Reserve0 string `json:"reserve0"`
+			Reserve1 string `json:"reserve1"`
+		} `json:"decoded"`
+	}
+	if err := client.Call(&state, "hotcache_getContractState", pool); err != nil {
+		t.Fatalf("hotcache_getContractState failed: %v", err)
+	}
+	if state.Address != pool || state.Type != "UniswapV2" {
+		t.Errorf("unexpected contract state: %+v", state)
+	}
+	if state.Decoded.Reserve0 != "1000000" || state.Decoded.Reserve1 != "500" {
+		t.Errorf("unexpected reserves: %s/%s", state.Decoded.Reserve0, state.Decoded.Reserve1)
+	}
+
+	var snapshot hotcache.Snapshot
+	if err := client.Call(&snapshot, "hotcache_getSnapshot"); err != nil {
+		t.Fatalf("hotcache_getSnapshot failed: %v", err)
+	}
+	if snapshot.BlockNumber != 2 || snapshot.Contracts[pool] == nil {
+		t.Errorf("unexpected snapshot at block %d with %d contracts", snapshot.BlockNumber, len(snapshot.Contracts))
+	}
+	if root := chain.CurrentBlock().Root; snapshot.StateRoot != root {
+		t.Errorf("unexpected snapshot state root: have %x, want %x", snapshot.StateRoot, root)
+	}
+
+	var stats hotcache.StatisticsSnapshot
+	if err := client.Call(&stats, "hotcache_statistics"); err != nil {
+		t.Fatalf("hotcache_statistics failed: %v", err)
+	}
+	if stats.Updates != 2 {
+		t.Errorf("expected 2 updates, got %d", stats.Updates)
+	}
+
+	var health hotcache.HealthReport
+	if err := client.Call(&health, "hotcache_health", hexutil.Uint64(0)); err != nil {
+		t.Fatalf("hotcache_health failed: %v", err)
+	}
+	if !health.Enabled || health.Stale || health.BlockNumber != 2 || health.ValidationErrors != 0 {
+		t.Errorf("unexpected health report: %+v", health)
+	}
+
+	var watchlist []common.Address
+	if err := client.Call(&watchlist, "hotcache_watchlist"); err != nil {
+		t.Fatalf("hotcache_watchlist failed: %v", err)
+	}
+	if len(watchlist) != 1 || watchlist[0] != pool {
+		t.Errorf("unexpected watchlist: %v", watchlist)
+	}
+}
+
+func TestHotCacheSubscription(t *testing.T) {
+	pool := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+	chain := newHotCacheTestChain(t, pool, big.NewInt(1000000), big.NewInt(500))
+	defer chain.Stop()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("hotcache", NewHotCacheAPI(chain)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	summaries := make(chan hotcache.SnapshotSummary, 1)
+	sub, err := client.Subscribe(context.Background(), "hotcache", summaries, "newSnapshot")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Publish a snapshot with changed reserves on top of the head state
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	statedb.SetState(pool, common.BigToHash(big.NewInt(8)), common.BigToHash(big.NewInt(42)))
+	header := &types.Header{Number: big.NewInt(3), ParentHash: chain.CurrentBlock().Hash()}
+	if err := chain.HotCache().Update(header, hotcache.NewStateDBReader(statedb)); err != nil {
+		t.Fatalf("hot cache update failed: %v", err)
+	}
+
+	select {
+	case summary := <-summaries:
+		if summary.BlockNumber != 3 || summary.BlockHash != header.Hash() {
+			t.Errorf("unexpected snapshot %d (%x)", summary.BlockNumber, summary.BlockHash)
+		}
+		if len(summary.Changed) != 1 || summary.Changed[0] != pool {
+			t.Errorf("unexpected changed contracts: %v", summary.Changed)
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no snapshot notification received")
+	}
+}
@@ -223,6 +223,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
 		}
 	}
+	var hotCacheJournal string
+	if config.HotCacheJournal != "" {
+		hotCacheJournal = stack.ResolvePath(config.HotCacheJournal)
+	}
 	var (
 		options = &core.BlockChainConfig{
 			TrieCleanLimit:   config.TrieCleanCache,
@@ -252,6 +256,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			HotCacheShadowMode:   config.HotCacheShadowMode,
 			HotCacheWatchlist:    config.HotCacheWatchlist,
 			HotCacheMaxSnapshots: config.HotCacheMaxSnapshots,
+			HotCacheJournal:      hotCacheJournal,
 		}
 	)
 	if config.VMTrace != "" {
@@ -420,6 +425,9 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "net",
 			Service:   s.netRPCService,
+		}, {
+			Namespace: "hotcache",
+			Service:   NewHotCacheAPI(s.blockchain),
 		},
 	}...)
 }
@@ -79,6 +79,7 @@ var Defaults = Config{
 	HotCacheShadowMode:   true,  // Always validate in shadow mode initially
 	HotCacheWatchlist:    []common.Address{},
 	HotCacheMaxSnapshots: 64,
+	HotCacheJournal:      "hotcache.journal",
 }
 
 //go:generate go run github.com/fjl/gencodec -type Config -formats toml -out gen_config.go
@@ -207,6 +208,7 @@ type Config struct {
 	HotCacheShadowMode   bool             // Validate cache against canonical state (recommended for initial deployment)
 	HotCacheWatchlist    []common.Address // Contract addresses to cache (e.g., Uniswap pools, Aave markets)
 	HotCacheMaxSnapshots int              // Maximum number of historical snapshots for reorg protection (default: 64)
+	HotCacheJournal      string           // File in the data directory persisting the hot cache across restarts
 }
 
 // CreateConsensusEngine creates a consensus engine for the given chain config.
@@ -71,6 +71,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		HotCacheShadowMode      bool
 		HotCacheWatchlist       []common.Address
 		HotCacheMaxSnapshots    int
+		HotCacheJournal         string
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -127,6 +128,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.HotCacheShadowMode = c.HotCacheShadowMode
 	enc.HotCacheWatchlist = c.HotCacheWatchlist
 	enc.HotCacheMaxSnapshots = c.HotCacheMaxSnapshots
+	enc.HotCacheJournal = c.HotCacheJournal
 	return &enc, nil
 }
 
@@ -187,6 +189,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		HotCacheShadowMode      *bool
 		HotCacheWatchlist       []common.Address
 		HotCacheMaxSnapshots    *int
+		HotCacheJournal         *string
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -354,5 +357,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.HotCacheMaxSnapshots != nil {
 		c.HotCacheMaxSnapshots = *dec.HotCacheMaxSnapshots
 	}
+	if dec.HotCacheJournal != nil {
+		c.HotCacheJournal = *dec.HotCacheJournal
+	}
 	return nil
 }